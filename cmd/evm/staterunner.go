@@ -99,8 +99,9 @@ func stateTestCmd(ctx *cli.Context) error {
 				// Test failed, mark as so and dump any state to aid debugging
 				result.Pass, result.Error = false, err.Error()
 				if ctx.GlobalBool(DumpFlag.Name) && state != nil {
-					dump := state.RawDump()
-					result.State = &dump
+					if dump, err := state.RawDump(); err == nil {
+						result.State = &dump
+					}
 				}
 			}
 			// print state root for evmlab tracing (already committed above, so no need to delete objects again
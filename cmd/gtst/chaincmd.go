@@ -19,14 +19,20 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/cmd/utils"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/clique"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
 	"github.com/lbchain-devchain/go-lbchain-dev/console"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
@@ -35,12 +41,31 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/event"
 	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
 	"github.com/lbchain-devchain/go-lbchain-dev/trie"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"gopkg.in/urfave/cli.v1"
 )
 
 var (
+	dumpNoCodeFlag = cli.BoolFlag{
+		Name:  "nocode",
+		Usage: "Exclude contract code from the dump",
+	}
+	dumpNoStorageFlag = cli.BoolFlag{
+		Name:  "nostorage",
+		Usage: "Exclude storage entries from the dump",
+	}
+	dumpStartFlag = cli.StringFlag{
+		Name:  "start",
+		Usage: "Account address or hash to start the dump from",
+	}
+	dumpLimitFlag = cli.Uint64Flag{
+		Name:  "limit",
+		Usage: "Maximum number of accounts to dump (0 = unlimited)",
+	}
+
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initGenesis),
 		Name:      "init",
@@ -69,7 +94,10 @@ It expects the genesis file as argument.`,
 			utils.LightModeFlag,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
+			utils.CacheNoPrefetchFlag,
 			utils.CacheGCFlag,
+			utils.ParallelExecFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -135,11 +163,39 @@ Remove blockchain and state databases`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			dumpNoCodeFlag,
+			dumpNoStorageFlag,
+			dumpStartFlag,
+			dumpLimitFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The arguments are interpreted as block numbers or hashes.
-Use "lbchain-devereum dump 0" to dump the genesis block.`,
+Use "lbchain-devereum dump 0" to dump the genesis block.
+
+The state is streamed as JSON Lines: a first object holding the state root,
+followed by one object per account in account hash order. Use --start and
+--limit to dump only a section of the state.`,
+	}
+	verifyWitnessCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyWitness),
+		Name:      "verify-witness",
+		Usage:     "Statelessly verify a block using its witness",
+		ArgsUsage: "<witnessFile> [<genesisPath>]",
+		Flags: []cli.Flag{
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-witness command reexecutes a block using only the trie nodes, contract
+codes and headers contained in its witness, as returned by debug_getBlockWitness,
+and checks the resulting state root, receipts and gas usage against the block.
+No local chain data is needed.
+
+The witness file may hold the RLP encoded witness in binary or hex form. The chain
+configuration is taken from the genesis file if given, otherwise from the network
+selected via the command line flags.`,
 	}
 )
 
@@ -218,7 +274,7 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*lbchain-devdb.LDBDatabase)
+	db := core.KeyValueStore(chainDb).(*lbchain-devdb.LDBDatabase)
 
 	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
@@ -330,7 +386,7 @@ func copyDb(ctx *cli.Context) error {
 	// Compact the entire database to remove any sync overhead
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*lbchain-devdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
+	if err = core.KeyValueStore(chainDb).(*lbchain-devdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
@@ -370,6 +426,19 @@ func removeDB(ctx *cli.Context) error {
 func dump(ctx *cli.Context) error {
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
+
+	conf := &state.DumpConfig{
+		SkipCode:    ctx.Bool(dumpNoCodeFlag.Name),
+		SkipStorage: ctx.Bool(dumpNoStorageFlag.Name),
+		Max:         ctx.Uint64(dumpLimitFlag.Name),
+	}
+	if start := ctx.String(dumpStartFlag.Name); start != "" {
+		key, err := hexutil.Decode(start)
+		if err != nil || (len(key) != common.AddressLength && len(key) != common.HashLength) {
+			utils.Fatalf("Invalid dump start %q, want address or hash", start)
+		}
+		conf.Start = state.DumpStartKey(key)
+	}
 	for _, arg := range ctx.Args() {
 		var block *types.Block
 		if hashish(arg) {
@@ -386,7 +455,9 @@ func dump(ctx *cli.Context) error {
 			if err != nil {
 				utils.Fatalf("could not create new state: %v", err)
 			}
-			fmt.Printf("%s\n", state.Dump())
+			if err := state.IterativeDump(conf, os.Stdout); err != nil {
+				utils.Fatalf("Failed to dump state: %v", err)
+			}
 		}
 	}
 	chainDb.Close()
@@ -398,3 +469,63 @@ func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
 	return err != nil
 }
+
+// verifyWitness loads a block witness from a file and verifies the block by
+// executing it statelessly.
+func verifyWitness(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 || len(ctx.Args()) > 2 {
+		utils.Fatalf("This command requires one or two arguments.")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read witness: %v", err)
+	}
+	// Accept both binary and hex encoded witnesses, the latter possibly quoted
+	if text := strings.Trim(strings.TrimSpace(string(blob)), `"`); len(text) > 0 {
+		if !strings.HasPrefix(text, "0x") {
+			text = "0x" + text
+		}
+		if dec, err := hexutil.Decode(text); err == nil {
+			blob = dec
+		}
+	}
+	witness := new(core.Witness)
+	if err := rlp.DecodeBytes(blob, witness); err != nil {
+		utils.Fatalf("Invalid witness: %v", err)
+	}
+	// Assemble the chain configuration and a consensus engine for finalisation
+	genesis := utils.MakeGenesis(ctx)
+	if ctx.NArg() == 2 {
+		file, err := os.Open(ctx.Args().Get(1))
+		if err != nil {
+			utils.Fatalf("Failed to read genesis file: %v", err)
+		}
+		defer file.Close()
+
+		genesis = new(core.Genesis)
+		if err := json.NewDecoder(file).Decode(genesis); err != nil {
+			utils.Fatalf("Invalid genesis file: %v", err)
+		}
+	}
+	config := params.MainnetChainConfig
+	if genesis != nil && genesis.Config != nil {
+		config = genesis.Config
+	}
+	var engine consensus.Engine = ethash.NewFaker()
+	if config.Clique != nil {
+		db, _ := lbchain-devdb.NewMemDatabase()
+		engine = clique.New(config.Clique, db)
+	}
+	block := witness.Block
+	if block == nil {
+		utils.Fatalf("Invalid witness: no block")
+	}
+	start := time.Now()
+	if err := core.VerifyWitness(config, engine, witness); err != nil {
+		utils.Fatalf("Block #%d [%x] failed verification: %v", block.NumberU64(), block.Hash(), err)
+	}
+	fmt.Printf("Block #%d [%x] verified\n", block.NumberU64(), block.Hash())
+	fmt.Printf("Witness: %d trie nodes, %d codes, %d headers, %v, elapsed %v\n",
+		len(witness.Nodes), len(witness.Codes), len(witness.Headers), witness.Size(), common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-lbchain-devereum.
+//
+// go-lbchain-devereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-lbchain-devereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-lbchain-devereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/cmd/utils"
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Low level database operations",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Inspect the contents of the chain database.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Report the number and size of the database entries by type",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDatabase),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.CacheDatabaseFlag,
+					utils.AncientFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+    glbchain-dev db inspect
+
+Iterates over the entire key-value store and classifies every entry by the
+database schema (headers, bodies, receipts, trie nodes, etc), reporting their
+count and total size. The sizes of the ancient store tables are listed too.`,
+			},
+			{
+				Name:      "inspect-trie",
+				Usage:     "Report the node layout of a state trie and its storage tries",
+				ArgsUsage: "[<stateRoot>]",
+				Action:    utils.MigrateFlags(inspectTrie),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.CacheDatabaseFlag,
+					utils.AncientFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+    glbchain-dev db inspect-trie [<stateRoot>]
+
+Walks the state trie with the given root (or the state of the current head
+block if omitted) and all the storage tries referenced from it, reporting the
+node counts by depth and type, the number of leaves and the total size.`,
+			},
+		},
+	}
+)
+
+// inspectDatabase iterates over the chain database and prints the number and
+// size of entries in every data category.
+func inspectDatabase(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	stats, err := core.InspectDatabase(chainDb)
+	if err != nil {
+		utils.Fatalf("Failed to inspect database: %v", err)
+	}
+	var (
+		table = tablewriter.NewWriter(os.Stdout)
+		count uint64
+		size  common.StorageSize
+	)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Category", "Items", "Size"})
+	for _, stat := range stats {
+		table.Append([]string{stat.Category, fmt.Sprintf("%d", stat.Count), stat.Size.String()})
+		count, size = count+stat.Count, size+stat.Size
+	}
+	table.SetFooter([]string{"Total", fmt.Sprintf("%d", count), size.String()})
+	table.Render()
+	return nil
+}
+
+// inspectTrie walks a state trie along with its storage tries and prints their
+// node layout.
+func inspectTrie(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command requires at most one argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	var root common.Hash
+	if ctx.NArg() == 1 {
+		root = common.HexToHash(ctx.Args().First())
+	} else {
+		hash := core.GetHeadBlockHash(chainDb)
+		header := core.GetHeader(chainDb, hash, core.GetBlockNumber(chainDb, hash))
+		if header == nil {
+			utils.Fatalf("Failed to load head block %x", hash)
+		}
+		root = header.Root
+	}
+	var (
+		triedb   = trie.NewDatabase(chainDb)
+		storage  = new(trie.Stats)
+		contract = make(map[common.Hash]struct{})
+	)
+	accounts, err := trie.Inspect(root, triedb, func(leaf []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return err
+		}
+		if account.Root == types.EmptyRootHash {
+			return nil
+		}
+		// Identical storage tries share their nodes, only count them once
+		if _, ok := contract[account.Root]; ok {
+			return nil
+		}
+		contract[account.Root] = struct{}{}
+
+		stats, err := trie.Inspect(account.Root, triedb, nil)
+		if err != nil {
+			return err
+		}
+		storage.Add(stats)
+		return nil
+	})
+	if err != nil {
+		utils.Fatalf("Failed to inspect state trie %x: %v", root, err)
+	}
+	fmt.Printf("Account trie %x:\n", root)
+	printTrieStats(accounts)
+
+	fmt.Printf("\nStorage tries (%d distinct):\n", len(contract))
+	printTrieStats(storage)
+	return nil
+}
+
+// printTrieStats renders the per depth node counts and totals of a trie.
+func printTrieStats(stats *trie.Stats) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Depth", "Full", "Extension", "Leaf"})
+
+	var full, ext, leaf uint64
+	for depth, stat := range stats.Depths {
+		table.Append([]string{fmt.Sprintf("%d", depth), fmt.Sprintf("%d", stat.Full), fmt.Sprintf("%d", stat.Extension), fmt.Sprintf("%d", stat.Leaf)})
+		full, ext, leaf = full+stat.Full, ext+stat.Extension, leaf+stat.Leaf
+	}
+	table.SetFooter([]string{"Total", fmt.Sprintf("%d", full), fmt.Sprintf("%d", ext), fmt.Sprintf("%d", leaf)})
+	table.Render()
+
+	fmt.Printf("Leaves: %d, stored nodes: %d, size: %v\n", stats.Leaves, stats.Nodes, stats.Size)
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-lbchain-devereum.
+//
+// go-lbchain-devereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-lbchain-devereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-lbchain-devereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/cmd/utils"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/pruner"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/prometheus/prometheus/util/flock"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	pruneRetainFlag = cli.Uint64Flag{
+		Name:  "retain",
+		Usage: "Number of recent canonical block states to retain",
+		Value: pruner.DefaultRetain,
+	}
+
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage the state stored in the database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Manage the persisted lbchain-devchain state trie.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Delete all state not reachable from recent blocks",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.CacheDatabaseFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					pruneRetainFlag,
+				},
+				Description: `
+    glbchain-dev snapshot prune-state [--retain N]
+
+Marks every trie node and contract code reachable from the state roots of the
+last N canonical blocks and deletes all other state from the database. The
+node must not be running while pruning.
+
+Progress is persisted, so an interrupted pruning can be resumed by running the
+same command again. After deleting, all retained states are walked to verify
+they are still complete.`,
+			},
+		},
+	}
+)
+
+// pruneState deletes all the stale state from the database, keeping only the
+// most recent canonical states.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+
+	// Hold the instance lock so a node can't be started mid-pruning
+	release, _, err := flock.New(filepath.Join(stack.InstanceDir(), "LOCK"))
+	if err != nil {
+		utils.Fatalf("Node seems to be running, refusing to prune: %v", err)
+	}
+	defer release.Release()
+
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	db, ok := core.KeyValueStore(chainDb).(*lbchain-devdb.LDBDatabase)
+	if !ok {
+		utils.Fatalf("State pruning requires a persistent database")
+	}
+	p := pruner.New(db, stack.ResolvePath("prunestate"), ctx.Uint64(pruneRetainFlag.Name))
+	if err := p.Prune(); err != nil {
+		utils.Fatalf("State pruning failed: %v", err)
+	}
+	return nil
+}
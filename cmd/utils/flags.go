@@ -48,6 +48,7 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/les"
 	"github.com/lbchain-devchain/go-lbchain-dev/log"
 	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+	"github.com/lbchain-devchain/go-lbchain-dev/miner"
 	"github.com/lbchain-devchain/go-lbchain-dev/node"
 	"github.com/lbchain-devchain/go-lbchain-dev/p2p"
 	"github.com/lbchain-devchain/go-lbchain-dev/p2p/discover"
@@ -118,6 +119,15 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	AncientThresholdFlag = cli.Uint64Flag{
+		Name:  "datadir.ancient.threshold",
+		Usage: "Number of recent blocks to keep out of the ancient store (0 = disabled)",
+		Value: lbchain-dev.DefaultConfig.AncientThreshold,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -259,6 +269,16 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolSnapshotFlag = cli.StringFlag{
+		Name:  "txpool.snapshot",
+		Usage: "Disk snapshot of the full pool saved on shutdown and restored on startup (disabled if empty)",
+		Value: core.DefaultTxPoolConfig.Snapshot,
+	}
+	TxPoolSnapshotAgeFlag = cli.DurationFlag{
+		Name:  "txpool.snapshotage",
+		Usage: "Maximum age of the pool snapshot to still restore it on startup",
+		Value: core.DefaultTxPoolConfig.SnapshotAge,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -294,6 +314,11 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: lbchain-dev.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolHistoryFlag = cli.Uint64Flag{
+		Name:  "txpool.history",
+		Usage: "Number of recently seen transactions to retain the lifecycle history of",
+		Value: lbchain-dev.DefaultConfig.TxPool.History,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -305,6 +330,15 @@ var (
 		Usage: "Percentage of cache memory allowance to use for database io",
 		Value: 75,
 	}
+	CacheTrieFlag = cli.IntFlag{
+		Name:  "cache.trie",
+		Usage: "Megabytes of memory allocated to caching clean trie nodes, on top of --cache",
+		Value: lbchain-dev.DefaultConfig.TrieCleanCache,
+	}
+	CacheNoPrefetchFlag = cli.BoolFlag{
+		Name:  "cache.noprefetch",
+		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
+	}
 	CacheGCFlag = cli.IntFlag{
 		Name:  "cache.gc",
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
@@ -315,6 +349,14 @@ var (
 		Usage: "Number of trie node generations to keep in memory",
 		Value: int(state.MaxTrieCacheGen),
 	}
+	ParallelExecFlag = cli.BoolFlag{
+		Name:  "exec.parallel",
+		Usage: "Execute block transactions concurrently, reexecuting conflicting ones in order (more CPU, less import time)",
+	}
+	StateDiffsFlag = cli.BoolFlag{
+		Name:  "statediffs",
+		Usage: "Record the state changes of imported blocks, serving recent ones without reexecution",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -344,6 +386,15 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: "Ordering of the transactions in the mined blocks (price, arrival, roundrobin, priority)",
+		Value: miner.OrderingPrice,
+	}
+	MinerPriorityFlag = cli.StringFlag{
+		Name:  "miner.priority",
+		Usage: "Comma separated list of system contracts whose transactions are included first by the priority ordering",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -919,6 +970,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalString(TxPoolSnapshotFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSnapshotAgeFlag.Name) {
+		cfg.SnapshotAge = ctx.GlobalDuration(TxPoolSnapshotAgeFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -940,6 +997,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolHistoryFlag.Name) {
+		cfg.History = ctx.GlobalUint64(TxPoolHistoryFlag.Name)
+	}
 }
 
 func setlbchain-devash(ctx *cli.Context, cfg *lbchain-dev.Config) {
@@ -1048,14 +1108,33 @@ func Setlbchain-devConfig(ctx *cli.Context, stack *node.Node, cfg *lbchain-dev.C
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
 
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientThresholdFlag.Name) {
+		cfg.AncientThreshold = ctx.GlobalUint64(AncientThresholdFlag.Name)
+	}
+
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cfg.TrieCleanCache = ctx.GlobalInt(CacheTrieFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
+		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelExecFlag.Name) {
+		cfg.ParallelExec = ctx.GlobalBool(ParallelExecFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateDiffsFlag.Name) {
+		cfg.StateDiffs = ctx.GlobalBool(StateDiffsFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
 	}
@@ -1068,6 +1147,18 @@ func Setlbchain-devConfig(ctx *cli.Context, stack *node.Node, cfg *lbchain-dev.C
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.MinerOrdering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPriorityFlag.Name) {
+		cfg.MinerPriority = nil
+		for _, addr := range strings.Split(ctx.GlobalString(MinerPriorityFlag.Name), ",") {
+			if addr = strings.TrimSpace(addr); !common.IsHexAddress(addr) {
+				Fatalf("Invalid system contract address %q", addr)
+			}
+			cfg.MinerPriority = append(cfg.MinerPriority, common.HexToAddress(addr))
+		}
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1185,6 +1276,8 @@ func SetupNetwork(ctx *cli.Context) {
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
+// The ancient chain segments are accessible, but no new ones are frozen, as that
+// is only done by the running node.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) lbchain-devdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
@@ -1198,6 +1291,15 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) lbchain-devdb.Databas
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	if !ctx.GlobalBool(LightModeFlag.Name) {
+		cfg := &lbchain-dev.Config{
+			DatabaseFreezer:  ctx.GlobalString(AncientFlag.Name),
+			AncientThreshold: ctx.GlobalUint64(AncientThresholdFlag.Name),
+		}
+		if chainDb, err = lbchain-dev.CreateAncientDB(chainDb, cfg, false); err != nil {
+			Fatalf("Could not open ancient database: %v", err)
+		}
+	}
 	return chainDb
 }
 
@@ -1243,9 +1345,14 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cache := &core.CacheConfig{
-		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
-		TrieNodeLimit: lbchain-dev.DefaultConfig.TrieCache,
-		TrieTimeLimit: lbchain-dev.DefaultConfig.TrieTimeout,
+		Disabled:       ctx.GlobalString(GCModeFlag.Name) == "archive",
+		TrieCleanLimit: lbchain-dev.DefaultConfig.TrieCleanCache,
+		TrieNodeLimit:  lbchain-dev.DefaultConfig.TrieCache,
+		TrieTimeLimit:  lbchain-dev.DefaultConfig.TrieTimeout,
+		NoPrefetch:     ctx.GlobalBool(CacheNoPrefetchFlag.Name),
+	}
+	if ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cache.TrieCleanLimit = ctx.GlobalInt(CacheTrieFlag.Name)
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -1255,6 +1362,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
+	if ctx.GlobalBool(ParallelExecFlag.Name) {
+		chain.SetProcessor(core.NewParallelStateProcessor(config, chain, engine))
+	}
 	return chain, chainDb
 }
 
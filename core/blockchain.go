@@ -28,9 +28,11 @@ import (
 	"time"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/mclock"
 	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
 	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
@@ -54,9 +56,12 @@ var (
 const (
 	bodyCacheLimit      = 256
 	blockCacheLimit     = 256
+	diffCacheLimit      = 128
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	badBlockStoreLimit  = 16
+	reorgHistoryLimit   = 64
 	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
@@ -66,9 +71,12 @@ const (
 // CacheConfig contains the configuration values for the trie caching/pruning
 // that's resident in a blockchain.
 type CacheConfig struct {
-	Disabled      bool          // Whlbchain-dever to disable trie write caching (archive node)
-	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
-	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Disabled       bool          // Whlbchain-dever to disable trie write caching (archive node)
+	TrieCleanLimit int           // Memory allowance (MB) to use for caching clean trie nodes in memory
+	TrieNodeLimit  int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	NoPrefetch     bool          // Whlbchain-dever to disable speculative state prefetching during block import
+	StateDiffs     bool          // Whlbchain-dever to record the state changes of the imported blocks
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -93,14 +101,15 @@ type BlockChain struct {
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
-	hc            *HeaderChain
-	rmLogsFeed    event.Feed
-	chainFeed     event.Feed
-	chainSideFeed event.Feed
-	chainHeadFeed event.Feed
-	logsFeed      event.Feed
-	scope         event.SubscriptionScope
-	genesisBlock  *types.Block
+	hc             *HeaderChain
+	rmLogsFeed     event.Feed
+	chainFeed      event.Feed
+	chainSideFeed  event.Feed
+	chainHeadFeed  event.Feed
+	chainReorgFeed event.Feed
+	logsFeed       event.Feed
+	scope          event.SubscriptionScope
+	genesisBlock   *types.Block
 
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
@@ -111,10 +120,12 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat snapshot of the recent states for fast access
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	diffCache    *lru.Cache     // Cache for the state diffs of the most recently imported blocks
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -122,12 +133,17 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine    consensus.Engine
-	processor Processor // block processor interface
-	validator Validator // block and state validator interface
-	vmConfig  vm.Config
+	engine     consensus.Engine
+	processor  Processor  // block processor interface
+	validator  Validator  // block and state validator interface
+	prefetcher Prefetcher // block state prefetcher interface
+	vmConfig   vm.Config
 
 	badBlocks *lru.Cache // Bad block cache
+
+	reorgs        []ChainReorgEvent // Most recent chain reorganisations, oldest first
+	pendingReorgs []interface{}     // Reorg events not yet posted, sent along with the next chain events
+	reorgLock     sync.RWMutex
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -136,14 +152,16 @@ type BlockChain struct {
 func NewBlockChain(db lbchain-devdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
-			TrieNodeLimit: 256 * 1024 * 1024,
-			TrieTimeLimit: 5 * time.Minute,
+			TrieCleanLimit: 256,
+			TrieNodeLimit:  256 * 1024 * 1024,
+			TrieTimeLimit:  5 * time.Minute,
 		}
 	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	diffCache, _ := lru.New(diffCacheLimit)
 	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
@@ -151,18 +169,20 @@ func NewBlockChain(db lbchain-devdb.Database, cacheConfig *CacheConfig, chainCon
 		cacheConfig:  cacheConfig,
 		db:           db,
 		triegc:       prque.New(),
-		stateCache:   state.NewDatabase(db),
+		stateCache:   state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
+		diffCache:    diffCache,
 		engine:       engine,
 		vmConfig:     vmConfig,
 		badBlocks:    badBlocks,
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
+	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.getProcInterrupt)
@@ -189,6 +209,11 @@ func NewBlockChain(db lbchain-devdb.Database, cacheConfig *CacheConfig, chainCon
 			}
 		}
 	}
+	// Load the flat state snapshot, regenerating it in the background if missing
+	if ldb, ok := KeyValueStore(db).(*lbchain-devdb.LDBDatabase); ok {
+		bc.snaps = snapshot.New(ldb, bc.stateCache.TrieDB(), bc.CurrentBlock().Root())
+		bc.stateCache = state.NewDatabaseWithSnapshots(bc.stateCache, bc.snaps)
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -539,8 +564,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.db.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.db, hash, number)
 }
 
 // HasState checks if state trie is fully present in the database or not.
@@ -596,6 +620,17 @@ func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	return GetBlockReceipts(bc.db, hash, GetBlockNumber(bc.db, hash))
 }
 
+// GetStateDiffs retrieves the state changes recorded while importing a recent
+// block, one entry for each of its transactions and for the block finalisation.
+// Nil is returned if the block was not imported recently or diff recording is
+// disabled.
+func (bc *BlockChain) GetStateDiffs(hash common.Hash) []*state.TxStateDiff {
+	if diffs, ok := bc.diffCache.Get(hash); ok {
+		return diffs.([]*state.TxStateDiff)
+	}
+	return nil
+}
+
 // GetBlocksFromHash returns the block corresponding to hash and up to n-1 ancestors.
 // [deprecated by lbchain-dev/62]
 func (bc *BlockChain) GetBlocksFromHash(hash common.Hash, n int) (blocks []*types.Block) {
@@ -642,6 +677,13 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Flatten the state snapshot of the head to disk to avoid regenerating it
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to persist state snapshot", "err", err)
+		}
+		bc.snaps.Stop()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -985,6 +1027,16 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		// Keep the state snapshot tracking the canonical chain, rebuilding it if the
+		// chain moved to a state it has no layer for
+		if bc.snaps != nil {
+			if bc.snaps.Snapshot(root) == nil {
+				bc.snaps.Rebuild(root)
+			} else if err := bc.snaps.Cap(root, triesInMemory-1); err != nil {
+				log.Warn("Failed to cap state snapshot", "root", root, "err", err)
+			}
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -997,7 +1049,13 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 //
 // After insertion is done, all accumulated events will be fired.
 func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
-	n, events, logs, err := bc.insertChain(chain)
+	return bc.InsertChainFrom("", chain)
+}
+
+// InsertChainFrom inserts a batch of blocks like InsertChain, recording the
+// given peer as the origin of any bad block encountered.
+func (bc *BlockChain) InsertChainFrom(origin string, chain types.Blocks) (int, error) {
+	n, events, logs, err := bc.insertChain(origin, chain)
 	bc.PostChainEvents(events, logs)
 	return n, err
 }
@@ -1005,7 +1063,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // insertChain will execute the actual chain insertion and event aggregation. The
 // only reason this method exists as a separate one is to make locking cleaner
 // with deferred statements.
-func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*types.Log, error) {
+func (bc *BlockChain) insertChain(origin string, chain types.Blocks) (int, []interface{}, []*types.Log, error) {
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
 		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
@@ -1044,6 +1102,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	abort, results := bc.engine.VerifyHeaders(bc, headers, seals)
 	defer close(abort)
 
+	// Interrupt any speculative execution of an upcoming block when returning
+	var followup *prefetchTask
+	defer func() {
+		if followup != nil {
+			followup.stop()
+		}
+	}()
+
 	// Iterate over the blocks and insert when the verifier permits
 	for i, block := range chain {
 		// If the chain is terminating, stop processing blocks
@@ -1053,7 +1119,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		}
 		// If the header is a banned one, straight out abort
 		if BadHashes[block.Hash()] {
-			bc.reportBlock(block, nil, ErrBlacklistedHash)
+			bc.reportBlock(origin, block, nil, ErrBlacklistedHash)
 			return i, events, coalescedLogs, ErrBlacklistedHash
 		}
 		// Wait for the block's verification to complete
@@ -1113,7 +1179,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			}
 			// Import all the pruned blocks to make the state available
 			bc.chainmu.Unlock()
-			_, evs, logs, err := bc.insertChain(winner)
+			_, evs, logs, err := bc.insertChain(origin, winner)
 			bc.chainmu.Lock()
 			events, coalescedLogs = evs, logs
 
@@ -1122,7 +1188,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			}
 
 		case err != nil:
-			bc.reportBlock(block, nil, err)
+			bc.reportBlock(origin, block, nil, err)
 			return i, events, coalescedLogs, err
 		}
 		// Create a new statedb using the parent block and report an
@@ -1137,25 +1203,50 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		// While processing the block, speculatively execute the next one on top
+		// of the same parent state to warm up the caches for it
+		prefetched := followup
+		followup = nil
+		if i+1 < len(chain) && !bc.cacheConfig.NoPrefetch {
+			followup = bc.prefetch(chain[i+1], parent.Root())
+		}
+		if bc.cacheConfig.StateDiffs {
+			state.EnableDiffs()
+		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
 		if err != nil {
-			bc.reportBlock(block, receipts, err)
+			bc.reportBlock(origin, block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
 		// Validate the state using the default validator
 		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas)
 		if err != nil {
-			bc.reportBlock(block, receipts, err)
+			bc.reportBlock(origin, block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		if followup != nil {
+			followup.stop()
+		}
+		if prefetched != nil {
+			prefetched.report(block, state)
+		}
 		proctime := time.Since(bstart)
 
+		// Retrieve the recorded diffs before committing the state
+		diffs := state.Diffs()
+
 		// Write the block to the chain and get the status.
 		status, err := bc.WriteBlockWithState(block, receipts, state)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		if diffs != nil {
+			bc.diffCache.Add(block.Hash(), diffs)
+		}
+		// Announce any reorg caused by the block before the block itself
+		events = append(events, bc.takeReorgEvents()...)
+
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", block.Number(), "hash", block.Hash(), "uncles", len(block.Uncles()),
@@ -1328,6 +1419,9 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	}
 	// calculate the difference between deleted and added transactions
 	diff := types.TxDifference(deletedTxs, addedTxs)
+	if len(oldChain) > 0 {
+		bc.recordReorg(commonBlock, oldChain, newChain, diff)
+	}
 	// When transactions get deleted from the database that means the
 	// receipts that were created in the fork must also be deleted
 	for _, tx := range diff {
@@ -1347,6 +1441,61 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	return nil
 }
 
+// recordReorg assembles the summary of a chain reorganisation from the dropped
+// and added blocks (both ordered head first) and the transactions not included
+// in the new chain, adds it to the reorg history and queues it for announcing
+// along with the chain events of the insertion.
+func (bc *BlockChain) recordReorg(ancestor *types.Block, oldChain, newChain types.Blocks, dropped types.Transactions) {
+	ev := ChainReorgEvent{
+		Ancestor: ancestor.Header(),
+		Dropped:  make(types.Blocks, 0, len(oldChain)),
+		Added:    make(types.Blocks, 0, len(newChain)),
+	}
+	lost := make(map[common.Hash]struct{}, len(dropped))
+	for _, tx := range dropped {
+		lost[tx.Hash()] = struct{}{}
+	}
+	for i := len(oldChain) - 1; i >= 0; i-- {
+		ev.Dropped = append(ev.Dropped, oldChain[i])
+		for _, tx := range oldChain[i].Transactions() {
+			if _, ok := lost[tx.Hash()]; ok {
+				ev.DroppedTxs = append(ev.DroppedTxs, tx)
+			} else {
+				ev.ReincludedTxs = append(ev.ReincludedTxs, tx)
+			}
+		}
+	}
+	for i := len(newChain) - 1; i >= 0; i-- {
+		ev.Added = append(ev.Added, newChain[i])
+	}
+	bc.reorgLock.Lock()
+	if len(bc.reorgs) >= reorgHistoryLimit {
+		bc.reorgs = append(bc.reorgs[:0], bc.reorgs[1:]...)
+	}
+	bc.reorgs = append(bc.reorgs, ev)
+	bc.pendingReorgs = append(bc.pendingReorgs, ev)
+	bc.reorgLock.Unlock()
+}
+
+// takeReorgEvents returns the reorg events recorded since the last call, in the
+// order they happened.
+func (bc *BlockChain) takeReorgEvents() []interface{} {
+	bc.reorgLock.Lock()
+	defer bc.reorgLock.Unlock()
+
+	events := bc.pendingReorgs
+	bc.pendingReorgs = nil
+	return events
+}
+
+// Reorgs returns the most recent chain reorganisations, oldest first.
+func (bc *BlockChain) Reorgs() []ChainReorgEvent {
+	bc.reorgLock.RLock()
+	defer bc.reorgLock.RUnlock()
+
+	return append([]ChainReorgEvent{}, bc.reorgs...)
+}
+
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
@@ -1355,7 +1504,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 	if logs != nil {
 		bc.logsFeed.Send(logs)
 	}
-	for _, event := range events {
+	// Reorgs caused by blocks written outside of an insertion (e.g. mined ones)
+	// precede the events of those blocks
+	for _, event := range append(bc.takeReorgEvents(), events...) {
 		switch ev := event.(type) {
 		case ChainEvent:
 			bc.chainFeed.Send(ev)
@@ -1365,6 +1516,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
+
+		case ChainReorgEvent:
+			bc.chainReorgFeed.Send(ev)
 		}
 	}
 }
@@ -1383,31 +1537,69 @@ func (bc *BlockChain) update() {
 }
 
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
+// The fields beyond the header are only available for the blocks persisted in the
+// database.
 type BadBlockArgs struct {
-	Hash   common.Hash   `json:"hash"`
-	Header *types.Header `json:"header"`
+	Hash   common.Hash    `json:"hash"`
+	Header *types.Header  `json:"header"`
+	RLP    hexutil.Bytes  `json:"rlp,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+	Peer   string         `json:"peer,omitempty"`
+	Time   hexutil.Uint64 `json:"time,omitempty"`
 }
 
-// BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
+// BadBlocks returns a list of the last 'bad blocks' that the client has seen on the
+// network, both the ones persisted in the database and the ones only cached in memory.
 func (bc *BlockChain) BadBlocks() ([]BadBlockArgs, error) {
-	headers := make([]BadBlockArgs, 0, bc.badBlocks.Len())
+	var (
+		stored  = GetBadBlocks(bc.db)
+		headers = make([]BadBlockArgs, 0, len(stored)+bc.badBlocks.Len())
+		known   = make(map[common.Hash]bool)
+	)
+	for _, bad := range stored {
+		blob, err := rlp.EncodeToBytes(bad.Block)
+		if err != nil {
+			return nil, err
+		}
+		header := bad.Block.Header()
+		headers = append(headers, BadBlockArgs{
+			Hash:   header.Hash(),
+			Header: header,
+			RLP:    blob,
+			Reason: bad.Reason,
+			Peer:   bad.Peer,
+			Time:   hexutil.Uint64(bad.Time),
+		})
+		known[header.Hash()] = true
+	}
 	for _, hash := range bc.badBlocks.Keys() {
-		if hdr, exist := bc.badBlocks.Peek(hash); exist {
+		if hdr, exist := bc.badBlocks.Peek(hash); exist && !known[hash.(common.Hash)] {
 			header := hdr.(*types.Header)
-			headers = append(headers, BadBlockArgs{header.Hash(), header})
+			headers = append(headers, BadBlockArgs{Hash: header.Hash(), Header: header})
 		}
 	}
 	return headers, nil
 }
 
-// addBadBlock adds a bad block to the bad-block LRU cache
-func (bc *BlockChain) addBadBlock(block *types.Block) {
+// BadBlock retrieves a bad block persisted in the database by hash.
+func (bc *BlockChain) BadBlock(hash common.Hash) *BadBlock {
+	return GetBadBlock(bc.db, hash)
+}
+
+// addBadBlock adds a bad block to the bad-block LRU cache and persists it along
+// with the reason of its rejection and its origin.
+func (bc *BlockChain) addBadBlock(origin string, block *types.Block, reason error) {
 	bc.badBlocks.Add(block.Header().Hash(), block.Header())
+
+	bad := &BadBlock{Block: block, Reason: reason.Error(), Peer: origin, Time: uint64(time.Now().Unix())}
+	if err := WriteBadBlock(bc.db, bad, badBlockStoreLimit); err != nil {
+		log.Error("Failed to store bad block", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
 }
 
 // reportBlock logs a bad block error.
-func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
-	bc.addBadBlock(block)
+func (bc *BlockChain) reportBlock(origin string, block *types.Block, receipts types.Receipts, err error) {
+	bc.addBadBlock(origin, block, err)
 
 	var receiplbchain-devring string
 	for _, receipt := range receipts {
@@ -1552,6 +1744,11 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeChainReorgEvent registers a subscription of ChainReorgEvent.
+func (bc *BlockChain) SubscribeChainReorgEvent(ch chan<- ChainReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.chainReorgFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
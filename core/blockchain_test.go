@@ -17,6 +17,9 @@
 package core
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -32,6 +35,7 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
 )
 
 // Test fork of length N starting from block i
@@ -119,12 +123,12 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		}
 		receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock("", block, receipts, err)
 			return err
 		}
 		err = blockchain.validator.ValidateState(block, blockchain.GetBlockByHash(block.ParentHash()), statedb, receipts, usedGas)
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock("", block, receipts, err)
 			return err
 		}
 		blockchain.mu.Lock()
@@ -451,6 +455,46 @@ func testBadHashes(t *testing.T, full bool) {
 	}
 }
 
+// Tests that rejected blocks are persisted along with the reason and origin of
+// the rejection, surviving a restart.
+func TestBadBlockPersistence(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 3, ethash.NewFaker(), db, 10)
+
+	BadHashes[blocks[2].Hash()] = true
+	defer func() { delete(BadHashes, blocks[2].Hash()) }()
+
+	if _, err := blockchain.InsertChainFrom("bad peer", blocks); err != ErrBlacklistedHash {
+		t.Fatalf("error mismatch: have: %v, want: %v", err, ErrBlacklistedHash)
+	}
+	blockchain.Stop()
+
+	// Reopen the chain and check the bad block is still reported in full
+	blockchain, _ = NewBlockChain(db, nil, params.Alllbchain-devashProtocolChanges, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	bads, err := blockchain.BadBlocks()
+	if err != nil {
+		t.Fatalf("failed to retrieve bad blocks: %v", err)
+	}
+	if len(bads) != 1 {
+		t.Fatalf("bad block count mismatch: have %d, want %d", len(bads), 1)
+	}
+	if bads[0].Hash != blocks[2].Hash() || bads[0].Peer != "bad peer" || bads[0].Reason != ErrBlacklistedHash.Error() {
+		t.Errorf("bad block mismatch: have %x/%q/%q, want %x/%q/%q", bads[0].Hash, bads[0].Peer, bads[0].Reason, blocks[2].Hash(), "bad peer", ErrBlacklistedHash)
+	}
+	blob, _ := rlp.EncodeToBytes(blocks[2])
+	if !bytes.Equal(bads[0].RLP, blob) {
+		t.Errorf("bad block RLP mismatch: have %x, want %x", bads[0].RLP, blob)
+	}
+	if bad := blockchain.BadBlock(blocks[2].Hash()); bad == nil || bad.Block.Hash() != blocks[2].Hash() {
+		t.Errorf("bad block not retrievable by hash")
+	}
+}
+
 // Tests that bad hashes are detected on boot, and the chain rolled back to a
 // good state prior to the bad hash.
 func TestReorgBadHeaderHashes(t *testing.T) { testReorgBadHashes(t, false) }
@@ -978,6 +1022,143 @@ done:
 
 }
 
+// Tests that a reorg is announced with its common ancestor, the dropped and the
+// added blocks and the fate of the dropped transactions, and recorded in the
+// reorg history.
+func TestReorgEvent(t *testing.T) {
+	var (
+		db, _   = lbchain-devdb.NewMemDatabase()
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}, addr2: {Balance: big.NewInt(10000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	newTx := func(key *ecdsa.PrivateKey, nonce uint64) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), params.TxGas, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		return tx
+	}
+	txA, txB, txC := newTx(key1, 0), newTx(key1, 1), newTx(key2, 0)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		gen.AddTx([]*types.Transaction{txA, txB, txC}[i])
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Create a heavier fork from genesis, including only the first transaction
+	replacementBlocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, func(i int, gen *BlockGen) {
+		if i == 1 {
+			gen.AddTx(txA)
+		}
+		if i == 2 {
+			gen.OffsetTime(-9)
+		}
+	})
+	reorgCh := make(chan ChainReorgEvent, 8)
+	sub := blockchain.SubscribeChainReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(replacementBlocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var ev ChainReorgEvent
+	select {
+	case ev = <-reorgCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for reorg event")
+	}
+	if ev.Ancestor.Hash() != genesis.Hash() {
+		t.Errorf("ancestor mismatch: have #%d [%x], want genesis", ev.Ancestor.Number, ev.Ancestor.Hash())
+	}
+	checkBlocks := func(kind string, have, want types.Blocks) {
+		if len(have) != len(want) {
+			t.Fatalf("%s block count mismatch: have %d, want %d", kind, len(have), len(want))
+		}
+		for i := range have {
+			if have[i].Hash() != want[i].Hash() {
+				t.Errorf("%s block %d mismatch: have %x, want %x", kind, i, have[i].Hash(), want[i].Hash())
+			}
+		}
+	}
+	checkBlocks("dropped", ev.Dropped, chain)
+	checkBlocks("added", ev.Added, replacementBlocks[:3])
+
+	if len(ev.DroppedTxs) != 2 || ev.DroppedTxs[0].Hash() != txB.Hash() || ev.DroppedTxs[1].Hash() != txC.Hash() {
+		t.Errorf("dropped transactions mismatch: have %v", ev.DroppedTxs)
+	}
+	if len(ev.ReincludedTxs) != 1 || ev.ReincludedTxs[0].Hash() != txA.Hash() {
+		t.Errorf("reincluded transactions mismatch: have %v", ev.ReincludedTxs)
+	}
+	// Extending the new chain is not a reorg
+	select {
+	case ev := <-reorgCh:
+		t.Errorf("unexpected reorg event: %d dropped, %d added", len(ev.Dropped), len(ev.Added))
+	case <-time.After(250 * time.Millisecond):
+	}
+	if reorgs := blockchain.Reorgs(); len(reorgs) != 1 || reorgs[0].Ancestor.Hash() != genesis.Hash() {
+		t.Errorf("reorg history mismatch: have %d entries", len(reorgs))
+	}
+}
+
+// Tests that the reorg events are posted along with the chain events of the
+// insertion causing them, in the order the reorgs happened.
+func TestReorgEventOrdering(t *testing.T) {
+	var (
+		db, _   = lbchain-devdb.NewMemDatabase()
+		genesis = new(Genesis).MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	blockchain, _ := NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	defer blockchain.Stop()
+
+	// Create three competing forks from genesis, each heavier than the previous
+	var forks []types.Blocks
+	for i := 0; i < 3; i++ {
+		fork, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2+i, func(j int, gen *BlockGen) {
+			gen.SetCoinbase(common.Address{byte(i + 1)})
+		})
+		forks = append(forks, fork)
+	}
+	if _, err := blockchain.InsertChain(forks[0]); err != nil {
+		t.Fatalf("failed to insert first fork: %v", err)
+	}
+	reorgCh := make(chan ChainReorgEvent, 8)
+	sub := blockchain.SubscribeChainReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	// Every reorg must be announced by the time its insertion returns
+	for i := 1; i < len(forks); i++ {
+		if _, err := blockchain.InsertChain(forks[i]); err != nil {
+			t.Fatalf("failed to insert fork %d: %v", i, err)
+		}
+		select {
+		case ev := <-reorgCh:
+			if ev.Dropped[0].Hash() != forks[i-1][0].Hash() || ev.Added[0].Hash() != forks[i][0].Hash() {
+				t.Errorf("fork %d: reorg event mismatch: dropped %x, added %x", i, ev.Dropped[0].Hash(), ev.Added[0].Hash())
+			}
+		default:
+			t.Fatalf("fork %d: reorg event not posted with the insertion", i)
+		}
+	}
+	select {
+	case ev := <-reorgCh:
+		t.Errorf("unexpected reorg event: %d dropped, %d added", len(ev.Dropped), len(ev.Added))
+	default:
+	}
+}
+
 // Tests if the canonical block can be fetched from the database during chain insertion.
 func TestCanonicalBlockRetrieval(t *testing.T) {
 	_, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
@@ -1338,3 +1519,75 @@ func TestLargeReorgTrieGC(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the state diffs of the imported blocks are recorded if enabled, with
+// the block rewards attributed to the block itself instead of its last transaction.
+func TestStateDiffRecording(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.NewEIP155Signer(params.TestChainConfig.ChainId)
+		engine  = ethash.NewFaker()
+		db, _   = lbchain-devdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0xcb})
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{byte(j + 1)}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	// Import the chain both with and without diff recording
+	for _, enabled := range []bool{false, true} {
+		diskdb, _ := lbchain-devdb.NewMemDatabase()
+		gspec.MustCommit(diskdb)
+
+		chain, _ := NewBlockChain(diskdb, &CacheConfig{TrieCleanLimit: 256, TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, StateDiffs: enabled}, gspec.Config, engine, vm.Config{})
+		if n, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("enabled %v: failed to insert block %d: %v", enabled, n, err)
+		}
+		for i, block := range blocks {
+			diffs := chain.GetStateDiffs(block.Hash())
+			if !enabled {
+				if diffs != nil {
+					t.Errorf("block %d: diffs recorded while disabled", i)
+				}
+				continue
+			}
+			if len(diffs) != 3 {
+				t.Fatalf("block %d: diff count mismatch: have %d, want 3", i, len(diffs))
+			}
+			for j, tx := range block.Transactions() {
+				if diffs[j].TxHash != tx.Hash() || diffs[j].TxIndex != j {
+					t.Errorf("block %d, tx %d: attribution mismatch: have %x/%d, want %x/%d", i, j, diffs[j].TxHash, diffs[j].TxIndex, tx.Hash(), j)
+				}
+				if diffs[j].Diff[common.Address{byte(j + 1)}] == nil {
+					t.Errorf("block %d, tx %d: recipient change missing", i, j)
+				}
+			}
+			if diffs[2].TxHash != (common.Hash{}) || diffs[2].TxIndex != 2 {
+				t.Errorf("block %d: finalisation attribution mismatch: have %x/%d", i, diffs[2].TxHash, diffs[2].TxIndex)
+			}
+			if len(diffs[2].Diff) != 1 || diffs[2].Diff[common.Address{0xcb}] == nil {
+				t.Errorf("block %d: finalisation should only reward the coinbase", i)
+			}
+			// The recorded diffs must match the ones of a reexecution
+			parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+			statedb, _ := state.New(parent.Root(), chain.stateCache)
+			statedb.EnableDiffs()
+			if _, _, _, err := chain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+				t.Fatalf("block %d: failed to reexecute: %v", i, err)
+			}
+			statedb.IntermediateRoot(true)
+
+			have, _ := json.Marshal(diffs)
+			want, _ := json.Marshal(statedb.Diffs())
+			if !bytes.Equal(have, want) {
+				t.Errorf("block %d: recorded diffs mismatch:\nhave %s\nwant %s", i, have, want)
+			}
+		}
+		chain.Stop()
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+)
+
+// errNotIterable is returned if a database inspection is attempted on a store
+// that can't iterate over its keys.
+var errNotIterable = errors.New("database not iterable")
+
+// Database categories reported by InspectDatabase.
+const (
+	statHeaders       = "Headers"
+	statBodies        = "Bodies"
+	statReceipts      = "Receipts"
+	statDifficulties  = "Difficulties"
+	statCanonical     = "Canonical hashes"
+	statNumbers       = "Header numbers"
+	statLookups       = "Transaction lookups"
+	statBloomBits     = "Bloom bits"
+	statBadBlocks     = "Bad blocks"
+	statTrieNodes     = "Trie nodes and contract codes"
+	statPreimages     = "Trie preimages"
+	statSnapAccounts  = "Snapshot accounts"
+	statSnapStorage   = "Snapshot storage"
+	statIndexes       = "Chain indexes"
+	statLegacy        = "Legacy receipts and lookups"
+	statMetadata      = "Metadata"
+	statUnaccounted   = "Unaccounted"
+	statAncientPrefix = "Ancient "
+)
+
+// lightIndexPrefixes are the prefixes of the light client indexes, defined in
+// the light package which can't be imported from here.
+var lightIndexPrefixes = [][]byte{[]byte("chtRoot-"), []byte("chtIndex-"), []byte("bltRoot-"), []byte("bltIndex-")}
+
+// DatabaseStat is the number of entries and their total size in one category
+// of a chain database.
+type DatabaseStat struct {
+	Category string
+	Count    uint64
+	Size     common.StorageSize
+}
+
+// InspectDatabase iterates over every key of a persistent chain database and
+// classifies it by the database schema, returning the number of entries and
+// their total size in each category. If the database has an ancient store, the
+// sizes of its tables are reported too.
+func InspectDatabase(db lbchain-devdb.Database) ([]DatabaseStat, error) {
+	ldb, ok := KeyValueStore(db).(*lbchain-devdb.LDBDatabase)
+	if !ok {
+		return nil, errNotIterable
+	}
+	var (
+		stats  = make(map[string]*DatabaseStat)
+		start  = time.Now()
+		logged = time.Now()
+		count  uint64
+	)
+	it := ldb.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		key, size := it.Key(), common.StorageSize(len(it.Key())+len(it.Value()))
+
+		category := classifyKey(key)
+		stat, ok := stats[category]
+		if !ok {
+			stat = &DatabaseStat{Category: category}
+			stats[category] = stat
+		}
+		stat.Count++
+		stat.Size += size
+
+		if count++; time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "keys", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	result := make([]DatabaseStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Size > result[j].Size })
+
+	// Append the ancient tables after the key-value categories
+	if frdb, ok := db.(*freezerdb); ok {
+		names := make([]string, 0, len(frdb.tables))
+		for name := range frdb.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items, size := frdb.tables[name].stats()
+			result = append(result, DatabaseStat{Category: statAncientPrefix + name, Count: items, Size: common.StorageSize(size)})
+		}
+	}
+	log.Info("Inspected database", "keys", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return result, nil
+}
+
+// classifyKey maps a database key to the category of data it holds, based on
+// its prefix and length.
+func classifyKey(key []byte) string {
+	switch {
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+		return statHeaders
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength+len(tdSuffix) && bytes.HasSuffix(key, tdSuffix):
+		return statDifficulties
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(numSuffix) && bytes.HasSuffix(key, numSuffix):
+		return statCanonical
+	case bytes.HasPrefix(key, blockHashPrefix) && len(key) == len(blockHashPrefix)+common.HashLength:
+		return statNumbers
+	case bytes.HasPrefix(key, bodyPrefix) && len(key) == len(bodyPrefix)+8+common.HashLength:
+		return statBodies
+	case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+		return statReceipts
+	case bytes.HasPrefix(key, lookupPrefix) && len(key) == len(lookupPrefix)+common.HashLength:
+		return statLookups
+	case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+2+8+common.HashLength:
+		return statBloomBits
+	case bytes.HasPrefix(key, badBlockPrefix) && len(key) == len(badBlockPrefix)+common.HashLength:
+		return statBadBlocks
+	case bytes.HasPrefix(key, snapshot.AccountPrefix) && len(key) == len(snapshot.AccountPrefix)+common.HashLength:
+		return statSnapAccounts
+	case bytes.HasPrefix(key, snapshot.StoragePrefix) && len(key) == len(snapshot.StoragePrefix)+2*common.HashLength:
+		return statSnapStorage
+	case bytes.HasPrefix(key, []byte(preimagePrefix)) && len(key) == len(preimagePrefix)+common.HashLength:
+		return statPreimages
+	case len(key) == common.HashLength:
+		return statTrieNodes
+	case bytes.HasPrefix(key, BloomBitsIndexPrefix):
+		return statIndexes
+	case bytes.HasPrefix(key, oldReceiptsPrefix) || (len(key) == common.HashLength+len(oldTxMetaSuffix) && bytes.HasSuffix(key, oldTxMetaSuffix)):
+		return statLegacy
+	case bytes.HasPrefix(key, configPrefix):
+		return statMetadata
+	}
+	for _, prefix := range lightIndexPrefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return statIndexes
+		}
+	}
+	for _, meta := range [][]byte{headHeaderKey, headBlockKey, headFastKey, trieSyncKey, badBlockIndexKey} {
+		if bytes.Equal(key, meta) {
+			return statMetadata
+		}
+	}
+	// Singleton keys of other subsystems are short ASCII names, anything else
+	// is unknown to the schema
+	if len(key) < common.HashLength && isPrintable(key) {
+		return statMetadata
+	}
+	return statUnaccounted
+}
+
+// isPrintable reports whlbchain-dever a key consists only of printable ASCII characters.
+func isPrintable(key []byte) bool {
+	for _, c := range key {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
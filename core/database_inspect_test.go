@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+)
+
+// Tests that database inspection classifies the entries written through the
+// schema accessors into the correct categories.
+func TestInspectDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inspect-database")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := lbchain-devdb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Write a few blocks with all their metadata into the database
+	for i := uint64(1); i <= 3; i++ {
+		tx := types.NewTransaction(i, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(i)}, []*types.Transaction{tx}, nil, nil)
+
+		WriteBlock(db, block)
+		WriteTd(db, block.Hash(), i, big.NewInt(int64(i)))
+		WriteCanonicalHash(db, block.Hash(), i)
+		WriteBlockReceipts(db, block.Hash(), i, nil)
+		WriteTxLookupEntries(db, block)
+		WriteHeadBlockHash(db, block.Hash())
+	}
+	WriteBloomBits(db, 0, 0, common.Hash{0x01}, []byte{0x01})
+	WritePreimages(db, 0, map[common.Hash][]byte{crypto.Keccak256Hash([]byte{0x01}): {0x01}})
+	WriteBadBlock(db, &BadBlock{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(4)})}, badBlockStoreLimit)
+
+	db.Put(crypto.Keccak256([]byte{0x02}), []byte{0x02})
+	db.Put(append(common.CopyBytes(snapshot.AccountPrefix), common.Hash{0x03}.Bytes()...), []byte{0x03})
+	db.Put([]byte{0xff, 0xff}, []byte{0x04})
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	have := make(map[string]uint64)
+	for _, stat := range stats {
+		have[stat.Category] = stat.Count
+		if stat.Size == 0 {
+			t.Errorf("category %q: zero size", stat.Category)
+		}
+	}
+	want := map[string]uint64{
+		statHeaders:      3,
+		statBodies:       3,
+		statDifficulties: 3,
+		statCanonical:    3,
+		statNumbers:      3,
+		statReceipts:     3,
+		statLookups:      3,
+		statBloomBits:    1,
+		statBadBlocks:    1,
+		statPreimages:    1,
+		statTrieNodes:    1,
+		statSnapAccounts: 1,
+		statMetadata:     2,
+		statUnaccounted:  1,
+	}
+	for category, count := range want {
+		if have[category] != count {
+			t.Errorf("category %q: item count mismatch: have %d, want %d", category, have[category], count)
+		}
+	}
+	if len(have) != len(want) {
+		t.Errorf("category count mismatch: have %v, want %v", have, want)
+	}
+	// Non-iterable databases should be rejected
+	memdb, _ := lbchain-devdb.NewMemDatabase()
+	if _, err := InspectDatabase(memdb); err != errNotIterable {
+		t.Errorf("memory database inspection error mismatch: have %v, want %v", err, errNotIterable)
+	}
+}
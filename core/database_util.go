@@ -44,10 +44,11 @@ type DatabaseDeleter interface {
 }
 
 var (
-	headHeaderKey = []byte("LastHeader")
-	headBlockKey  = []byte("LastBlock")
-	headFastKey   = []byte("LastFast")
-	trieSyncKey   = []byte("TrieSync")
+	headHeaderKey    = []byte("LastHeader")
+	headBlockKey     = []byte("LastBlock")
+	headFastKey      = []byte("LastFast")
+	trieSyncKey      = []byte("TrieSync")
+	badBlockIndexKey = []byte("InvalidBlocks") // badBlockIndexKey -> RLP list of the hashes of the most recent bad blocks
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
@@ -58,6 +59,7 @@ var (
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 	lookupPrefix        = []byte("l") // lookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix     = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	badBlockPrefix      = []byte("x") // badBlockPrefix + hash -> bad block
 
 	preimagePrefix = "secure-key-"              // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("lbchain-devereum-config-") // config prefix for the db
@@ -161,9 +163,20 @@ func GetTrieSyncProgress(db DatabaseReader) uint64 {
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
+// HasHeader verifies the existence of a block header corresponding to the hash.
+func HasHeader(db lbchain-devdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(headerKey(hash, number)); ok {
+		return true
+	}
+	return len(getAncient(db, freezerHashTable, hash, number)) > 0
+}
+
 // GetHeader retrieves the block header corresponding to the hash, nil if none
 // found.
 func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header {
@@ -182,9 +195,20 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, freezerBodiesTable, hash, number)
+	}
 	return data
 }
 
+// HasBody verifies the existence of a block body corresponding to the hash.
+func HasBody(db lbchain-devdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(blockBodyKey(hash, number)); ok {
+		return true
+	}
+	return len(getAncient(db, freezerHashTable, hash, number)) > 0
+}
+
 func headerKey(hash common.Hash, number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
@@ -193,6 +217,14 @@ func blockBodyKey(hash common.Hash, number uint64) []byte {
 	return append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func blockReceiptsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+func tdKey(hash common.Hash, number uint64) []byte {
+	return append(headerKey(hash, number), tdSuffix...)
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash, nil if none found.
 func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
@@ -211,7 +243,10 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data, _ := db.Get(tdKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, freezerDifficultyTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -246,7 +281,10 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data, _ := db.Get(blockReceiptsKey(hash, number))
+	if len(data) == 0 {
+		data = getAncient(db, freezerReceiptTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -524,6 +562,12 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
 }
 
+// deleteHeaderWithoutNumber removes only the block header, keeping the hash to
+// number mapping in place (e.g. after moving the header into the ancient store).
+func deleteHeaderWithoutNumber(db DatabaseDeleter, hash common.Hash, number uint64) {
+	db.Delete(headerKey(hash, number))
+}
+
 // DeleteBody removes all block body data associated with a hash.
 func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
@@ -650,3 +694,92 @@ func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	}
 	return a
 }
+
+// BadBlock is a block rejected during import, stored along with the reason of
+// the rejection and the peer it originated from.
+type BadBlock struct {
+	Block  *types.Block
+	Reason string // Validation error the block was rejected with
+	Peer   string // Identifier of the peer the block originated from, empty if unknown
+	Time   uint64 // Unix timestamp of the rejection
+}
+
+func badBlockKey(hash common.Hash) []byte {
+	return append(append([]byte{}, badBlockPrefix...), hash.Bytes()...)
+}
+
+// getBadBlockIndex retrieves the hashes of the bad blocks stored in the database,
+// most recent first.
+func getBadBlockIndex(db DatabaseReader) []common.Hash {
+	data, _ := db.Get(badBlockIndexKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var hashes []common.Hash
+	if err := rlp.DecodeBytes(data, &hashes); err != nil {
+		log.Error("Invalid bad block index RLP", "err", err)
+		return nil
+	}
+	return hashes
+}
+
+// GetBadBlocks retrieves the bad blocks stored in the database, most recent first.
+func GetBadBlocks(db DatabaseReader) []*BadBlock {
+	var blocks []*BadBlock
+	for _, hash := range getBadBlockIndex(db) {
+		if bad := GetBadBlock(db, hash); bad != nil {
+			blocks = append(blocks, bad)
+		}
+	}
+	return blocks
+}
+
+// GetBadBlock retrieves a single bad block from the database by hash.
+func GetBadBlock(db DatabaseReader, hash common.Hash) *BadBlock {
+	data, _ := db.Get(badBlockKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	bad := new(BadBlock)
+	if err := rlp.DecodeBytes(data, bad); err != nil {
+		log.Error("Invalid bad block RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return bad
+}
+
+// WriteBadBlock stores a bad block in the database, keeping at most limit of the
+// most recent ones. Storing an already known block only refreshes its entry.
+func WriteBadBlock(db lbchain-devdb.Database, bad *BadBlock, limit int) error {
+	data, err := rlp.EncodeToBytes(bad)
+	if err != nil {
+		return err
+	}
+	hash := bad.Block.Hash()
+	if err := db.Put(badBlockKey(hash), data); err != nil {
+		return err
+	}
+	// Move the block to the front of the index, evicting the oldest ones
+	hashes, evicted := []common.Hash{hash}, []common.Hash{}
+	for _, old := range getBadBlockIndex(db) {
+		switch {
+		case old == hash:
+		case len(hashes) >= limit:
+			evicted = append(evicted, old)
+		default:
+			hashes = append(hashes, old)
+		}
+	}
+	if data, err = rlp.EncodeToBytes(hashes); err != nil {
+		return err
+	}
+	if err := db.Put(badBlockIndexKey, data); err != nil {
+		return err
+	}
+	for _, old := range evicted {
+		if err := db.Delete(badBlockKey(old)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
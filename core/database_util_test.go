@@ -386,3 +386,45 @@ func TestBlockReceiplbchain-devorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that bad blocks are stored most recent first, deduplicated and capped,
+// with the evicted ones removed from the database.
+func TestBadBlockStorage(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+
+	if blocks := GetBadBlocks(db); len(blocks) != 0 {
+		t.Fatalf("non existent bad blocks returned: %v", blocks)
+	}
+	blocks := make([]*types.Block, 4)
+	for i := range blocks {
+		blocks[i] = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), Extra: []byte("bad block")})
+		if err := WriteBadBlock(db, &BadBlock{Block: blocks[i], Reason: "invalid", Peer: "peer", Time: uint64(i)}, 3); err != nil {
+			t.Fatalf("failed to write bad block #%d: %v", i, err)
+		}
+	}
+	// Rewriting a known block should move it to the front without duplicating it
+	if err := WriteBadBlock(db, &BadBlock{Block: blocks[2], Reason: "again", Time: 4}, 3); err != nil {
+		t.Fatalf("failed to rewrite bad block: %v", err)
+	}
+	stored := GetBadBlocks(db)
+	if len(stored) != 3 {
+		t.Fatalf("bad block count mismatch: have %d, want %d", len(stored), 3)
+	}
+	for i, want := range []int{2, 3, 1} {
+		if hash := stored[i].Block.Hash(); hash != blocks[want].Hash() {
+			t.Errorf("bad block %d: hash mismatch: have %x, want %x", i, hash, blocks[want].Hash())
+		}
+	}
+	if bad := GetBadBlock(db, blocks[2].Hash()); bad == nil || bad.Reason != "again" || bad.Peer != "" || bad.Time != 4 {
+		t.Errorf("rewritten bad block mismatch: %+v", bad)
+	}
+	if bad := GetBadBlock(db, blocks[3].Hash()); bad == nil || bad.Reason != "invalid" || bad.Peer != "peer" || bad.Time != 3 {
+		t.Errorf("bad block mismatch: %+v", bad)
+	}
+	if bad := GetBadBlock(db, blocks[0].Hash()); bad != nil {
+		t.Errorf("evicted bad block returned: %+v", bad)
+	}
+	if ok, _ := db.Has(badBlockKey(blocks[0].Hash())); ok {
+		t.Errorf("evicted bad block not deleted")
+	}
+}
@@ -52,3 +52,12 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainReorgEvent is posted when the canonical chain is reorganised onto a fork.
+type ChainReorgEvent struct {
+	Ancestor      *types.Header      // Common ancestor of the old and new chains
+	Dropped       types.Blocks       // Blocks removed from the canonical chain, oldest first
+	Added         types.Blocks       // Blocks added to the canonical chain, oldest first
+	DroppedTxs    types.Transactions // Transactions of the dropped blocks not in the added ones
+	ReincludedTxs types.Transactions // Transactions of the dropped blocks also in the added ones
+}
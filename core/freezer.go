@@ -0,0 +1,449 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+const (
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+
+	// DefaultAncientThreshold is the number of recent blocks kept in the key-value
+	// store before being moved into the ancient store. Reorgs deeper than this are
+	// not supported once the blocks have been frozen.
+	DefaultAncientThreshold = 90000
+)
+
+// freezerNoSnappy configures whlbchain-dever compression is disabled for the ancient
+// tables. Hashes and difficulties don't compress well.
+var freezerNoSnappy = map[string]bool{
+	freezerHeaderTable:     false,
+	freezerHashTable:       true,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	freezeMeter = metrics.NewRegisteredMeter("chain/ancient/frozen", nil)
+)
+
+// AncientReader wraps the read access to the append-only store of old canonical
+// chain data.
+type AncientReader interface {
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks frozen in the ancient store.
+	Ancients() uint64
+}
+
+// AncientStore extends the ancient reader with the ability to discard frozen data
+// when the chain is rewound.
+type AncientStore interface {
+	AncientReader
+
+	// TruncateAncients discards all but the first n frozen blocks.
+	TruncateAncients(n uint64) error
+}
+
+// freezer is an append-only database to store immutable chain data
+// into flat files:
+//
+// - The append only nature ensures that disk writes are minimized.
+// - Old chain segments can be moved onto cheaper disks than the key-value store.
+type freezer struct {
+	frozen    uint64 // Number of blocks already frozen (atomically accessed)
+	threshold uint64 // Number of recent blocks to keep in the key-value store
+
+	tables       map[string]*freezerTable // Data tables for storing everything
+	instanceLock flock.Releaser           // File-system lock to prevent double opens
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into append
+// only flat file containers.
+func newFreezer(datadir string, threshold uint64) (*freezer, error) {
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return nil, err
+	}
+	lock, _, err := flock.New(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return nil, err
+	}
+	f := &freezer{
+		threshold:    threshold,
+		tables:       make(map[string]*freezerTable),
+		instanceLock: lock,
+		quit:         make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newFreezerTable(datadir, name, disableSnappy)
+		if err != nil {
+			for _, table := range f.tables {
+				table.Close()
+			}
+			lock.Release()
+			return nil, err
+		}
+		f.tables[name] = table
+	}
+	if err := f.repair(); err != nil {
+		f.close()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "path", datadir, "frozen", f.frozen)
+	return f, nil
+}
+
+// repair truncates all data tables to the same length, dropping any block only
+// partially frozen before a crash.
+func (f *freezer) repair() error {
+	min := uint64(1<<64 - 1)
+	for _, table := range f.tables {
+		if items := table.Items(); items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the number of blocks frozen in the ancient store.
+func (f *freezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.frozen)
+}
+
+// appendAncient injects all the data of a single block into the freezer. The
+// block number must be the next one in sequence.
+func (f *freezer) appendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	// Rollback all tables to the starting position in case of error
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				table.truncate(number)
+			}
+		}
+	}()
+	blobs := map[string][]byte{
+		freezerHashTable:       hash,
+		freezerHeaderTable:     header,
+		freezerBodiesTable:     body,
+		freezerReceiptTable:    receipts,
+		freezerDifficultyTable: td,
+	}
+	for kind, blob := range blobs {
+		if err := f.tables[kind].Append(number, blob); err != nil {
+			return fmt.Errorf("failed to append block %d %s: %v", number, kind, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards all but the first n frozen blocks.
+func (f *freezer) TruncateAncients(items uint64) error {
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// sync flushes all data tables to disk.
+func (f *freezer) sync() error {
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close terminates the background freezer and closes all the data files.
+func (f *freezer) close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := f.instanceLock.Release(); err != nil {
+		errs = append(errs, err)
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+//
+// This functionality is deliberately broken off from block importing to avoid
+// incurring additional data shuffling delays on block propagation.
+func (f *freezer) freeze(db lbchain-devdb.Database) {
+	defer f.wg.Done()
+
+	backoff := false
+	for {
+		select {
+		case <-f.quit:
+			return
+		default:
+		}
+		if backoff {
+			select {
+			case <-time.NewTimer(freezerRecheckInterval).C:
+			case <-f.quit:
+				return
+			}
+		}
+		backoff = true
+
+		// Retrieve the freezing threshold, bailing if there's nothing old enough
+		hash := GetHeadBlockHash(db)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		number := GetBlockNumber(db, hash)
+		if number == missingNumber || number < f.threshold {
+			continue
+		}
+		limit := number - f.threshold
+		if limit <= f.Ancients() {
+			continue
+		}
+		if limit-f.Ancients() > freezerBatchLimit {
+			limit = f.Ancients() + freezerBatchLimit
+		}
+		// Move the ancient blocks out of the key-value store
+		var (
+			start    = time.Now()
+			first    = f.Ancients()
+			ancients = make([]common.Hash, 0, limit-first)
+		)
+		for f.Ancients() < limit {
+			number := f.Ancients()
+
+			hash := GetCanonicalHash(db, number)
+			if hash == (common.Hash{}) {
+				log.Error("Canonical hash missing, can't freeze", "number", number)
+				break
+			}
+			header, _ := db.Get(headerKey(hash, number))
+			if len(header) == 0 {
+				log.Error("Block header missing, can't freeze", "number", number, "hash", hash)
+				break
+			}
+			body, _ := db.Get(blockBodyKey(hash, number))
+			if len(body) == 0 {
+				log.Error("Block body missing, can't freeze", "number", number, "hash", hash)
+				break
+			}
+			receipts, _ := db.Get(blockReceiptsKey(hash, number))
+			if len(receipts) == 0 {
+				log.Error("Block receipts missing, can't freeze", "number", number, "hash", hash)
+				break
+			}
+			td, _ := db.Get(tdKey(hash, number))
+			if len(td) == 0 {
+				log.Error("Total difficulty missing, can't freeze", "number", number, "hash", hash)
+				break
+			}
+			if err := f.appendAncient(number, hash[:], header, body, receipts, td); err != nil {
+				log.Error("Failed to freeze block", "number", number, "hash", hash, "err", err)
+				break
+			}
+			ancients = append(ancients, hash)
+		}
+		// Batch of blocks have been frozen, flush them before wiping from the database.
+		// The hash to number mappings are retained to keep the frozen blocks
+		// retrievable by hash.
+		if err := f.sync(); err != nil {
+			log.Crit("Failed to flush frozen tables", "err", err)
+		}
+		for i, hash := range ancients {
+			number := first + uint64(i)
+
+			DeleteBody(db, hash, number)
+			DeleteBlockReceipts(db, hash, number)
+			deleteHeaderWithoutNumber(db, hash, number)
+			DeleteTd(db, hash, number)
+		}
+		freezeMeter.Mark(int64(len(ancients)))
+
+		context := []interface{}{
+			"blocks", len(ancients), "elapsed", common.PrettyDuration(time.Since(start)), "number", f.Ancients() - 1,
+		}
+		if n := len(ancients); n > 0 {
+			context = append(context, []interface{}{"hash", ancients[n-1]}...)
+		}
+		log.Info("Deep froze chain segment", context...)
+
+		// Avoid database thrashing with tiny writes
+		if len(ancients) == freezerBatchLimit {
+			backoff = false
+		}
+	}
+}
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	lbchain-devdb.Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer wraps a key-value database with an append-only ancient
+// store in the given directory, and starts moving canonical chain data older than
+// the threshold out of the key-value store in the background.
+func NewDatabaseWithFreezer(db lbchain-devdb.Database, freezer string, threshold uint64) (lbchain-devdb.Database, error) {
+	frdb, err := newFreezer(freezer, threshold)
+	if err != nil {
+		return nil, err
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// OpenDatabaseWithFreezer wraps a key-value database with the append-only ancient
+// store in the given directory, making the already frozen chain data accessible
+// without moving anything new out of the key-value store.
+func OpenDatabaseWithFreezer(db lbchain-devdb.Database, freezer string) (lbchain-devdb.Database, error) {
+	frdb, err := newFreezer(freezer, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// Close implements lbchain-devdb.Database, stopping the background freezer before
+// closing the key-value store.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// KeyValueStore returns the key-value database backing the given chain database,
+// stripping away any ancient store attached to it.
+func KeyValueStore(db lbchain-devdb.Database) lbchain-devdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// getAncient retrieves a frozen item of a canonical block if the database has
+// an ancient store attached, verifying that the block hash matches.
+func getAncient(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	ancients, ok := db.(AncientReader)
+	if !ok || number >= ancients.Ancients() {
+		return nil
+	}
+	stored, err := ancients.Ancient(freezerHashTable, number)
+	if err != nil || common.BytesToHash(stored) != hash {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
+	return data
+}
+
+// truncateAncients discards all frozen blocks above head, if the database has
+// an ancient store attached.
+func truncateAncients(db DatabaseReader, head uint64) {
+	if ancients, ok := db.(AncientStore); ok {
+		if err := ancients.TruncateAncients(head + 1); err != nil {
+			log.Crit("Failed to truncate ancient store", "head", head, "err", err)
+		}
+	}
+}
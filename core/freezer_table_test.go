@@ -0,0 +1,155 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFreezerBlob generates a deterministic blob for the given item number.
+func testFreezerBlob(item uint64) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("%d", item)), int(item%7)+1)
+}
+
+// Tests that blobs appended to a freezer table can be retrieved, both with and
+// without compression, also after reopening the table.
+func TestFreezerTableBasics(t *testing.T) {
+	for _, noSnappy := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		tab, err := newFreezerTable(dir, "test", noSnappy)
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		for i := uint64(0); i < 255; i++ {
+			if err := tab.Append(i, testFreezerBlob(i)); err != nil {
+				t.Fatalf("failed to append item %d: %v", i, err)
+			}
+		}
+		if err := tab.Append(300, []byte{0x01}); err != errOutOrderInsertion {
+			t.Errorf("out of order append error mismatch: have %v, want %v", err, errOutOrderInsertion)
+		}
+		tab.Close()
+
+		if tab, err = newFreezerTable(dir, "test", noSnappy); err != nil {
+			t.Fatalf("failed to reopen table: %v", err)
+		}
+		if items := tab.Items(); items != 255 {
+			t.Fatalf("item count mismatch: have %d, want %d", items, 255)
+		}
+		for i := uint64(0); i < 255; i++ {
+			blob, err := tab.Retrieve(i)
+			if err != nil {
+				t.Fatalf("failed to retrieve item %d: %v", i, err)
+			}
+			if !bytes.Equal(blob, testFreezerBlob(i)) {
+				t.Errorf("item %d: blob mismatch: have %x, want %x", i, blob, testFreezerBlob(i))
+			}
+		}
+		if _, err := tab.Retrieve(255); err != errOutOfBounds {
+			t.Errorf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		tab.Close()
+	}
+}
+
+// Tests that a freezer table with a data file shorter than what the index
+// references is repaired on open by dropping the dangling items.
+func TestFreezerTableRepairDanglingData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tab, err := newFreezerTable(dir, "test", true)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i := uint64(0); i < 10; i++ {
+		tab.Append(i, testFreezerBlob(i))
+	}
+	tab.Close()
+
+	// Crop the data file mid-way through the last item
+	path := filepath.Join(dir, "test.rdat")
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat data file: %v", err)
+	}
+	if err := os.Truncate(path, stat.Size()-1); err != nil {
+		t.Fatalf("failed to truncate data file: %v", err)
+	}
+	if tab, err = newFreezerTable(dir, "test", true); err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	defer tab.Close()
+
+	if items := tab.Items(); items != 9 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 9)
+	}
+	// Ensure the table can be appended to again after the repair
+	if err := tab.Append(9, testFreezerBlob(9)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, _ := tab.Retrieve(9); !bytes.Equal(blob, testFreezerBlob(9)) {
+		t.Errorf("blob mismatch after repair: have %x, want %x", blob, testFreezerBlob(9))
+	}
+}
+
+// Tests that truncating a freezer table discards all items beyond the limit.
+func TestFreezerTableTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tab, err := newFreezerTable(dir, "test", false)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	defer tab.Close()
+
+	for i := uint64(0); i < 20; i++ {
+		tab.Append(i, testFreezerBlob(i))
+	}
+	if err := tab.truncate(5); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+	if items := tab.Items(); items != 5 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 5)
+	}
+	if _, err := tab.Retrieve(5); err != errOutOfBounds {
+		t.Errorf("truncated item retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	if err := tab.Append(5, []byte{0xff}); err != nil {
+		t.Fatalf("failed to append after truncation: %v", err)
+	}
+	if blob, _ := tab.Retrieve(5); !bytes.Equal(blob, []byte{0xff}) {
+		t.Errorf("blob mismatch after truncation: have %x, want ff", blob)
+	}
+}
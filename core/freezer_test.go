@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+// Tests that old canonical blocks are moved into the ancient store, remaining
+// retrievable through the chain database accessors afterwards.
+func TestFreezerMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, err := lbchain-devdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	// Import a short chain into the plain key-value store, with a transaction in
+	// every block to have some receipts to freeze
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.NewEIP155Signer(params.TestChainConfig.ChainId)
+	)
+	gspec := &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{sender: {Balance: big.NewInt(1000000000)}}}
+	genesis := gspec.MustCommit(kvdb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), kvdb, 16, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(sender), common.Address{0xaa}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+	})
+
+	chain, _ := NewBlockChain(kvdb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// Attach an ancient store and wait for the old blocks to be frozen
+	db, err := NewDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"), 4)
+	if err != nil {
+		t.Fatalf("failed to create ancient store: %v", err)
+	}
+	defer db.Close()
+
+	ancients := db.(AncientReader)
+	for start := time.Now(); ancients.Ancients() < 12 || HasBody(kvdb, blocks[10].Hash(), 11); {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("ancient count mismatch: have %d, want %d", ancients.Ancients(), 12)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, block := range append([]*types.Block{genesis}, blocks...) {
+		hash, number := block.Hash(), block.NumberU64()
+
+		frozen := number < 12
+		if have := HasBody(kvdb, hash, number); have == frozen {
+			t.Errorf("block %d: key-value presence mismatch: have %v, want %v", number, have, !frozen)
+		}
+		if stored := GetBlock(db, hash, number); stored == nil || stored.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v, want %x", number, stored, hash)
+		}
+		if td := GetTd(db, hash, number); td == nil {
+			t.Errorf("block %d: total difficulty missing", number)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) {
+			t.Errorf("block %d: block reported missing", number)
+		}
+		// Frozen blocks should remain retrievable by hash only
+		if have := GetBlockNumber(db, hash); have != number {
+			t.Errorf("block %d: number mismatch: have %d, want %d", number, have, number)
+		}
+		if number > 0 {
+			if receipts := GetBlockReceipts(db, hash, number); len(receipts) != 1 || receipts[0].TxHash != block.Transactions()[0].Hash() {
+				t.Errorf("block %d: receipts mismatch: have %v", number, receipts)
+			}
+		}
+	}
+	chain, err = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	for _, block := range blocks[:11] {
+		if stored := chain.GetBlockByHash(block.Hash()); stored == nil || stored.Hash() != block.Hash() {
+			t.Errorf("block %d: frozen block by hash mismatch: have %v, want %x", block.NumberU64(), stored, block.Hash())
+		}
+	}
+	chain.Stop()
+
+	// Rewinding the chain should drop the frozen blocks above the new head
+	truncateAncients(db, 5)
+	if have := ancients.Ancients(); have != 6 {
+		t.Errorf("truncated ancient count mismatch: have %d, want %d", have, 6)
+	}
+}
+
+// Tests that opening an ancient store for access only doesn't move any blocks
+// out of the key-value store.
+func TestFreezerOpenOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb, err := lbchain-devdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	gspec := &Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(kvdb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), kvdb, 16, nil)
+
+	chain, _ := NewBlockChain(kvdb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	db, err := OpenDatabaseWithFreezer(kvdb, filepath.Join(dir, "ancient"))
+	if err != nil {
+		t.Fatalf("failed to open ancient store: %v", err)
+	}
+	defer db.Close()
+
+	// Give a background freezer the chance to run, ensuring nothing was moved
+	time.Sleep(100 * time.Millisecond)
+	if have := db.(AncientReader).Ancients(); have != 0 {
+		t.Errorf("ancient count mismatch: have %d, want %d", have, 0)
+	}
+	for _, block := range blocks {
+		if !HasBody(kvdb, block.Hash(), block.NumberU64()) {
+			t.Errorf("block %d: moved out of the key-value store", block.NumberU64())
+		}
+	}
+}
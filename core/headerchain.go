@@ -368,8 +368,7 @@ func (hc *HeaderChain) HasHeader(hash common.Hash, number uint64) bool {
 	if hc.numberCache.Contains(hash) || hc.headerCache.Contains(hash) {
 		return true
 	}
-	ok, _ := hc.chainDb.Has(headerKey(hash, number))
-	return ok
+	return HasHeader(hc.chainDb, hash, number)
 }
 
 // GetHeaderByNumber retrieves a block header from the database by number,
@@ -420,10 +419,11 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 		DeleteTd(hc.chainDb, hash, num)
 		hc.currentHeader.Store(hc.GetHeader(hdr.ParentHash, hdr.Number.Uint64()-1))
 	}
-	// Roll back the canonical chain numbering
+	// Roll back the canonical chain numbering and any frozen blocks above the head
 	for i := height; i > head; i-- {
 		DeleteCanonicalHash(hc.chainDb, i)
 	}
+	truncateAncients(hc.chainDb, head)
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
 	hc.tdCache.Purge()
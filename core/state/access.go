@@ -0,0 +1,234 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+)
+
+// AccessKind is the part of an account a state access refers to.
+type AccessKind uint8
+
+const (
+	AccessExist   AccessKind = iota // Existence (and self-destruction) of the account
+	AccessBalance                   // Balance of the account
+	AccessNonce                     // Nonce of the account
+	AccessCode                      // Code of the account
+	AccessStorage                   // A single storage slot of the account
+)
+
+// AccessKey identifies a single piece of state read or written by a transaction.
+type AccessKey struct {
+	Address common.Address
+	Kind    AccessKind
+	Slot    common.Hash // Storage slot, only set for AccessStorage
+}
+
+// Access is the set of state read and written by a transaction, along with the
+// changes it made. It is collected from a state on which only that transaction
+// was executed, allowing the changes to be replayed on a different state as long
+// as none of the read values differ there.
+type Access struct {
+	Reads  map[AccessKey]struct{} // State read by the transaction
+	Writes map[AccessKey]struct{} // State modified by the transaction
+
+	replayable bool
+	accounts   []*accountAccess // Modified accounts, in order of first modification
+	logs       []*types.Log
+	preimages  map[common.Hash][]byte
+}
+
+// accountAccess is the set of changes made to a single account.
+type accountAccess struct {
+	address common.Address
+	balance *big.Int // New balance if it was read, otherwise the balance change
+	blind   bool     // Whlbchain-dever the balance was modified without being read
+	touched bool     // Whlbchain-dever the account was touched without changing the balance
+	nonce   *uint64
+	code    []byte
+	codeSet bool
+	storage map[common.Hash]common.Hash
+}
+
+// TrackReads starts recording the state read through the accessor methods, to
+// be reported by Access. Any previously recorded reads are discarded.
+func (self *StateDB) TrackReads() {
+	self.reads = make(map[AccessKey]struct{})
+}
+
+// read records a state read, if tracking is enabled.
+func (self *StateDB) read(addr common.Address, kinds ...AccessKind) {
+	if self.reads == nil {
+		return
+	}
+	for _, kind := range kinds {
+		self.reads[AccessKey{Address: addr, Kind: kind}] = struct{}{}
+	}
+}
+
+// Access returns the state read and written since the last finalisation and
+// stops tracking reads. Only the reads recorded since the last call to
+// TrackReads are reported. It must be called before the state is finalised.
+func (self *StateDB) Access() *Access {
+	access := &Access{
+		Reads:      make(map[AccessKey]struct{}, len(self.reads)),
+		Writes:     make(map[AccessKey]struct{}),
+		replayable: true,
+		logs:       self.logs[self.thash],
+		preimages:  make(map[common.Hash][]byte),
+	}
+	for key := range self.reads {
+		access.Reads[key] = struct{}{}
+	}
+	self.reads = nil
+
+	var (
+		accounts = make(map[common.Address]*accountAccess)
+		origins  = make(map[common.Address]*big.Int) // Balances before the first change
+		created  = make(map[common.Address]bool)     // Accounts created or overwritten
+	)
+	account := func(addr common.Address) *accountAccess {
+		if _, ok := accounts[addr]; !ok {
+			accounts[addr] = &accountAccess{address: addr, storage: make(map[common.Hash]common.Hash)}
+			access.accounts = append(access.accounts, accounts[addr])
+		}
+		return accounts[addr]
+	}
+	for _, entry := range self.journal {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			account(*ch.account)
+			created[*ch.account] = true
+			if _, ok := origins[*ch.account]; !ok {
+				origins[*ch.account] = new(big.Int)
+			}
+		case resetObjectChange:
+			// Wiping an account can't be expressed as individual field changes
+			account(ch.prev.address)
+			created[ch.prev.address] = true
+			access.replayable = false
+		case suicideChange:
+			account(*ch.account)
+			access.replayable = false
+		case balanceChange:
+			account(*ch.account)
+			if _, ok := origins[*ch.account]; !ok {
+				origins[*ch.account] = ch.prev
+			}
+		case nonceChange:
+			account(*ch.account).nonce = new(uint64)
+		case codeChange:
+			account(*ch.account).codeSet = true
+		case storageChange:
+			account(*ch.account).storage[ch.key] = common.Hash{}
+		case touchChange:
+			account(*ch.account).touched = true
+		case addPreimageChange:
+			access.preimages[ch.hash] = self.preimages[ch.hash]
+		}
+	}
+	// Touching the RIPEMD precompile survives reverts without leaving a journal
+	// entry behind (see touchChange), so it can't be replayed
+	if _, dirty := self.stateObjectsDirty[ripemd]; dirty && accounts[ripemd] == nil {
+		access.replayable = false
+	}
+	// Gather the new values of all the modified fields
+	for _, acc := range access.accounts {
+		obj := self.stateObjects[acc.address]
+		if obj == nil {
+			access.replayable = false
+			continue
+		}
+		addr := acc.address
+		if origin, ok := origins[addr]; ok {
+			access.Writes[AccessKey{Address: addr, Kind: AccessBalance}] = struct{}{}
+			if _, read := access.Reads[AccessKey{Address: addr, Kind: AccessBalance}]; read {
+				acc.balance = new(big.Int).Set(obj.Balance())
+			} else {
+				acc.balance, acc.blind = new(big.Int).Sub(obj.Balance(), origin), true
+			}
+		}
+		if acc.nonce != nil {
+			*acc.nonce = obj.Nonce()
+			access.Writes[AccessKey{Address: addr, Kind: AccessNonce}] = struct{}{}
+		}
+		if acc.codeSet {
+			acc.code = common.CopyBytes(obj.Code(self.db))
+			access.Writes[AccessKey{Address: addr, Kind: AccessCode}] = struct{}{}
+		}
+		for key := range acc.storage {
+			acc.storage[key] = obj.Gelbchain-devate(self.db, key)
+			access.Writes[AccessKey{Address: addr, Kind: AccessStorage, Slot: key}] = struct{}{}
+		}
+		// Creating the account, or deleting it as empty or self-destructed during
+		// finalisation affects all of its fields
+		if created[addr] || obj.suicided || obj.empty() {
+			for _, kind := range []AccessKind{AccessExist, AccessBalance, AccessNonce, AccessCode} {
+				access.Writes[AccessKey{Address: addr, Kind: kind}] = struct{}{}
+			}
+		}
+	}
+	return access
+}
+
+// Replayable reports whlbchain-dever the changes can be replayed on another state with
+// Apply. Transactions wiping accounts can only be reexecuted.
+func (a *Access) Replayable() bool {
+	return a.replayable
+}
+
+// Apply replays the changes on another state, which must be prepared for the
+// same transaction. The resulting state is equivalent to executing the
+// transaction on it, given that all the state it read is identical in both.
+func (a *Access) Apply(statedb *StateDB) {
+	for _, acc := range a.accounts {
+		switch {
+		case acc.balance == nil:
+			// Touch the account the same way the transaction did, if empty
+			if acc.touched {
+				statedb.AddBalance(acc.address, new(big.Int))
+			}
+		case acc.blind && acc.balance.Sign() == 0:
+			statedb.AddBalance(acc.address, new(big.Int))
+		case acc.blind && acc.balance.Sign() > 0:
+			statedb.AddBalance(acc.address, acc.balance)
+		case acc.blind:
+			statedb.SubBalance(acc.address, new(big.Int).Neg(acc.balance))
+		default:
+			statedb.SetBalance(acc.address, acc.balance)
+		}
+		if acc.nonce != nil {
+			statedb.SetNonce(acc.address, *acc.nonce)
+		}
+		if acc.codeSet {
+			statedb.SetCode(acc.address, acc.code)
+		}
+		for key, value := range acc.storage {
+			statedb.Selbchain-devate(acc.address, key, value)
+		}
+	}
+	for _, log := range a.logs {
+		cpy := *log
+		statedb.AddLog(&cpy)
+	}
+	for hash, preimage := range a.preimages {
+		statedb.AddPreimage(hash, preimage)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/trie"
 	lru "github.com/hashicorp/golang-lru"
@@ -76,13 +77,47 @@ type Trie interface {
 // intermediate trie-node memory pool between the low level storage layer and the
 // high level trie abstraction.
 func NewDatabase(db lbchain-devdb.Database) Database {
+	return NewDatabaseWithCache(db, 0)
+}
+
+// NewDatabaseWithCache creates a backing store for state. The returned database
+// is safe for concurrent use and retains both a few recent expanded trie nodes in
+// memory, as well as a size bounded cache (MB) of clean trie nodes read from or
+// flushed to disk.
+func NewDatabaseWithCache(db lbchain-devdb.Database, cache int) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
-		db:            trie.NewDatabase(db),
+		db:            trie.NewDatabaseWithCache(db, cache),
 		codeSizeCache: csc,
 	}
 }
 
+// NewDatabaseWithSnapshots wraps a state database, attaching a flat snapshot of
+// the state to it. States opened through the returned database consult the
+// snapshot for account and storage reads ahead of the tries, and push their
+// changes into it on commit.
+func NewDatabaseWithSnapshots(db Database, snaps *snapshot.Tree) Database {
+	return &snapshotDB{
+		Database: db,
+		snaps:    snaps,
+	}
+}
+
+// snapshotDB is a state database with a flat state snapshot attached.
+type snapshotDB struct {
+	Database
+	snaps *snapshot.Tree
+}
+
+// snapshots retrieves the flat state snapshot attached to a state database, or
+// nil if the database doesn't maintain one.
+func snapshots(db Database) *snapshot.Tree {
+	if db, ok := db.(*snapshotDB); ok {
+		return db.snaps
+	}
+	return nil
+}
+
 type cachingDB struct {
 	db            *trie.Database
 	mu            sync.Mutex
@@ -0,0 +1,303 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+)
+
+// StateDiff is the set of changes made to the state by a transaction or block,
+// keyed by the modified account.
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff is the set of changes made to a single account. Fields left nil
+// were not modified. Only the storage slots written to are tracked, so slots
+// wiped by a self-destruct or an account re-creation are not listed.
+type AccountDiff struct {
+	Created bool                         `json:"created,omitempty"` // Account was created, or re-created over an existing one
+	Deleted bool                         `json:"deleted,omitempty"` // Account was removed from the state
+	Balance *BalanceDiff                 `json:"balance,omitempty"`
+	Nonce   *NonceDiff                   `json:"nonce,omitempty"`
+	Code    *CodeDiff                    `json:"code,omitempty"`
+	Storage map[common.Hash]*StorageDiff `json:"storage,omitempty"`
+}
+
+// BalanceDiff is the original and the new balance of an account.
+type BalanceDiff struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// NonceDiff is the original and the new nonce of an account.
+type NonceDiff struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// CodeDiff is the original and the new code of an account.
+type CodeDiff struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StorageDiff is the original and the new value of a storage slot.
+type StorageDiff struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// TxStateDiff is the state diff of a single transaction. Changes made outside
+// of transactions (e.g. block rewards, hard-fork transitions) are recorded with
+// a zero transaction hash.
+type TxStateDiff struct {
+	TxHash  common.Hash `json:"txHash"`
+	TxIndex int         `json:"txIndex"`
+	Diff    StateDiff   `json:"diff"`
+}
+
+// Merge folds a subsequent diff into this one, keeping the earliest original
+// values and the latest new values. Changes reverted by the subsequent diff are
+// dropped.
+func (d StateDiff) Merge(next StateDiff) {
+	for addr, change := range next {
+		acc, ok := d[addr]
+		if !ok {
+			acc = new(AccountDiff)
+			d[addr] = acc
+		}
+		acc.Created = acc.Created || change.Created
+		acc.Deleted = change.Deleted
+		if change.Balance != nil {
+			if acc.Balance == nil {
+				acc.Balance = &BalanceDiff{From: change.Balance.From}
+			}
+			acc.Balance.To = change.Balance.To
+		}
+		if change.Nonce != nil {
+			if acc.Nonce == nil {
+				acc.Nonce = &NonceDiff{From: change.Nonce.From}
+			}
+			acc.Nonce.To = change.Nonce.To
+		}
+		if change.Code != nil {
+			if acc.Code == nil {
+				acc.Code = &CodeDiff{From: change.Code.From}
+			}
+			acc.Code.To = change.Code.To
+		}
+		for key, slot := range change.Storage {
+			if acc.Storage == nil {
+				acc.Storage = make(map[common.Hash]*StorageDiff)
+			}
+			if _, ok := acc.Storage[key]; !ok {
+				acc.Storage[key] = &StorageDiff{From: slot.From}
+			}
+			acc.Storage[key].To = slot.To
+		}
+		acc.prune()
+		if acc.empty() {
+			delete(d, addr)
+		}
+	}
+}
+
+// prune removes all the field changes that leave the value unmodified.
+func (acc *AccountDiff) prune() {
+	if acc.Balance != nil && (*big.Int)(acc.Balance.From).Cmp((*big.Int)(acc.Balance.To)) == 0 {
+		acc.Balance = nil
+	}
+	if acc.Nonce != nil && acc.Nonce.From == acc.Nonce.To {
+		acc.Nonce = nil
+	}
+	if acc.Code != nil && bytes.Equal(acc.Code.From, acc.Code.To) {
+		acc.Code = nil
+	}
+	for key, slot := range acc.Storage {
+		if slot.From == slot.To {
+			delete(acc.Storage, key)
+		}
+	}
+	if len(acc.Storage) == 0 {
+		acc.Storage = nil
+	}
+}
+
+// empty reports whlbchain-dever the account diff contains no changes at all.
+func (acc *AccountDiff) empty() bool {
+	return !acc.Created && !acc.Deleted && acc.Balance == nil && acc.Nonce == nil && acc.Code == nil && acc.Storage == nil
+}
+
+// diffOrigin is the original state of an account, as collected from the journal
+// of a transaction. Fields are nil if the journal holds no prior value for them.
+type diffOrigin struct {
+	fresh   bool // Account did not exist in the state
+	reset   bool // Account was overwritten by a new one
+	balance *big.Int
+	nonce   *uint64
+	code    []byte
+	codeSet bool
+	storage map[common.Hash]common.Hash
+}
+
+// EnableDiffs turns on the recording of the state changes made by each of the
+// subsequently executed transactions, retrievable via Diffs.
+func (self *StateDB) EnableDiffs() {
+	self.diffs = []*TxStateDiff{}
+	self.diffMark = len(self.journal)
+}
+
+// Diffs returns the state changes recorded since diff recording was enabled,
+// one entry for each transaction or other batch of changes, in execution order.
+func (self *StateDB) Diffs() []*TxStateDiff {
+	self.recordDiff(false)
+	return self.diffs
+}
+
+// recordDiff collects the changes made since the last finalisation into a new
+// state diff, comparing the original values tracked by the journal with the
+// current values. It must be called before the dirty objects are finalised.
+func (self *StateDB) recordDiff(deleteEmptyObjects bool) {
+	if self.diffs == nil {
+		return
+	}
+	// Only process the journal entries not yet recorded by a previous call
+	if self.diffMark > len(self.journal) {
+		self.diffMark = len(self.journal)
+	}
+	entries := self.journal[self.diffMark:]
+	if len(entries) == 0 {
+		return
+	}
+	self.diffMark = len(self.journal)
+
+	origins := make(map[common.Address]*diffOrigin)
+	origin := func(addr common.Address) *diffOrigin {
+		if _, ok := origins[addr]; !ok {
+			origins[addr] = &diffOrigin{storage: make(map[common.Hash]common.Hash)}
+		}
+		return origins[addr]
+	}
+	// The first journal entry of every field holds its value before the changes
+	for _, entry := range entries {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			o := origin(*ch.account)
+			if o.balance == nil && o.nonce == nil && !o.codeSet {
+				o.fresh = true
+				o.balance, o.nonce, o.code, o.codeSet = new(big.Int), new(uint64), nil, true
+			}
+		case resetObjectChange:
+			o := origin(ch.prev.address)
+			o.reset = true
+			if o.balance == nil {
+				o.balance = new(big.Int).Set(ch.prev.Balance())
+			}
+			if o.nonce == nil {
+				nonce := ch.prev.Nonce()
+				o.nonce = &nonce
+			}
+			if !o.codeSet {
+				o.code, o.codeSet = ch.prev.Code(self.db), true
+			}
+		case suicideChange:
+			if o := origin(*ch.account); o.balance == nil {
+				o.balance = new(big.Int).Set(ch.prevbalance)
+			}
+		case balanceChange:
+			if o := origin(*ch.account); o.balance == nil {
+				o.balance = new(big.Int).Set(ch.prev)
+			}
+		case nonceChange:
+			if o := origin(*ch.account); o.nonce == nil {
+				nonce := ch.prev
+				o.nonce = &nonce
+			}
+		case codeChange:
+			if o := origin(*ch.account); !o.codeSet {
+				o.code, o.codeSet = ch.prevcode, true
+			}
+		case storageChange:
+			o := origin(*ch.account)
+			if _, ok := o.storage[ch.key]; !ok {
+				o.storage[ch.key] = ch.prevalue
+			}
+		case touchChange:
+			origin(*ch.account)
+		}
+	}
+	// Compare the original values with the current ones
+	diff := make(StateDiff)
+	for addr, o := range origins {
+		obj := self.stateObjects[addr]
+		if obj == nil {
+			continue
+		}
+		var (
+			acc     = &AccountDiff{Created: o.fresh || o.reset}
+			deleted = obj.suicided || (deleteEmptyObjects && obj.empty())
+			code    = obj.Code(self.db)
+		)
+		// Fields not modified are originally the same as now
+		if o.balance == nil {
+			o.balance = obj.Balance()
+		}
+		if o.nonce == nil {
+			nonce := obj.Nonce()
+			o.nonce = &nonce
+		}
+		if !o.codeSet {
+			o.code = code
+		}
+		acc.Balance = &BalanceDiff{From: (*hexutil.Big)(new(big.Int).Set(o.balance)), To: (*hexutil.Big)(new(big.Int).Set(obj.Balance()))}
+		acc.Nonce = &NonceDiff{From: hexutil.Uint64(*o.nonce), To: hexutil.Uint64(obj.Nonce())}
+		acc.Code = &CodeDiff{From: common.CopyBytes(o.code), To: common.CopyBytes(code)}
+		if len(o.storage) > 0 {
+			acc.Storage = make(map[common.Hash]*StorageDiff)
+			for key, prev := range o.storage {
+				acc.Storage[key] = &StorageDiff{From: prev, To: obj.Gelbchain-devate(self.db, key)}
+			}
+		}
+		if deleted {
+			acc.Deleted = true
+			acc.Balance.To = (*hexutil.Big)(new(big.Int))
+			acc.Nonce.To = 0
+			acc.Code.To = nil
+			for _, slot := range acc.Storage {
+				slot.To = common.Hash{}
+			}
+		}
+		// An account both created and deleted never made it into the state
+		if o.fresh && acc.Deleted {
+			continue
+		}
+		acc.prune()
+		if !acc.empty() {
+			diff[addr] = acc
+		}
+	}
+	// Changes made after the transaction was finalised (e.g. block rewards) are
+	// not part of it, attribute them to the block instead
+	thash, index := self.thash, self.txIndex
+	if self.diffSealed {
+		thash, index = common.Hash{}, self.txIndex+1
+	}
+	self.diffs = append(self.diffs, &TxStateDiff{TxHash: thash, TxIndex: index, Diff: diff})
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+)
+
+// Tests that the state changes of individual transactions are recorded with
+// their original and new values, and that reverted changes are left out.
+func TestDiffRecording(t *testing.T) {
+	var (
+		db, _ = lbchain-devdb.NewMemDatabase()
+		a     = common.Address{0x0a}
+		b     = common.Address{0x0b}
+		c     = common.Address{0x0c}
+		tx1   = common.Hash{0x01}
+		tx2   = common.Hash{0x02}
+	)
+	statedb, _ := New(common.Hash{}, NewDatabase(db))
+	statedb.SetBalance(a, big.NewInt(100))
+	statedb.Selbchain-devate(a, common.Hash{1}, common.Hash{1})
+	root, _ := statedb.Commit(true)
+	statedb, _ = New(root, statedb.Database())
+
+	statedb.EnableDiffs()
+
+	// Transfer some funds and modify storage, reverting some changes
+	statedb.Prepare(tx1, common.Hash{}, 0)
+	statedb.SubBalance(a, big.NewInt(10))
+	statedb.AddBalance(b, big.NewInt(10))
+	statedb.Selbchain-devate(a, common.Hash{1}, common.Hash{2})
+	statedb.Selbchain-devate(a, common.Hash{2}, common.Hash{5})
+
+	snap := statedb.Snapshot()
+	statedb.Selbchain-devate(a, common.Hash{3}, common.Hash{3})
+	statedb.SetCode(b, []byte{0x01})
+	statedb.RevertToSnapshot(snap)
+	statedb.Finalise(true)
+
+	// Undo a storage change, bump the nonce and create a short lived account
+	statedb.Prepare(tx2, common.Hash{}, 1)
+	statedb.Selbchain-devate(a, common.Hash{1}, common.Hash{1})
+	statedb.SetNonce(a, 1)
+	statedb.CreateAccount(c)
+	statedb.AddBalance(c, big.NewInt(1))
+	statedb.Suicide(c)
+	statedb.Finalise(true)
+
+	diffs := statedb.Diffs()
+	if len(diffs) != 2 {
+		t.Fatalf("diff count mismatch: have %d, want 2", len(diffs))
+	}
+	hbig := func(n int64) *hexutil.Big { return (*hexutil.Big)(new(big.Int).SetInt64(n)) }
+
+	want1 := StateDiff{
+		a: {
+			Balance: &BalanceDiff{From: hbig(100), To: hbig(90)},
+			Storage: map[common.Hash]*StorageDiff{
+				{1}: {From: common.Hash{1}, To: common.Hash{2}},
+				{2}: {From: common.Hash{}, To: common.Hash{5}},
+			},
+		},
+		b: {
+			Created: true,
+			Balance: &BalanceDiff{From: hbig(0), To: hbig(10)},
+		},
+	}
+	want2 := StateDiff{
+		a: {
+			Nonce:   &NonceDiff{From: 0, To: 1},
+			Storage: map[common.Hash]*StorageDiff{{1}: {From: common.Hash{2}, To: common.Hash{1}}},
+		},
+	}
+	for i, want := range []StateDiff{want1, want2} {
+		if have := diffs[i].Diff; !equalDiffs(have, want) {
+			t.Errorf("tx %d: diff mismatch:\nhave %s\nwant %s", i, dumpDiff(have), dumpDiff(want))
+		}
+	}
+	if diffs[0].TxHash != tx1 || diffs[1].TxHash != tx2 || diffs[1].TxIndex != 1 {
+		t.Errorf("transaction attribution mismatch: have %x/%d, %x/%d", diffs[0].TxHash, diffs[0].TxIndex, diffs[1].TxHash, diffs[1].TxIndex)
+	}
+	// Merging the transactions should cancel out the reverted storage change
+	merged := make(StateDiff)
+	merged.Merge(diffs[0].Diff)
+	merged.Merge(diffs[1].Diff)
+
+	want := StateDiff{
+		a: {
+			Balance: &BalanceDiff{From: hbig(100), To: hbig(90)},
+			Nonce:   &NonceDiff{From: 0, To: 1},
+			Storage: map[common.Hash]*StorageDiff{{2}: {From: common.Hash{}, To: common.Hash{5}}},
+		},
+		b: want1[b],
+	}
+	if !equalDiffs(merged, want) {
+		t.Errorf("merged diff mismatch:\nhave %s\nwant %s", dumpDiff(merged), dumpDiff(want))
+	}
+	// Destructing an existing account should report it as deleted
+	statedb.Prepare(common.Hash{0x03}, common.Hash{}, 2)
+	statedb.Suicide(a)
+	statedb.Finalise(true)
+
+	diffs = statedb.Diffs()
+	if acc := diffs[2].Diff[a]; acc == nil || !acc.Deleted || acc.Balance == nil || (*big.Int)(acc.Balance.To).Sign() != 0 || acc.Nonce == nil || acc.Nonce.To != 0 {
+		t.Errorf("destructed account diff mismatch: have %s", dumpDiff(diffs[2].Diff))
+	}
+}
+
+func equalDiffs(a, b StateDiff) bool {
+	return reflect.DeepEqual(dumpDiff(a), dumpDiff(b))
+}
+
+func dumpDiff(diff StateDiff) string {
+	blob, _ := json.Marshal(diff)
+	return string(blob)
+}
@@ -17,14 +17,28 @@
 package state
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
 	"github.com/lbchain-devchain/go-lbchain-dev/trie"
 )
 
+// DumpConfig is a set of options to control what portions of the state are
+// iterated and collected.
+type DumpConfig struct {
+	SkipCode    bool   // Whlbchain-dever to leave out the contract codes
+	SkipStorage bool   // Whlbchain-dever to leave out the storage slots
+	Start       []byte // Account hash to start the iteration from (nil = beginning)
+	Max         uint64 // Maximum number of accounts to dump (0 = unlimited)
+	MaxStorage  uint64 // Maximum number of storage slots to dump per account (0 = unlimited)
+}
+
 type DumpAccount struct {
 	Balance  string            `json:"balance"`
 	Nonce    uint64            `json:"nonce"`
@@ -32,6 +46,10 @@ type DumpAccount struct {
 	CodeHash string            `json:"codeHash"`
 	Code     string            `json:"code"`
 	Storage  map[string]string `json:"storage"`
+
+	Address     *common.Address `json:"address,omitempty"`     // Account address, if the preimage is known (streaming only)
+	Key         hexutil.Bytes   `json:"key,omitempty"`         // Account hash in the state trie (streaming only)
+	StorageNext hexutil.Bytes   `json:"storageNext,omitempty"` // Slot hash to continue the storage from, if capped (streaming only)
 }
 
 type Dump struct {
@@ -39,43 +57,156 @@ type Dump struct {
 	Accounts map[string]DumpAccount `json:"accounts"`
 }
 
-func (self *StateDB) RawDump() Dump {
-	dump := Dump{
-		Root:     fmt.Sprintf("%x", self.trie.Hash()),
-		Accounts: make(map[string]DumpAccount),
+// IteratorDump is a page of accounts of the state, along with the account hash
+// to continue the iteration from.
+type IteratorDump struct {
+	Root     string        `json:"root"`
+	Accounts []DumpAccount `json:"accounts"`
+	Next     hexutil.Bytes `json:"next,omitempty"` // nil if no more accounts
+}
+
+// dump iterates over the accounts of the state in hash order, as configured by
+// conf, calling onaccount for each of them. The address is nil if its preimage
+// is unknown. If the iteration stops early due to the account limit, the hash of
+// the next account is returned.
+func (self *StateDB) dump(conf *DumpConfig, onaccount func(addr *common.Address, account DumpAccount) error) ([]byte, error) {
+	if conf == nil {
+		conf = new(DumpConfig)
 	}
+	var count uint64
 
-	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	it := trie.NewIterator(self.trie.NodeIterator(conf.Start))
 	for it.Next() {
-		addr := self.trie.GetKey(it.Key)
+		if conf.Max > 0 && count == conf.Max {
+			return common.CopyBytes(it.Key), nil
+		}
 		var data Account
 		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("account %x: %v", it.Key, err)
 		}
-
-		obj := newObject(nil, common.BytesToAddress(addr), data, nil)
 		account := DumpAccount{
 			Balance:  data.Balance.String(),
 			Nonce:    data.Nonce,
 			Root:     common.Bytes2Hex(data.Root[:]),
 			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
-			Storage:  make(map[string]string),
+			Key:      common.CopyBytes(it.Key),
 		}
-		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
-		for storageIt.Next() {
-			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+		var addr *common.Address
+		if preimage := self.trie.GetKey(it.Key); preimage != nil {
+			address := common.BytesToAddress(preimage)
+			addr = &address
+		}
+		addrHash := common.BytesToHash(it.Key)
+		if !conf.SkipCode && !bytes.Equal(data.CodeHash, emptyCodeHash) {
+			code, err := self.db.ContractCode(addrHash, common.BytesToHash(data.CodeHash))
+			if err != nil {
+				return nil, fmt.Errorf("account %x: missing code: %v", it.Key, err)
+			}
+			account.Code = common.Bytes2Hex(code)
+		}
+		if !conf.SkipStorage {
+			account.Storage = make(map[string]string)
+
+			tr, err := self.db.OpenStorageTrie(addrHash, data.Root)
+			if err != nil {
+				return nil, fmt.Errorf("account %x: missing storage trie: %v", it.Key, err)
+			}
+			storageIt := trie.NewIterator(tr.NodeIterator(nil))
+			for storageIt.Next() {
+				if conf.MaxStorage > 0 && uint64(len(account.Storage)) == conf.MaxStorage {
+					account.StorageNext = common.CopyBytes(storageIt.Key)
+					break
+				}
+				account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+			}
+			if storageIt.Err != nil {
+				return nil, fmt.Errorf("account %x: %v", it.Key, storageIt.Err)
+			}
+		}
+		if err := onaccount(addr, account); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	return nil, it.Err
+}
+
+// RawDump collects the entire state into memory. Use IterativeDump or
+// IteratorDump for large states.
+func (self *StateDB) RawDump() (Dump, error) {
+	dump := Dump{
+		Root:     fmt.Sprintf("%x", self.trie.Hash()),
+		Accounts: make(map[string]DumpAccount),
+	}
+	_, err := self.dump(nil, func(addr *common.Address, account DumpAccount) error {
+		var key string
+		if addr != nil {
+			key = common.Bytes2Hex(addr[:])
 		}
-		dump.Accounts[common.Bytes2Hex(addr)] = account
+		account.Key = nil
+		dump.Accounts[key] = account
+		return nil
+	})
+	if err != nil {
+		return Dump{}, err
 	}
-	return dump
+	return dump, nil
 }
 
 func (self *StateDB) Dump() []byte {
-	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
+	dump, err := self.RawDump()
+	if err != nil {
+		fmt.Println("dump err", err)
+	}
+	json, err := json.MarshalIndent(dump, "", "    ")
 	if err != nil {
 		fmt.Println("dump err", err)
 	}
 
 	return json
 }
+
+// IterativeDump streams the state into the writer as JSON Lines, one object
+// holding the state root followed by one object for every account, without
+// collecting anything into memory.
+func (self *StateDB) IterativeDump(conf *DumpConfig, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(struct {
+		Root string `json:"root"`
+	}{fmt.Sprintf("%x", self.trie.Hash())}); err != nil {
+		return err
+	}
+	_, err := self.dump(conf, func(addr *common.Address, account DumpAccount) error {
+		account.Address = addr
+		return enc.Encode(account)
+	})
+	return err
+}
+
+// IteratorDump collects a page of the accounts of the state, as configured by
+// conf, returning the hash of the account to continue from in the next page.
+func (self *StateDB) IteratorDump(conf *DumpConfig) (IteratorDump, error) {
+	dump := IteratorDump{
+		Root:     fmt.Sprintf("%x", self.trie.Hash()),
+		Accounts: []DumpAccount{},
+	}
+	next, err := self.dump(conf, func(addr *common.Address, account DumpAccount) error {
+		account.Address = addr
+		dump.Accounts = append(dump.Accounts, account)
+		return nil
+	})
+	if err != nil {
+		return IteratorDump{}, err
+	}
+	dump.Next = next
+	return dump, nil
+}
+
+// DumpStartKey converts a dump starting point into an account hash: addresses
+// are hashed, anything else is taken as an account hash.
+func DumpStartKey(start []byte) []byte {
+	if len(start) == common.AddressLength {
+		return crypto.Keccak256(start)
+	}
+	return start
+}
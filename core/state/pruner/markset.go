@@ -0,0 +1,86 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+)
+
+// markSet is a disk backed set of hashes. The state of a real chain is too big
+// to track in memory, so marks are accumulated in batches into a scratch LevelDB
+// instance, with the not yet flushed ones kept aside for lookups.
+type markSet struct {
+	db      *lbchain-devdb.LDBDatabase
+	batch   lbchain-devdb.Batch
+	pending map[common.Hash]struct{}
+	count   uint64
+}
+
+// newMarkSet opens (or creates) a mark set in the given directory.
+func newMarkSet(dir string) (*markSet, error) {
+	db, err := lbchain-devdb.NewLDBDatabase(dir, 256, 256)
+	if err != nil {
+		return nil, err
+	}
+	return &markSet{
+		db:      db,
+		batch:   db.NewBatch(),
+		pending: make(map[common.Hash]struct{}),
+	}, nil
+}
+
+// add inserts a hash into the set, flushing to disk if enough accumulated.
+func (s *markSet) add(hash common.Hash) error {
+	if err := s.batch.Put(hash[:], nil); err != nil {
+		return err
+	}
+	s.pending[hash] = struct{}{}
+	s.count++
+
+	if s.batch.ValueSize() >= lbchain-devdb.IdealBatchSize || len(s.pending) >= 100000 {
+		return s.flush()
+	}
+	return nil
+}
+
+// has reports whlbchain-dever the hash is contained in the set.
+func (s *markSet) has(hash common.Hash) (bool, error) {
+	if _, ok := s.pending[hash]; ok {
+		return true, nil
+	}
+	return s.db.Has(hash[:])
+}
+
+// flush writes all pending marks out to disk.
+func (s *markSet) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.batch.Write(); err != nil {
+		return err
+	}
+	s.batch.Reset()
+	s.pending = make(map[common.Hash]struct{})
+	return nil
+}
+
+// close flushes any pending marks and releases the backing database.
+func (s *markSet) close() {
+	s.flush()
+	s.db.Close()
+}
@@ -0,0 +1,410 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline garbage collection of stale state trie nodes.
+//
+// The in-memory trie.Database only garbage collects dirty nodes, so everything
+// flushed to disk lives forever. The pruner marks every trie node and contract
+// code reachable from a set of recent canonical state roots and sweeps all other
+// hash-keyed entries out of the database. It must only be run on a database that
+// is not in use by a live node.
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// DefaultRetain is the default number of recent canonical states to keep,
+	// matching the number of tries a full node retains in memory.
+	DefaultRetain = 128
+
+	// phaseMark is the pruning phase in which reachable nodes are being collected.
+	phaseMark = uint8(0)
+
+	// phaseSweep is the pruning phase in which unreachable nodes are being deleted.
+	phaseSweep = uint8(1)
+
+	// sweepBatchSize is the number of deletions after which the sweep progress is
+	// persisted.
+	sweepBatchSize = 10000
+)
+
+var (
+	// markerKey is the database key tracking an in-progress pruning, allowing
+	// an interrupted run to resume where it left off.
+	markerKey = []byte("StatePruneMarker")
+
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	// errNoHeadState is returned if the state of the current head block is
+	// missing, in which case there is nothing sensible to retain.
+	errNoHeadState = errors.New("head state missing")
+)
+
+// marker is the persisted progress of a pruning run.
+type marker struct {
+	Roots    []common.Hash // State roots being retained
+	Phase    uint8         // Pruning phase the run was interrupted in
+	Position []byte        // Last database key swept (sweep phase only)
+}
+
+// Pruner deletes all state trie nodes and contract codes not reachable from the
+// most recent canonical state roots.
+type Pruner struct {
+	db        *lbchain-devdb.LDBDatabase // Chain database to prune
+	datadir   string             // Scratch directory to store the reachability sets in
+	retain    uint64             // Number of recent canonical states to retain
+	batchSize int                // Number of deletions to persist the sweep progress after
+
+	// Testing hooks
+	sweepHook func([]byte) error // Method to call upon persisting the sweep progress, aborting on error
+}
+
+// New creates a state pruner for the given database. The datadir is used to
+// hold the temporary mark sets, which may be large for big states.
+func New(db *lbchain-devdb.LDBDatabase, datadir string, retain uint64) *Pruner {
+	if retain == 0 {
+		retain = 1
+	}
+	return &Pruner{
+		db:        db,
+		datadir:   datadir,
+		retain:    retain,
+		batchSize: sweepBatchSize,
+	}
+}
+
+// Prune runs (or resumes) a full pruning cycle: it marks all nodes reachable
+// from the retained roots, sweeps everything else out of the database and
+// finally verifies that the retained states are still complete.
+func (p *Pruner) Prune() error {
+	start := time.Now()
+
+	m, err := p.loadMarker()
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		roots, err := p.retainedRoots()
+		if err != nil {
+			return err
+		}
+		m = &marker{Roots: roots, Phase: phaseMark}
+		if err := p.storeMarker(m); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Resuming interrupted state pruning", "roots", len(m.Roots), "phase", m.Phase, "position", common.ToHex(m.Position))
+	}
+	markdir := filepath.Join(p.datadir, "marks")
+
+	// If the mark phase was interrupted or the mark set went missing, (re)collect
+	if m.Phase == phaseMark || !common.FileExist(markdir) {
+		if err := os.RemoveAll(markdir); err != nil {
+			return err
+		}
+		marks, err := newMarkSet(markdir)
+		if err != nil {
+			return err
+		}
+		if err := p.mark(m.Roots, marks); err != nil {
+			marks.close()
+			return err
+		}
+		marks.close()
+
+		m.Phase, m.Position = phaseSweep, nil
+		if err := p.storeMarker(m); err != nil {
+			return err
+		}
+	}
+	// Reachable nodes collected, delete everything else
+	marks, err := newMarkSet(markdir)
+	if err != nil {
+		return err
+	}
+	if err := p.sweep(m, marks); err != nil {
+		marks.close()
+		return err
+	}
+	marks.close()
+
+	// Ensure none of the retained states were damaged before cleaning up
+	if err := p.verify(m.Roots); err != nil {
+		return err
+	}
+	if err := p.db.Delete(markerKey); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p.datadir); err != nil {
+		return err
+	}
+	log.Info("Compacting database after pruning")
+	cstart := time.Now()
+	if err := p.db.LDB().CompactRange(util.Range{}); err != nil {
+		return err
+	}
+	log.Info("State pruning completed", "roots", len(m.Roots), "compaction", common.PrettyDuration(time.Since(cstart)), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// retainedRoots collects the state roots of the most recent canonical blocks
+// that are actually present in the database.
+func (p *Pruner) retainedRoots() ([]common.Hash, error) {
+	head := core.GetHeadBlockHash(p.db)
+	if head == (common.Hash{}) {
+		return nil, errors.New("head block missing")
+	}
+	number := core.GetBlockNumber(p.db, head)
+	header := core.GetHeader(p.db, head, number)
+	if header == nil {
+		return nil, fmt.Errorf("head header #%d [%x…] missing", number, head[:4])
+	}
+	if ok, _ := p.db.Has(header.Root[:]); !ok {
+		return nil, errNoHeadState
+	}
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]struct{})
+	)
+	for i := uint64(0); i < p.retain && i <= header.Number.Uint64(); i++ {
+		n := header.Number.Uint64() - i
+		h := core.GetHeader(p.db, core.GetCanonicalHash(p.db, n), n)
+		if h == nil {
+			break
+		}
+		if _, ok := seen[h.Root]; ok {
+			continue
+		}
+		if ok, _ := p.db.Has(h.Root[:]); !ok {
+			continue
+		}
+		seen[h.Root] = struct{}{}
+		roots = append(roots, h.Root)
+	}
+	log.Info("Selected state roots to retain", "head", header.Number, "count", len(roots))
+	return roots, nil
+}
+
+// mark iterates all the given state tries, adding every standalone trie node
+// and contract code hash to the mark set. Subtries that were already marked are
+// skipped, so consecutive roots sharing most of their nodes are cheap.
+func (p *Pruner) mark(roots []common.Hash, marks *markSet) error {
+	var (
+		triedb = trie.NewDatabase(p.db)
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for i, root := range roots {
+		err := p.markTrie(triedb, root, marks, func(leaf []byte) error {
+			var account state.Account
+			if err := rlp.DecodeBytes(leaf, &account); err != nil {
+				return err
+			}
+			if code := common.BytesToHash(account.CodeHash); code != emptyCode {
+				if ok, _ := p.db.Has(code[:]); !ok {
+					return fmt.Errorf("contract code %x missing", code)
+				}
+				if err := marks.add(code); err != nil {
+					return err
+				}
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Marking reachable state", "root", i+1, "roots", len(roots), "nodes", marks.count, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+			return p.markTrie(triedb, account.Root, marks, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("state %x: %v", root, err)
+		}
+	}
+	if err := marks.flush(); err != nil {
+		return err
+	}
+	log.Info("Marked reachable state", "roots", len(roots), "nodes", marks.count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// markTrie adds all the nodes of a single trie to the mark set, invoking the
+// optional onleaf callback for every value not yet seen.
+func (p *Pruner) markTrie(triedb *trie.Database, root common.Hash, marks *markSet, onleaf func([]byte) error) error {
+	if root == emptyRoot || root == (common.Hash{}) {
+		return nil
+	}
+	if ok, err := marks.has(root); err != nil || ok {
+		return err
+	}
+	t, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			ok, err := marks.has(hash)
+			if err != nil {
+				return err
+			}
+			if ok {
+				descend = false
+				continue
+			}
+			if err := marks.add(hash); err != nil {
+				return err
+			}
+		}
+		if it.Leaf() && onleaf != nil {
+			if err := onleaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// sweep deletes all hash-keyed database entries not contained in the mark set.
+// Progress is persisted atomically with every batch of deletions.
+func (p *Pruner) sweep(m *marker, marks *markSet) error {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		batch   = new(leveldb.Batch)
+		deleted int
+		size    common.StorageSize
+		it      = p.db.LDB().NewIterator(&util.Range{Start: m.Position}, nil)
+	)
+	defer it.Release()
+
+	flush := func() error {
+		blob, err := rlp.EncodeToBytes(m)
+		if err != nil {
+			return err
+		}
+		batch.Put(markerKey, blob)
+		if err := p.db.LDB().Write(batch, nil); err != nil {
+			return err
+		}
+		batch.Reset()
+		if p.sweepHook != nil {
+			return p.sweepHook(m.Position)
+		}
+		return nil
+	}
+	for it.Next() {
+		key := it.Key()
+
+		// Trie nodes and contract codes are the only entries keyed by a bare hash
+		if len(key) != common.HashLength {
+			continue
+		}
+		ok, err := marks.has(common.BytesToHash(key))
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		batch.Delete(key)
+		deleted++
+		size += common.StorageSize(len(key) + len(it.Value()))
+
+		if batch.Len() >= p.batchSize {
+			m.Position = common.CopyBytes(key)
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning stale state", "deleted", deleted, "size", size, "position", common.ToHex(key), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	log.Info("Pruned stale state", "deleted", deleted, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verify walks all the retained state tries, ensuring every node and contract
+// code is still present after pruning.
+func (p *Pruner) verify(roots []common.Hash) error {
+	start := time.Now()
+
+	dir := filepath.Join(p.datadir, "verify")
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	seen, err := newMarkSet(dir)
+	if err != nil {
+		return err
+	}
+	defer seen.close()
+
+	if err := p.mark(roots, seen); err != nil {
+		return fmt.Errorf("retained state damaged: %v", err)
+	}
+	log.Info("Verified retained states", "roots", len(roots), "nodes", seen.count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// loadMarker retrieves the progress marker of an interrupted pruning, or nil
+// if no pruning is in progress.
+func (p *Pruner) loadMarker() (*marker, error) {
+	blob, err := p.db.Get(markerKey)
+	if err != nil || len(blob) == 0 {
+		return nil, nil
+	}
+	m := new(marker)
+	if err := rlp.DecodeBytes(blob, m); err != nil {
+		return nil, fmt.Errorf("corrupt pruning marker: %v", err)
+	}
+	return m, nil
+}
+
+// storeMarker persists the pruning progress marker into the database.
+func (p *Pruner) storeMarker(m *marker) error {
+	blob, err := rlp.EncodeToBytes(m)
+	if err != nil {
+		return err
+	}
+	return p.db.Put(markerKey, blob)
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+// Tests that pruning retains the requested recent states fully intact, while
+// deleting the ones beyond the retention window.
+func TestPruneState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := lbchain-devdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	blocks := makeArchiveChain(t, db)
+
+	// Prune all but the last two states and ensure they are retained
+	if err := New(db, filepath.Join(dir, "prunestate"), 2).Prune(); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	for i, block := range blocks {
+		statedb, err := state.New(block.Root(), state.NewDatabase(db))
+		if i < len(blocks)-2 {
+			if err == nil {
+				t.Errorf("block %d: stale state retained", block.NumberU64())
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("block %d: retained state missing: %v", block.NumberU64(), err)
+		}
+		it := state.NewNodeIterator(statedb)
+		for it.Next() {
+		}
+		if it.Error != nil {
+			t.Errorf("block %d: retained state incomplete: %v", block.NumberU64(), it.Error)
+		}
+	}
+	if blob, _ := db.Get(markerKey); blob != nil {
+		t.Errorf("pruning marker not cleaned up")
+	}
+}
+
+// Tests that a pruning interrupted during the sweep persists its progress, that
+// a restart resumes from it, and that no live node is deleted along the way.
+func TestPruneStateResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := lbchain-devdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	blocks := makeArchiveChain(t, db)
+
+	// Collect the nodes of the states to retain, all of which must survive
+	var live []common.Hash
+	for _, block := range blocks[len(blocks)-2:] {
+		statedb, _ := state.New(block.Root(), state.NewDatabase(db))
+		for it := state.NewNodeIterator(statedb); it.Next(); {
+			if it.Hash != (common.Hash{}) {
+				live = append(live, it.Hash)
+			}
+		}
+	}
+	checkLive := func(stage string) {
+		for _, hash := range live {
+			if ok, _ := db.Has(hash[:]); !ok {
+				t.Fatalf("%s: live node %x deleted", stage, hash)
+			}
+		}
+	}
+	// Prune with tiny batches and stop after the first persisted one
+	errInterrupted := errors.New("interrupted")
+
+	pruner := New(db, filepath.Join(dir, "prunestate"), 2)
+	pruner.batchSize = 2
+	pruner.sweepHook = func(position []byte) error { return errInterrupted }
+
+	if err := pruner.Prune(); err != errInterrupted {
+		t.Fatalf("interrupted pruning error mismatch: have %v, want %v", err, errInterrupted)
+	}
+	m, err := pruner.loadMarker()
+	if err != nil || m == nil {
+		t.Fatalf("pruning marker not persisted: %v", err)
+	}
+	if m.Phase != phaseSweep || m.Position == nil || len(m.Roots) != 2 {
+		t.Fatalf("pruning marker mismatch: phase %d, position %x, %d roots", m.Phase, m.Position, len(m.Roots))
+	}
+	if m.Roots[0] != blocks[len(blocks)-1].Root() || m.Roots[1] != blocks[len(blocks)-2].Root() {
+		t.Errorf("retained roots mismatch: have %x", m.Roots)
+	}
+	checkLive("interrupted")
+
+	// Restart the pruning and ensure it continues from the persisted position
+	var positions [][]byte
+
+	pruner = New(db, filepath.Join(dir, "prunestate"), 2)
+	pruner.batchSize = 2
+	pruner.sweepHook = func(position []byte) error {
+		positions = append(positions, common.CopyBytes(position))
+		return nil
+	}
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("failed to resume pruning: %v", err)
+	}
+	if len(positions) == 0 {
+		t.Fatalf("resumed pruning didn't sweep")
+	}
+	for i, position := range positions {
+		if bytes.Compare(position, m.Position) < 0 {
+			t.Errorf("resumed batch %d: position %x before the persisted %x", i, position, m.Position)
+		}
+	}
+	checkLive("resumed")
+
+	for _, block := range blocks[:len(blocks)-2] {
+		if _, err := state.New(block.Root(), state.NewDatabase(db)); err == nil {
+			t.Errorf("block %d: stale state retained", block.NumberU64())
+		}
+	}
+	if blob, _ := db.Get(markerKey); blob != nil {
+		t.Errorf("pruning marker not cleaned up")
+	}
+}
+
+// makeArchiveChain imports a short chain into the database as an archive node,
+// mutating the state in every block.
+func makeArchiveChain(t *testing.T, db *lbchain-devdb.LDBDatabase) []*types.Block {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 8, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(common.Address{0x01})
+
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i + 2)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	chain, _ := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+	return blocks
+}
@@ -0,0 +1,264 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// diskCacheItems is the number of recently read flat entries to keep in memory.
+const diskCacheItems = 1024 * 1024
+
+var (
+	// Database key schema of the flat snapshot. The prefixes are shared with
+	// other data types of the chain database, so entries are told apart by the
+	// exact key length too.
+	AccountPrefix = []byte("a") // AccountPrefix + account hash -> account trie value
+	StoragePrefix = []byte("o") // StoragePrefix + account hash + storage hash -> storage trie value
+
+	snapshotRootKey      = []byte("SnapshotRoot")      // State root of the fully generated snapshot
+	snapshotGeneratorKey = []byte("SnapshotGenerator") // Progress of a snapshot being generated
+)
+
+// accountKey = AccountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, AccountPrefix...), hash[:]...)
+}
+
+// storageKey = StoragePrefix + account hash + storage hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	return append(append(append([]byte{}, StoragePrefix...), accountHash[:]...), storageHash[:]...)
+}
+
+// generatorProgress is the persisted marker of an ongoing snapshot generation.
+type generatorProgress struct {
+	Root   common.Hash // State root the snapshot is being generated for
+	Marker []byte      // Last account hash fully generated (empty = none)
+}
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb *lbchain-devdb.LDBDatabase // Key-value store containing the base snapshot
+	triedb *trie.Database     // Trie node cache for reconstruction purposes
+	cache  *lru.Cache         // Cache to avoid hitting the disk for direct access
+
+	root  common.Hash // Root hash of the base snapshot
+	stale bool        // Signals that the layer became stale (state progressed)
+
+	genMarker []byte           // Marker for the state that's indexed during generation (nil = done)
+	genAbort  chan chan []byte // Notification channel to abort generating the snapshot in this layer
+	genDone   chan struct{}    // Notification channel closed when the generator terminates
+
+	lock sync.RWMutex
+}
+
+// newDiskLayer creates a disk layer for the given root, optionally reusing the
+// read cache of a previous disk layer.
+func newDiskLayer(diskdb *lbchain-devdb.LDBDatabase, triedb *trie.Database, root common.Hash, marker []byte, cache *lru.Cache) *diskLayer {
+	if cache == nil {
+		cache, _ = lru.New(diskCacheItems)
+	}
+	return &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		cache:     cache,
+		root:      root,
+		genMarker: marker,
+	}
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whlbchain-dever this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// covered returns whlbchain-dever the given account hash was already processed by
+// the background generation. The method assumes the read lock is held.
+func (dl *diskLayer) covered(hash common.Hash) bool {
+	return dl.genMarker == nil || (len(dl.genMarker) > 0 && bytes.Compare(hash[:], dl.genMarker) <= 0)
+}
+
+// Account directly retrieves the RLP encoded account associated with a particular
+// hash in the snapshot slim data format.
+func (dl *diskLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(hash) {
+		return nil, ErrNotCoveredYet
+	}
+	return dl.get(accountKey(hash))
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covered(accountHash) {
+		return nil, ErrNotCoveredYet
+	}
+	return dl.get(storageKey(accountHash, storageHash))
+}
+
+// get retrieves a flat entry from the clean cache or the database, treating
+// missing entries as empty.
+func (dl *diskLayer) get(key []byte) ([]byte, error) {
+	if blob, ok := dl.cache.Get(string(key)); ok {
+		snapshotCleanHitMeter.Mark(1)
+		return blob.([]byte), nil
+	}
+	snapshotCleanMissMeter.Mark(1)
+
+	blob, err := dl.diskdb.Get(key)
+	if err == leveldb.ErrNotFound {
+		blob, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dl.cache.Add(string(key), blob)
+	return blob, nil
+}
+
+// apply writes the contents of a diff layer into the persistent store. If the
+// snapshot is still being generated, only the range already covered by the
+// generator is written, the rest will be picked up from the trie directly.
+func (dl *diskLayer) apply(diff *diffLayer, marker []byte) error {
+	var (
+		db       = dl.diskdb.LDB()
+		batch    = new(leveldb.Batch)
+		accepted = func(hash common.Hash) bool {
+			return marker == nil || (len(marker) > 0 && bytes.Compare(hash[:], marker) <= 0)
+		}
+		flush = func(force bool) error {
+			if !force && len(batch.Dump()) < lbchain-devdb.IdealBatchSize {
+				return nil
+			}
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+			return nil
+		}
+	)
+	// Destructed accounts lose all their storage, wipe them first
+	for hash := range diff.destructSet {
+		if !accepted(hash) {
+			continue
+		}
+		batch.Delete(accountKey(hash))
+		dl.cache.Remove(string(accountKey(hash)))
+
+		prefix := append(append([]byte{}, StoragePrefix...), hash[:]...)
+		it := db.NewIterator(util.BytesPrefix(prefix), nil)
+		for it.Next() {
+			if key := it.Key(); len(key) == len(StoragePrefix)+2*common.HashLength {
+				batch.Delete(common.CopyBytes(key))
+				dl.cache.Remove(string(key))
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	// Write all the live accounts and storage slots
+	for hash, data := range diff.accountData {
+		if !accepted(hash) {
+			continue
+		}
+		key := accountKey(hash)
+		if len(data) == 0 {
+			batch.Delete(key)
+		} else {
+			batch.Put(key, data)
+		}
+		dl.cache.Add(string(key), data)
+
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	for accountHash, storage := range diff.storageData {
+		if !accepted(accountHash) {
+			continue
+		}
+		for storageHash, data := range storage {
+			key := storageKey(accountHash, storageHash)
+			if len(data) == 0 {
+				batch.Delete(key)
+			} else {
+				batch.Put(key, data)
+			}
+			dl.cache.Add(string(key), data)
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	return flush(true)
+}
+
+// persistRoot records the root of the disk layer in the database, or the
+// progress of the generator if the snapshot is still incomplete.
+func (dl *diskLayer) persistRoot() error {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.genMarker != nil {
+		blob, err := rlp.EncodeToBytes(generatorProgress{Root: dl.root, Marker: dl.genMarker})
+		if err != nil {
+			return err
+		}
+		return dl.diskdb.Put(snapshotGeneratorKey, blob)
+	}
+	return dl.diskdb.Put(snapshotRootKey, dl.root[:])
+}
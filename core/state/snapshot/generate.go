@@ -0,0 +1,261 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// account is the lbchain-devchain consensus representation of accounts, needed to
+// find the storage tries during generation.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// generateSnapshot starts regenerating the snapshot of the given root in the
+// background from the state trie, wiping any existing snapshot data first.
+func generateSnapshot(diskdb *lbchain-devdb.LDBDatabase, triedb *trie.Database, root common.Hash) *diskLayer {
+	base := newDiskLayer(diskdb, triedb, root, []byte{}, nil)
+	if err := diskdb.Delete(snapshotRootKey); err != nil {
+		log.Error("Failed to delete snapshot root", "err", err)
+	}
+	if err := base.persistRoot(); err != nil {
+		log.Error("Failed to store snapshot generator", "err", err)
+	}
+	base.startGeneration()
+	return base
+}
+
+// wipeSnapshot deletes all the flat snapshot entries from the database. If an
+// abort request arrives in the meantime, the wiping is interrupted and the
+// request returned.
+func (dl *diskLayer) wipeSnapshot() (chan []byte, error) {
+	var (
+		db    = dl.diskdb.LDB()
+		batch = new(leveldb.Batch)
+	)
+	for _, prefix := range []struct {
+		prefix []byte
+		length int
+	}{
+		{AccountPrefix, len(AccountPrefix) + common.HashLength},
+		{StoragePrefix, len(StoragePrefix) + 2*common.HashLength},
+	} {
+		it := db.NewIterator(util.BytesPrefix(prefix.prefix), nil)
+		for it.Next() {
+			if len(it.Key()) != prefix.length {
+				continue
+			}
+			batch.Delete(common.CopyBytes(it.Key()))
+			if len(batch.Dump()) >= lbchain-devdb.IdealBatchSize {
+				if err := db.Write(batch, nil); err != nil {
+					it.Release()
+					return nil, err
+				}
+				batch.Reset()
+
+				select {
+				case abort := <-dl.genAbort:
+					it.Release()
+					return abort, nil
+				default:
+				}
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, db.Write(batch, nil)
+}
+
+// startGeneration launches the background generator of the disk layer.
+func (dl *diskLayer) startGeneration() {
+	dl.genAbort = make(chan chan []byte)
+	dl.genDone = make(chan struct{})
+	go dl.generate()
+}
+
+// stopGeneration aborts the background generator if it is running, returning
+// the marker up to which the snapshot was generated (nil if it's complete).
+func (dl *diskLayer) stopGeneration() []byte {
+	if dl.genAbort != nil {
+		res := make(chan []byte)
+		select {
+		case dl.genAbort <- res:
+			<-res
+		case <-dl.genDone:
+		}
+		dl.genAbort = nil
+	}
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.genMarker
+}
+
+// generate is a background thread that iterates over the state trie of the disk
+// layer and creates the flat snapshot entries for it, continuing from where a
+// previous run was interrupted.
+func (dl *diskLayer) generate() {
+	defer close(dl.genDone)
+
+	dl.lock.RLock()
+	marker := common.CopyBytes(dl.genMarker)
+	dl.lock.RUnlock()
+
+	// Starting from scratch, make sure no stale entries are left around
+	if len(marker) == 0 {
+		abort, err := dl.wipeSnapshot()
+		if err != nil {
+			log.Error("Failed to wipe state snapshot", "err", err)
+			return
+		}
+		if abort != nil {
+			abort <- marker
+			return
+		}
+	}
+	var (
+		db       = dl.diskdb.LDB()
+		batch    = new(leveldb.Batch)
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+	)
+	// checkpoint flushes all the entries generated so far and advances the marker
+	checkpoint := func(last []byte) error {
+		blob, err := rlp.EncodeToBytes(generatorProgress{Root: dl.root, Marker: last})
+		if err != nil {
+			return err
+		}
+		batch.Put(snapshotGeneratorKey, blob)
+		if err := db.Write(batch, nil); err != nil {
+			return err
+		}
+		batch.Reset()
+
+		dl.lock.Lock()
+		dl.genMarker = last
+		dl.lock.Unlock()
+		return nil
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		log.Error("Failed to open account trie for snapshot", "root", dl.root, "err", err)
+		return
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(marker))
+	for it.Next() {
+		// The marker itself was already generated by the previous run
+		if len(marker) > 0 && bytes.Equal(it.Key, marker) {
+			continue
+		}
+		accountHash := common.BytesToHash(it.Key)
+		batch.Put(accountKey(accountHash), common.CopyBytes(it.Value))
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			log.Error("Invalid account encountered during snapshot generation", "hash", accountHash, "err", err)
+			return
+		}
+		if acc.Root != emptyRoot {
+			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb, 0)
+			if err != nil {
+				log.Error("Failed to open storage trie for snapshot", "root", acc.Root, "err", err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				batch.Put(storageKey(accountHash, common.BytesToHash(storeIt.Key)), common.CopyBytes(storeIt.Value))
+				slots++
+
+				// Huge storage tries are flushed without advancing the marker
+				if len(batch.Dump()) >= lbchain-devdb.IdealBatchSize {
+					if err := db.Write(batch, nil); err != nil {
+						log.Error("Failed to write snapshot storage", "err", err)
+						return
+					}
+					batch.Reset()
+				}
+			}
+			if storeIt.Err != nil {
+				log.Error("Failed to iterate storage trie for snapshot", "root", acc.Root, "err", storeIt.Err)
+				return
+			}
+		}
+		// The account is fully generated, checkpoint if enough data accumulated
+		var abort chan []byte
+		select {
+		case abort = <-dl.genAbort:
+		default:
+		}
+		if len(batch.Dump()) >= lbchain-devdb.IdealBatchSize || abort != nil {
+			if err := checkpoint(common.CopyBytes(it.Key)); err != nil {
+				log.Error("Failed to write snapshot generation progress", "err", err)
+				if abort != nil {
+					abort <- nil
+				}
+				return
+			}
+		}
+		if abort != nil {
+			log.Info("Aborted state snapshot generation", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			abort <- dl.genMarker
+			return
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		log.Error("Failed to iterate account trie for snapshot", "root", dl.root, "err", it.Err)
+		return
+	}
+	// Snapshot fully generated, mark it complete
+	batch.Delete(snapshotGeneratorKey)
+	batch.Put(snapshotRootKey, dl.root[:])
+	if err := db.Write(batch, nil); err != nil {
+		log.Error("Failed to finalize state snapshot", "err", err)
+		return
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.lock.Unlock()
+
+	log.Info("Generated state snapshot", "root", dl.root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+}
@@ -0,0 +1,344 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat, hash keyed view of the state trie, used
+// to serve account and storage reads without walking the trie.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+)
+
+var (
+	snapshotCleanHitMeter  = metrics.NewRegisteredMeter("state/snapshot/clean/hit", nil)
+	snapshotCleanMissMeter = metrics.NewRegisteredMeter("state/snapshot/clean/miss", nil)
+	snapshotDirtyHitMeter  = metrics.NewRegisteredMeter("state/snapshot/dirty/hit", nil)
+	snapshotFlattenMeter   = metrics.NewRegisteredMeter("state/snapshot/flatten", nil)
+
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// Account directly retrieves the RLP encoded account associated with a
+	// particular hash in the snapshot slim data format, or nil if the account
+	// does not exist.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the RLP encoded storage data associated with a
+	// particular hash within a particular account, or nil if the slot is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Stale return whlbchain-dever this layer has become stale (was flattened across)
+	// or if it's still live.
+	Stale() bool
+}
+
+// Tree is an lbchain-devchain state snapshot tree. It consists of one persistent
+// base layer backed by a key-value store, on top of which arbitrarily many in-
+// memory diff layers are topped. The memory diffs can form a tree with branching,
+// but the disk layer is singleton and common to all. If a reorg goes deeper than
+// the disk layer, everything needs to be regenerated.
+//
+// The goal of a state snapshot is to allow direct access to account and storage
+// data to avoid expensive multi-level trie lookups.
+type Tree struct {
+	diskdb *lbchain-devdb.LDBDatabase       // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that the head of the snapshot matches the expected one.
+//
+// If the snapshot is missing, incomplete for a different root or otherwise does
+// not match the expected root, it is wiped and regenerated in the background
+// from the state trie.
+func New(diskdb *lbchain-devdb.LDBDatabase, triedb *trie.Database, root common.Hash) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	if head := loadSnapshot(diskdb, triedb, root); head != nil {
+		snap.layers[root] = head
+		return snap
+	}
+	snap.layers[root] = generateSnapshot(diskdb, triedb, root)
+	return snap
+}
+
+// loadSnapshot loads a pre-existing state snapshot backed by a key-value store,
+// resuming its generation if it was interrupted. Nil is returned if there is no
+// usable snapshot for the requested root.
+func loadSnapshot(diskdb *lbchain-devdb.LDBDatabase, triedb *trie.Database, root common.Hash) *diskLayer {
+	// If an interrupted generation is found for the correct root, resume it
+	if blob, _ := diskdb.Get(snapshotGeneratorKey); len(blob) > 0 {
+		var gen generatorProgress
+		if err := rlp.DecodeBytes(blob, &gen); err != nil {
+			log.Warn("Failed to decode snapshot generator", "err", err)
+			return nil
+		}
+		if gen.Root != root {
+			log.Warn("Snapshot generator stale", "have", gen.Root, "want", root)
+			return nil
+		}
+		log.Info("Resuming state snapshot generation", "root", root, "at", common.BytesToHash(gen.Marker))
+		base := newDiskLayer(diskdb, triedb, root, gen.Marker, nil)
+		base.startGeneration()
+		return base
+	}
+	// Otherwise accept a fully generated snapshot only if it's for the correct root
+	blob, _ := diskdb.Get(snapshotRootKey)
+	if len(blob) != common.HashLength {
+		log.Warn("Snapshot root missing")
+		return nil
+	}
+	if have := common.BytesToHash(blob); have != root {
+		log.Warn("Snapshot root stale", "have", have, "want", root)
+		return nil
+	}
+	log.Info("Loaded state snapshot", "root", root)
+	return newDiskLayer(diskdb, triedb, root, nil, nil)
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if snap, ok := t.layers[blockRoot]; ok {
+		return snap
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This is a
+	// special case that can only happen for Clique networks where empty blocks
+	// don't modify the state (0 block subsidy).
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Generate a new snapshot on top of the parent, unless already known
+	if _, ok := t.layers[blockRoot]; ok {
+		return nil
+	}
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[blockRoot] = newDiffLayer(parent, blockRoot, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the persistent disk layer. A zero layer count
+// flattens every diff layer of the given head.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil // Disk layer, nothing to flatten
+	}
+	// Find the lowest diff layer to retain and flatten everything below it
+	var bottom *diffLayer
+	if layers == 0 {
+		bottom = diff
+	} else {
+		for i := 0; i < layers-1; i++ {
+			parent, ok := diff.Parent().(*diffLayer)
+			if !ok {
+				return nil // Not enough layers to flatten
+			}
+			diff = parent
+		}
+		if bottom, ok = diff.Parent().(*diffLayer); !ok {
+			return nil
+		}
+	}
+	base, err := t.flatten(bottom)
+	if err != nil {
+		return err
+	}
+	// Rewire all the children of the flattened layer onto the new disk layer
+	for _, layer := range t.layers {
+		if child, ok := layer.(*diffLayer); ok {
+			child.lock.Lock()
+			if child.parent == snapshot(bottom) {
+				child.parent = base
+			}
+			child.lock.Unlock()
+		}
+	}
+	// Drop all the layers that don't descend from the new disk layer anymore
+	for hash, layer := range t.layers {
+		for current := layer; ; current = current.Parent() {
+			if disk, ok := current.(*diskLayer); ok {
+				if disk != base {
+					markStale(layer)
+					delete(t.layers, hash)
+				}
+				break
+			}
+			if current.Stale() {
+				markStale(layer)
+				delete(t.layers, hash)
+				break
+			}
+		}
+	}
+	t.layers[base.root] = base
+	return nil
+}
+
+// flatten persists the given diff layer along with all of its ancestors into
+// the disk layer, returning the new disk layer the chain was squashed into.
+//
+// The method assumes that the tree lock is held.
+func (t *Tree) flatten(bottom *diffLayer) (*diskLayer, error) {
+	// Gather all the diffs from the disk layer upwards
+	var diffs []*diffLayer
+	for current := snapshot(bottom); ; current = current.Parent() {
+		if diff, ok := current.(*diffLayer); ok {
+			diffs = append(diffs, diff)
+			continue
+		}
+		base := current.(*diskLayer)
+
+		// Stop any running generation, the flattened data changes the root
+		marker := base.stopGeneration()
+
+		// Invalidate the persisted root until all diffs are written, so a crash
+		// midway results in a regeneration instead of a corrupt snapshot
+		if err := t.diskdb.Delete(snapshotRootKey); err != nil {
+			return nil, err
+		}
+		for i := len(diffs) - 1; i >= 0; i-- {
+			if err := base.apply(diffs[i], marker); err != nil {
+				return nil, err
+			}
+			markStale(diffs[i])
+			snapshotFlattenMeter.Mark(1)
+		}
+		base.lock.Lock()
+		base.stale = true
+		base.lock.Unlock()
+
+		res := newDiskLayer(t.diskdb, t.triedb, bottom.root, marker, base.cache)
+		if err := res.persistRoot(); err != nil {
+			return nil, err
+		}
+		if marker != nil {
+			res.startGeneration()
+		}
+		return res, nil
+	}
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all the in-memory layers, regenerating the snapshot from the state
+// trie of the given root in the background.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+		markStale(layer)
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+	t.layers = map[common.Hash]snapshot{
+		root: generateSnapshot(t.diskdb, t.triedb, root),
+	}
+}
+
+// Stop terminates any running background generation, persisting its progress
+// so it can be resumed on the next startup.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+	}
+}
+
+// markStale flags a snapshot layer as no longer usable.
+func markStale(layer snapshot) {
+	switch layer := layer.(type) {
+	case *diffLayer:
+		layer.lock.Lock()
+		layer.stale = true
+		layer.lock.Unlock()
+	case *diskLayer:
+		layer.lock.Lock()
+		layer.stale = true
+		layer.lock.Unlock()
+	}
+}
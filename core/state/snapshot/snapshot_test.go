@@ -0,0 +1,280 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+)
+
+// newTestDatabase creates a temporary persistent database for the snapshots.
+func newTestDatabase(t *testing.T) (*lbchain-devdb.LDBDatabase, func()) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	db, err := lbchain-devdb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// randomHash generates a random blob of data and returns it as a hash.
+func randomHash(seed byte, index int) common.Hash {
+	return crypto.Keccak256Hash([]byte{seed, byte(index >> 8), byte(index)})
+}
+
+// newTestTree creates a snapshot tree on top of a fully generated, empty disk
+// layer for the given root.
+func newTestTree(db *lbchain-devdb.LDBDatabase, root common.Hash) *Tree {
+	db.Put(snapshotRootKey, root[:])
+	return New(db, trie.NewDatabase(db), root)
+}
+
+// waitGeneration blocks until the background generation of the tree's disk
+// layer completes.
+func waitGeneration(t *testing.T, snaps *Tree, root common.Hash) *diskLayer {
+	disk, ok := snaps.Snapshot(root).(*diskLayer)
+	if !ok {
+		t.Fatalf("disk layer missing for %x", root)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		disk.lock.RLock()
+		done := disk.genMarker == nil
+		disk.lock.RUnlock()
+
+		if done {
+			return disk
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("snapshot generation timed out")
+		}
+	}
+}
+
+// Tests that accounts and storage slots are resolved from the topmost layer
+// that modified them, and that destructed accounts hide all their old storage.
+func TestDiffLayerLookups(t *testing.T) {
+	db, release := newTestDatabase(t)
+	defer release()
+
+	var (
+		base  = common.Hash{0x01}
+		acc1  = common.Hash{0xa1}
+		acc2  = common.Hash{0xa2}
+		slot1 = common.Hash{0xb1}
+		slot2 = common.Hash{0xb2}
+	)
+	db.Put(accountKey(acc1), []byte{0x01})
+	db.Put(storageKey(acc1, slot1), []byte{0x11})
+	db.Put(storageKey(acc1, slot2), []byte{0x12})
+
+	snaps := newTestTree(db, base)
+
+	// Modify the first slot in one layer and destruct the account in another
+	if err := snaps.Update(common.Hash{0x02}, base, nil, map[common.Hash][]byte{acc2: {0x02}}, map[common.Hash]map[common.Hash][]byte{acc1: {slot1: {0x21}}}); err != nil {
+		t.Fatalf("failed to create first diff layer: %v", err)
+	}
+	if err := snaps.Update(common.Hash{0x03}, common.Hash{0x02}, map[common.Hash]struct{}{acc1: {}}, nil, nil); err != nil {
+		t.Fatalf("failed to create second diff layer: %v", err)
+	}
+	tests := []struct {
+		root    common.Hash
+		account common.Hash
+		slot    *common.Hash
+		want    []byte
+	}{
+		{base, acc1, nil, []byte{0x01}},
+		{base, acc1, &slot1, []byte{0x11}},
+		{base, acc2, nil, nil},
+		{common.Hash{0x02}, acc1, nil, []byte{0x01}},
+		{common.Hash{0x02}, acc1, &slot1, []byte{0x21}},
+		{common.Hash{0x02}, acc1, &slot2, []byte{0x12}},
+		{common.Hash{0x02}, acc2, nil, []byte{0x02}},
+		{common.Hash{0x03}, acc1, nil, nil},
+		{common.Hash{0x03}, acc1, &slot1, nil},
+		{common.Hash{0x03}, acc1, &slot2, nil},
+		{common.Hash{0x03}, acc2, nil, []byte{0x02}},
+	}
+	for i, tt := range tests {
+		var (
+			have []byte
+			err  error
+		)
+		if tt.slot == nil {
+			have, err = snaps.Snapshot(tt.root).Account(tt.account)
+		} else {
+			have, err = snaps.Snapshot(tt.root).Storage(tt.account, *tt.slot)
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to retrieve data: %v", i, err)
+		} else if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: data mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that capping the tree flattens the old layers into the disk, dropping
+// the sibling branches (reorged out chains) that don't build on it anymore,
+// while the branches that do are still accessible.
+func TestCapFlattensLayers(t *testing.T) {
+	db, release := newTestDatabase(t)
+	defer release()
+
+	base := common.Hash{0x01}
+	snaps := newTestTree(db, base)
+
+	// Create a chain of 4 layers with a fork at the first one
+	var (
+		parent = base
+		acc    = common.Hash{0xaa}
+	)
+	for i := 2; i <= 5; i++ {
+		root := common.Hash{byte(i)}
+		if err := snaps.Update(root, parent, nil, map[common.Hash][]byte{acc: {byte(i)}}, nil); err != nil {
+			t.Fatalf("failed to create diff layer %d: %v", i, err)
+		}
+		parent = root
+	}
+	fork := common.Hash{0xff}
+	if err := snaps.Update(fork, common.Hash{0x02}, nil, map[common.Hash][]byte{acc: {0xff}}, nil); err != nil {
+		t.Fatalf("failed to create fork layer: %v", err)
+	}
+	sibling := common.Hash{0xfe}
+	if err := snaps.Update(sibling, common.Hash{0x03}, nil, map[common.Hash][]byte{acc: {0xfe}}, nil); err != nil {
+		t.Fatalf("failed to create sibling layer: %v", err)
+	}
+	// Before flattening, both branches need to be readable
+	if blob, _ := snaps.Snapshot(fork).Account(acc); !bytes.Equal(blob, []byte{0xff}) {
+		t.Fatalf("fork account mismatch: have %x, want ff", blob)
+	}
+	stale := snaps.Snapshot(common.Hash{0x03})
+
+	// Flatten all but the top 2 layers and check the results
+	if err := snaps.Cap(common.Hash{0x05}, 2); err != nil {
+		t.Fatalf("failed to cap tree: %v", err)
+	}
+	if snaps.Snapshot(fork) != nil {
+		t.Errorf("reorged out fork retained")
+	}
+	if snaps.Snapshot(sibling) == nil {
+		t.Errorf("sibling building on the disk layer dropped")
+	}
+	if _, ok := snaps.Snapshot(common.Hash{0x03}).(*diskLayer); !ok {
+		t.Errorf("disk layer not moved to the flattened root")
+	}
+	if _, err := stale.Account(acc); err != ErrSnapshotStale {
+		t.Errorf("flattened layer error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	for root, want := range map[common.Hash]byte{{0x03}: 0x03, {0x04}: 0x04, {0x05}: 0x05, sibling: 0xfe} {
+		if blob, err := snaps.Snapshot(root).Account(acc); err != nil || !bytes.Equal(blob, []byte{want}) {
+			t.Errorf("root %x: account mismatch: have %x/%v, want %x", root[:1], blob, err, want)
+		}
+	}
+	// Flatten everything and ensure the snapshot is reloaded from disk
+	if err := snaps.Cap(common.Hash{0x05}, 0); err != nil {
+		t.Fatalf("failed to flatten tree: %v", err)
+	}
+	snaps = New(db, trie.NewDatabase(db), common.Hash{0x05})
+	disk, ok := snaps.Snapshot(common.Hash{0x05}).(*diskLayer)
+	if !ok || disk.genMarker != nil {
+		t.Fatalf("persisted snapshot not loaded")
+	}
+	if blob, _ := disk.Account(acc); !bytes.Equal(blob, []byte{0x05}) {
+		t.Errorf("persisted account mismatch: have %x, want 05", blob)
+	}
+}
+
+// Tests that a missing snapshot is generated from the state trie in the
+// background, and that a snapshot of the wrong root is regenerated.
+func TestGeneration(t *testing.T) {
+	db, release := newTestDatabase(t)
+	defer release()
+
+	// Create a state trie with a handful of accounts, some having storage
+	var (
+		triedb     = trie.NewDatabase(db)
+		accTrie, _ = trie.NewSecure(common.Hash{}, triedb, 0)
+		slots      = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	for i := 0; i < 100; i++ {
+		acc := account{Balance: big.NewInt(int64(i)), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)}
+		addr := randomHash(0x00, i)
+
+		if i%10 == 0 {
+			stTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+			slots[crypto.Keccak256Hash(addr[:])] = make(map[common.Hash][]byte)
+			for j := 0; j < 20; j++ {
+				key, val := randomHash(byte(i), j), []byte{0x80 | byte(j)}
+				stTrie.Update(key[:], val)
+				slots[crypto.Keccak256Hash(addr[:])][crypto.Keccak256Hash(key[:])] = val
+			}
+			acc.Root, _ = stTrie.Commit(nil)
+			triedb.Commit(acc.Root, false)
+		}
+		blob, _ := rlp.EncodeToBytes(acc)
+		accTrie.Update(addr[:], blob)
+	}
+	root, _ := accTrie.Commit(nil)
+	triedb.Commit(root, false)
+
+	// Insert some junk that needs to be wiped and generate the snapshot
+	junk := common.Hash{0xde, 0xad}
+	db.Put(accountKey(junk), []byte{0x01})
+	db.Put(snapshotRootKey, common.Hash{0x01}.Bytes())
+
+	snaps := New(db, triedb, root)
+	disk := waitGeneration(t, snaps, root)
+
+	if blob, err := disk.Account(junk); err != nil || blob != nil {
+		t.Errorf("stale snapshot entry not wiped: %x/%v", blob, err)
+	}
+	for i := 0; i < 100; i++ {
+		addr := randomHash(0x00, i)
+		want, _ := accTrie.TryGet(addr[:])
+
+		hash := crypto.Keccak256Hash(addr[:])
+		if have, err := disk.Account(hash); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("account %d: mismatch: have %x/%v, want %x", i, have, err, want)
+		}
+		for slot, want := range slots[hash] {
+			if have, err := disk.Storage(hash, slot); err != nil || !bytes.Equal(have, want) {
+				t.Errorf("account %d, slot %x: mismatch: have %x/%v, want %x", i, slot, have, err, want)
+			}
+		}
+	}
+	if blob, _ := db.Get(snapshotRootKey); !bytes.Equal(blob, root[:]) {
+		t.Errorf("generated snapshot root mismatch: have %x, want %x", blob, root)
+	}
+	if blob, _ := db.Get(snapshotGeneratorKey); blob != nil {
+		t.Errorf("generator marker not cleaned up")
+	}
+}
@@ -17,13 +17,16 @@
 package state
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"math/big"
 	"testing"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
 	checker "gopkg.in/check.v1"
 )
 
@@ -86,6 +89,169 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	}
 }
 
+// Tests that the streaming and paginated dumps iterate over all the accounts
+// in hash order, honouring the configured filters and limits.
+func TestIterativeDump(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+	for i := byte(1); i <= 10; i++ {
+		addr := common.Address{i}
+		state.SetBalance(addr, big.NewInt(int64(i)))
+		state.SetCode(addr, []byte{i})
+		state.Selbchain-devate(addr, common.Hash{i}, common.Hash{i})
+	}
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+
+	// Stream the whole state without code and ensure every account is present
+	var buf bytes.Buffer
+	if err := state.IterativeDump(&DumpConfig{SkipCode: true}, &buf); err != nil {
+		t.Fatalf("failed to dump state: %v", err)
+	}
+	var (
+		scanner = bufio.NewScanner(&buf)
+		header  struct{ Root string }
+		keys    [][]byte
+	)
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil || header.Root != common.Bytes2Hex(root[:]) {
+		t.Fatalf("invalid dump header: %s", scanner.Text())
+	}
+	for scanner.Scan() {
+		var account DumpAccount
+		if err := json.Unmarshal(scanner.Bytes(), &account); err != nil {
+			t.Fatalf("failed to decode account line %s: %v", scanner.Text(), err)
+		}
+		if account.Address == nil || !bytes.Equal(account.Key, crypto.Keccak256(account.Address[:])) {
+			t.Errorf("account %x: address mismatch: %v", account.Key, account.Address)
+		}
+		if account.Code != "" || len(account.Storage) != 1 {
+			t.Errorf("account %x: unexpected content: code %q, storage %v", account.Key, account.Code, account.Storage)
+		}
+		if len(keys) > 0 && bytes.Compare(keys[len(keys)-1], account.Key) >= 0 {
+			t.Errorf("account %x: out of order", account.Key)
+		}
+		keys = append(keys, account.Key)
+	}
+	if len(keys) != 10 {
+		t.Fatalf("dumped account count mismatch: have %d, want %d", len(keys), 10)
+	}
+	// Page through the state and ensure the same accounts are returned
+	var (
+		start []byte
+		paged [][]byte
+	)
+	for {
+		page, err := state.IteratorDump(&DumpConfig{SkipStorage: true, Start: start, Max: 3})
+		if err != nil {
+			t.Fatalf("failed to dump state page: %v", err)
+		}
+		if len(page.Accounts) > 3 {
+			t.Fatalf("page too large: have %d, want at most %d", len(page.Accounts), 3)
+		}
+		for _, account := range page.Accounts {
+			if account.Code == "" || account.Storage != nil {
+				t.Errorf("account %x: unexpected content: code %q, storage %v", account.Key, account.Code, account.Storage)
+			}
+			paged = append(paged, account.Key)
+		}
+		if page.Next == nil {
+			break
+		}
+		start = page.Next
+	}
+	if len(paged) != len(keys) {
+		t.Fatalf("paged account count mismatch: have %d, want %d", len(paged), len(keys))
+	}
+	for i := range keys {
+		if !bytes.Equal(paged[i], keys[i]) {
+			t.Errorf("account %d: key mismatch: have %x, want %x", i, paged[i], keys[i])
+		}
+	}
+	// Starting from an address should start from its hash
+	page, err := state.IteratorDump(&DumpConfig{Start: DumpStartKey(common.Address{5}.Bytes()), Max: 1})
+	if err != nil {
+		t.Fatalf("failed to dump from address: %v", err)
+	}
+	if len(page.Accounts) != 1 || *page.Accounts[0].Address != (common.Address{5}) {
+		t.Errorf("dump from address mismatch: have %+v", page.Accounts)
+	}
+}
+
+// Tests that dumping a state with missing code or storage reports the failure
+// instead of crashing.
+func TestRawDumpMissingData(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.Address{0x01}
+	state.SetCode(addr, []byte{0x01, 0x02, 0x03})
+	state.Selbchain-devate(addr, common.Hash{0x01}, common.Hash{0x01})
+	root, _ := state.Commit(false)
+	if err := state.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	// Delete the code and the storage root from the database
+	db.Delete(state.GetCodeHash(addr).Bytes())
+	db.Delete(state.StorageTrie(addr).Hash().Bytes())
+
+	for i, conf := range []*DumpConfig{{SkipStorage: true}, {SkipCode: true}} {
+		state, _ := New(root, NewDatabase(db))
+		if _, err := state.IteratorDump(conf); err == nil {
+			t.Errorf("test %d: missing data not reported", i)
+		}
+	}
+	state, _ = New(root, NewDatabase(db))
+	if _, err := state.RawDump(); err == nil {
+		t.Errorf("missing data not reported by raw dump")
+	}
+}
+
+// Tests that the storage of the dumped accounts is capped if requested, with the
+// slot hash to continue from returned for the accounts having more.
+func TestIterativeDumpStorageLimit(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+	for i := byte(1); i <= 10; i++ {
+		state.Selbchain-devate(common.Address{1}, common.Hash{i}, common.Hash{i})
+	}
+	state.Selbchain-devate(common.Address{2}, common.Hash{1}, common.Hash{1})
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+
+	page, err := state.IteratorDump(&DumpConfig{SkipCode: true, MaxStorage: 4})
+	if err != nil {
+		t.Fatalf("failed to dump state: %v", err)
+	}
+	if len(page.Accounts) != 2 {
+		t.Fatalf("dumped account count mismatch: have %d, want %d", len(page.Accounts), 2)
+	}
+	for _, account := range page.Accounts {
+		switch *account.Address {
+		case common.Address{1}:
+			if len(account.Storage) != 4 {
+				t.Errorf("capped storage size mismatch: have %d, want %d", len(account.Storage), 4)
+			}
+			// The remaining slots must start at the continuation key
+			tr, _ := state.Database().OpenStorageTrie(common.BytesToHash(account.Key), state.StorageTrie(common.Address{1}).Hash())
+			it := trie.NewIterator(tr.NodeIterator(account.StorageNext))
+			rest := 0
+			for it.Next() {
+				if _, ok := account.Storage[common.Bytes2Hex(state.trie.GetKey(it.Key))]; ok {
+					t.Errorf("slot %x returned twice", it.Key)
+				}
+				rest++
+			}
+			if rest != 6 {
+				t.Errorf("remaining slot count mismatch: have %d, want %d", rest, 6)
+			}
+		case common.Address{2}:
+			if len(account.Storage) != 1 || account.StorageNext != nil {
+				t.Errorf("uncapped storage mismatch: have %v, next %x", account.Storage, account.StorageNext)
+			}
+		}
+	}
+}
+
 func (s *StateSuite) SetUpTest(c *checker.C) {
 	s.db, _ = lbchain-devdb.NewMemDatabase()
 	s.state, _ = New(common.Hash{}, NewDatabase(s.db))
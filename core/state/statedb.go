@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
 	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/log"
@@ -53,6 +54,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat state snapshot consulted ahead of the trie, and the changes to push
+	// into it on commit. All of them are nil if no snapshot is available.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -80,6 +89,14 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Per transaction state diffs, only recorded if enabled
+	diffs      []*TxStateDiff
+	diffMark   int  // Number of journal entries already recorded
+	diffSealed bool // Current transaction already finalised, later changes belong to the block
+
+	// State read by the current transaction, only tracked if enabled
+	reads map[AccessKey]struct{}
+
 	lock sync.Mutex
 }
 
@@ -89,14 +106,31 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snapshots(db),
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot attaches the flat state snapshot layer of the given root, if one
+// is maintained, discarding any snapshot changes not yet committed.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -126,6 +160,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
 	self.preimages = make(map[common.Hash][]byte)
+	self.openSnapshot(root)
 	self.clearJournalAndRefund()
 	return nil
 }
@@ -176,18 +211,21 @@ func (self *StateDB) AddRefund(gas uint64) {
 // Exist reports whlbchain-dever the given account address exists in the state.
 // Notably this also returns true for suicided accounts.
 func (self *StateDB) Exist(addr common.Address) bool {
+	self.read(addr, AccessExist)
 	return self.gelbchain-devateObject(addr) != nil
 }
 
 // Empty returns whlbchain-dever the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (self *StateDB) Empty(addr common.Address) bool {
+	self.read(addr, AccessExist, AccessBalance, AccessNonce, AccessCode)
 	so := self.gelbchain-devateObject(addr)
 	return so == nil || so.empty()
 }
 
 // Retrieve the balance from the given address or 0 if object not found
 func (self *StateDB) GetBalance(addr common.Address) *big.Int {
+	self.read(addr, AccessBalance)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -196,6 +234,7 @@ func (self *StateDB) GetBalance(addr common.Address) *big.Int {
 }
 
 func (self *StateDB) GetNonce(addr common.Address) uint64 {
+	self.read(addr, AccessNonce)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -205,6 +244,7 @@ func (self *StateDB) GetNonce(addr common.Address) uint64 {
 }
 
 func (self *StateDB) GetCode(addr common.Address) []byte {
+	self.read(addr, AccessCode)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code(self.db)
@@ -213,6 +253,7 @@ func (self *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (self *StateDB) GetCodeSize(addr common.Address) int {
+	self.read(addr, AccessCode)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject == nil {
 		return 0
@@ -228,6 +269,7 @@ func (self *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	self.read(addr, AccessCode)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -236,6 +278,9 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 }
 
 func (self *StateDB) Gelbchain-devate(a common.Address, b common.Hash) common.Hash {
+	if self.reads != nil {
+		self.reads[AccessKey{Address: a, Kind: AccessStorage, Slot: b}] = struct{}{}
+	}
 	stateObject := self.gelbchain-devateObject(a)
 	if stateObject != nil {
 		return stateObject.Gelbchain-devate(self.db, b)
@@ -248,6 +293,20 @@ func (self *StateDB) Database() Database {
 	return self.db
 }
 
+// Accessed returns the accounts loaded into the state so far, along with the
+// storage slots loaded from each of them.
+func (self *StateDB) Accessed() map[common.Address][]common.Hash {
+	accessed := make(map[common.Address][]common.Hash, len(self.stateObjects))
+	for addr, obj := range self.stateObjects {
+		slots := make([]common.Hash, 0, len(obj.cachedStorage))
+		for key := range obj.cachedStorage {
+			slots = append(slots, key)
+		}
+		accessed[addr] = slots
+	}
+	return accessed
+}
+
 // StorageTrie returns the storage trie of an account.
 // The return value is a copy and is nil for non-existent accounts.
 func (self *StateDB) StorageTrie(a common.Address) Trie {
@@ -259,7 +318,37 @@ func (self *StateDB) StorageTrie(a common.Address) Trie {
 	return cpy.updateTrie(self.db)
 }
 
+// proofList collects the encoded nodes of a Merkle proof in root to leaf order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+// AccountProof returns the Merkle proof of an account in the account trie. The
+// proof is made against the trie as of the last root hash calculation, so any
+// pending changes need to be hashed in before.
+func (self *StateDB) AccountProof(a common.Address) ([][]byte, error) {
+	var proof proofList
+	err := self.trie.Prove(crypto.Keccak256(a.Bytes()), 0, &proof)
+	return proof, err
+}
+
+// StorageProof returns the Merkle proof of a storage slot in the storage trie
+// of an account. An error is returned for non-existent accounts.
+func (self *StateDB) StorageProof(a common.Address, key common.Hash) ([][]byte, error) {
+	tr := self.StorageTrie(a)
+	if tr == nil {
+		return nil, fmt.Errorf("storage trie for %x missing", a)
+	}
+	var proof proofList
+	err := tr.Prove(crypto.Keccak256(key.Bytes()), 0, &proof)
+	return proof, err
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
+	self.read(addr, AccessExist)
 	stateObject := self.gelbchain-devateObject(addr)
 	if stateObject != nil {
 		return stateObject.suicided
@@ -348,6 +437,11 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	// If state snapshotting is active, cache the data til commit
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -355,6 +449,13 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	// If state snapshotting is active, drop any pending changes of the account
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -367,8 +468,18 @@ func (self *StateDB) gelbchain-devateObject(addr common.Address) (stateObject *s
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, falling back to the trie
+	// if it's missing or still being generated.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.Account(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -409,10 +520,19 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 	prev = self.gelbchain-devateObject(addr)
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
+
+	// An overwritten account loses its storage, track it for the snapshot
+	var prevdestruct bool
+	if self.snap != nil && prev != nil {
+		_, prevdestruct = self.snapDestructs[prev.addrHash]
+		if !prevdestruct {
+			self.snapDestructs[prev.addrHash] = struct{}{}
+		}
+	}
 	if prev == nil {
 		self.journal = append(self.journal, createObjectChange{account: &addr})
 	} else {
-		self.journal = append(self.journal, resetObjectChange{prev: prev})
+		self.journal = append(self.journal, resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	self.selbchain-devateObject(newobj)
 	return newobj, prev
@@ -424,17 +544,34 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 // CreateAccount is called during the EVM CREATE operation. The situation might arise that
 // a contract does the following:
 //
-//   1. sends funds to sha(account ++ (nonce + 1))
-//   2. tx_create(sha(account ++ nonce)) (note that this gets the address of 1)
+//  1. sends funds to sha(account ++ (nonce + 1))
+//  2. tx_create(sha(account ++ nonce)) (note that this gets the address of 1)
 //
 // Carrying over the balance ensures that lbchain-dever doesn't disappear.
 func (self *StateDB) CreateAccount(addr common.Address) {
+	self.read(addr, AccessExist, AccessBalance)
 	new, prev := self.createObject(addr)
 	if prev != nil {
 		new.setBalance(prev.data.Balance)
 	}
 }
 
+// SetStorage replaces the entire storage of an account with the given one,
+// retaining its balance, nonce and code.
+func (self *StateDB) SetStorage(addr common.Address, storage map[common.Hash]common.Hash) {
+	new, prev := self.createObject(addr)
+	if prev != nil {
+		new.setBalance(prev.data.Balance)
+		new.setNonce(prev.data.Nonce)
+		if code := prev.Code(self.db); len(code) > 0 {
+			new.setCode(common.BytesToHash(prev.CodeHash()), code)
+		}
+	}
+	for key, value := range storage {
+		new.Selbchain-devate(self.db, key, value)
+	}
+}
+
 func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
 	so := db.gelbchain-devateObject(addr)
 	if so == nil {
@@ -466,6 +603,8 @@ func (self *StateDB) Copy() *StateDB {
 	state := &StateDB{
 		db:                self.db,
 		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            self.refund,
@@ -485,6 +624,24 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	// Copy the pending snapshot changes, the layers themselves are immutable
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, storage := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(storage))
+			for key, data := range storage {
+				state.snapStorage[hash][key] = data
+			}
+		}
+	}
 	return state
 }
 
@@ -525,6 +682,9 @@ func (self *StateDB) GetRefund() uint64 {
 // Finalise finalises the state by removing the self destructed objects
 // and clears the journal as well as the refunds.
 func (s *StateDB) Finalise(deleteEmptyObjects bool) {
+	s.recordDiff(deleteEmptyObjects)
+	s.diffSealed = true
+
 	for addr := range s.stateObjectsDirty {
 		stateObject := s.stateObjects[addr]
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
@@ -549,9 +709,13 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 // Prepare sets the current transaction hash and index and block hash which is
 // used when the EVM emits new state logs.
 func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
+	// Attribute any pending changes to the previous transaction before switching
+	self.recordDiff(false)
+
 	self.thash = thash
 	self.bhash = bhash
 	self.txIndex = ti
+	self.diffSealed = false
 }
 
 // DeleteSuicides flags the suicided objects for deletion so that it
@@ -577,12 +741,14 @@ func (s *StateDB) DeleteSuicides() {
 
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = nil
+	s.diffMark = 0
 	s.validRevisions = s.validRevisions[:0]
 	s.refund = 0
 }
 
 // Commit writes the state to the underlying in-memory trie database.
 func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error) {
+	s.recordDiff(deleteEmptyObjects)
 	defer s.clearJournalAndRefund()
 
 	// Commit objects to the trie.
@@ -624,5 +790,15 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		return nil
 	})
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// If snapshotting is enabled, push the changes as a new layer into the tree
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.openSnapshot(root)
+	}
 	return root, err
 }
@@ -20,18 +20,23 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/big"
 	"math/rand"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	check "gopkg.in/check.v1"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state/snapshot"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 )
 
@@ -168,6 +173,45 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+// Tests that replacing the storage of an account drops all of its previous slots,
+// both committed and dirty, while retaining the rest of the account.
+func TestSetStorage(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.Address{0x01}
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetNonce(addr, 7)
+	state.SetCode(addr, []byte{0x60, 0x00})
+	state.Selbchain-devate(addr, common.Hash{0x01}, common.Hash{0x01})
+
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+	state.Selbchain-devate(addr, common.Hash{0x02}, common.Hash{0x02})
+
+	state.SetStorage(addr, map[common.Hash]common.Hash{{0x03}: {0x03}})
+
+	for i := 0; i < 2; i++ {
+		if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(42)) != 0 {
+			t.Errorf("pass %d: balance mismatch: have %v, want %v", i, balance, 42)
+		}
+		if nonce := state.GetNonce(addr); nonce != 7 {
+			t.Errorf("pass %d: nonce mismatch: have %d, want %d", i, nonce, 7)
+		}
+		if code := state.GetCode(addr); !bytes.Equal(code, []byte{0x60, 0x00}) {
+			t.Errorf("pass %d: code mismatch: have %x, want %x", i, code, []byte{0x60, 0x00})
+		}
+		for slot, want := range map[common.Hash]common.Hash{{0x01}: {}, {0x02}: {}, {0x03}: {0x03}} {
+			if have := state.Gelbchain-devate(addr, slot); have != want {
+				t.Errorf("pass %d: slot %x mismatch: have %x, want %x", i, slot, have, want)
+			}
+		}
+		// Ensure the replacement survives a commit too
+		root, _ = state.Commit(false)
+		state, _ = New(root, state.Database())
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -421,3 +465,99 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that states backed by a flat snapshot read the same data as states that
+// walk the trie, across account deletions, recreations and storage changes.
+func TestFlatSnapshotReads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state-snapshot")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := lbchain-devdb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a base state and a snapshot tree on top of it
+	var (
+		triedb = NewDatabase(db)
+		addrs  = []common.Address{{0x01}, {0x02}, {0x03}}
+		slot   = common.Hash{0xaa}
+	)
+	state, _ := New(common.Hash{}, triedb)
+	for i, addr := range addrs {
+		state.SetBalance(addr, big.NewInt(int64(i+1)))
+		state.Selbchain-devate(addr, slot, common.Hash{byte(i + 1)})
+	}
+	root, _ := state.Commit(false)
+	triedb.TrieDB().Commit(root, false)
+
+	snaps := snapshot.New(db, triedb.TrieDB(), root)
+	sdb := NewDatabaseWithSnapshots(triedb, snaps)
+
+	// Wait for the snapshot of the base state to be generated
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := snaps.Snapshot(root).Account(crypto.Keccak256Hash(addrs[0][:])); err == nil {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("snapshot generation timed out")
+		}
+	}
+	// Mutate the state in a few consecutive blocks through the snapshot
+	blocks := []func(state *StateDB){
+		func(state *StateDB) {
+			state.AddBalance(addrs[0], big.NewInt(10))
+			state.Selbchain-devate(addrs[1], slot, common.Hash{0xff})
+		},
+		func(state *StateDB) {
+			state.Suicide(addrs[1])
+			state.Finalise(true)
+			state.CreateAccount(addrs[2])
+			state.SetNonce(addrs[2], 1)
+			if val := state.Gelbchain-devate(addrs[2], slot); val != (common.Hash{}) {
+				t.Errorf("recreated account storage retained: %x", val)
+			}
+		},
+		func(state *StateDB) {
+			state.AddBalance(addrs[1], big.NewInt(5))
+			state.Selbchain-devate(addrs[0], slot, common.Hash{})
+		},
+	}
+	for i, block := range blocks {
+		state, err := New(root, sdb)
+		if err != nil {
+			t.Fatalf("block %d: failed to open state: %v", i, err)
+		}
+		if state.snap == nil {
+			t.Fatalf("block %d: snapshot layer missing", i)
+		}
+		block(state)
+		if root, err = state.Commit(true); err != nil {
+			t.Fatalf("block %d: failed to commit state: %v", i, err)
+		}
+		if snaps.Snapshot(root) == nil {
+			t.Fatalf("block %d: snapshot layer not created", i)
+		}
+		// Compare the flat reads with the trie based ones
+		flat, _ := New(root, sdb)
+		tried, _ := New(root, triedb)
+		for _, addr := range addrs {
+			if flat.Exist(addr) != tried.Exist(addr) {
+				t.Errorf("block %d, %x: existence mismatch: flat %v, trie %v", i, addr, flat.Exist(addr), tried.Exist(addr))
+			}
+			if flat.GetBalance(addr).Cmp(tried.GetBalance(addr)) != 0 {
+				t.Errorf("block %d, %x: balance mismatch: flat %v, trie %v", i, addr, flat.GetBalance(addr), tried.GetBalance(addr))
+			}
+			if flat.GetNonce(addr) != tried.GetNonce(addr) {
+				t.Errorf("block %d, %x: nonce mismatch: flat %v, trie %v", i, addr, flat.GetNonce(addr), tried.GetNonce(addr))
+			}
+			if flat.Gelbchain-devate(addr, slot) != tried.Gelbchain-devate(addr, slot) {
+				t.Errorf("block %d, %x: storage mismatch: flat %x, trie %x", i, addr, flat.Gelbchain-devate(addr, slot), tried.Gelbchain-devate(addr, slot))
+			}
+		}
+	}
+}
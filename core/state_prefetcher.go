@@ -0,0 +1,227 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+// errPrefetchInterrupted is returned if a transaction isn't precached due to the
+// prefetch being interrupted.
+var errPrefetchInterrupted = errors.New("prefetch interrupted")
+
+var (
+	prefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
+	prefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
+
+	prefetchAccountHitMeter  = metrics.NewRegisteredMeter("chain/prefetch/account/hits", nil)
+	prefetchAccountMissMeter = metrics.NewRegisteredMeter("chain/prefetch/account/misses", nil)
+	prefetchStorageHitMeter  = metrics.NewRegisteredMeter("chain/prefetch/storage/hits", nil)
+	prefetchStorageMissMeter = metrics.NewRegisteredMeter("chain/prefetch/storage/misses", nil)
+)
+
+// PrefetchInterrupt signals a running prefetch to stop, cancelling the execution
+// of the transaction in flight instead of waiting for it to finish.
+type PrefetchInterrupt struct {
+	flag uint32
+	lock sync.Mutex
+	evm  *vm.EVM // EVM executing the transaction in flight, if any
+}
+
+// Interrupt stops the prefetch and cancels the transaction in flight.
+func (i *PrefetchInterrupt) Interrupt() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	atomic.StoreUint32(&i.flag, 1)
+	if i.evm != nil {
+		i.evm.Cancel()
+	}
+}
+
+// Interrupted reports whlbchain-dever the prefetch was interrupted. A nil interrupt is
+// never signalled.
+func (i *PrefetchInterrupt) Interrupted() bool {
+	return i != nil && atomic.LoadUint32(&i.flag) == 1
+}
+
+// track sets the EVM executing the transaction in flight, to be cancelled on
+// interruption. It returns false if the prefetch was already interrupted.
+func (i *PrefetchInterrupt) track(evm *vm.EVM) bool {
+	if i == nil {
+		return true
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.evm = evm
+	return atomic.LoadUint32(&i.flag) == 0
+}
+
+// statePrefetcher is a basic Prefetcher, which blindly executes a block on top
+// of an arbitrary state with the goal of prefetching potentially useful state
+// data from disk before the main block processor starts executing.
+type statePrefetcher struct {
+	config *params.ChainConfig // Chain configuration options
+	bc     *BlockChain         // Canonical block chain
+	engine consensus.Engine    // Consensus engine used for block rewards
+}
+
+// newStatePrefetcher initialises a new statePrefetcher.
+func newStatePrefetcher(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *statePrefetcher {
+	return &statePrefetcher{
+		config: config,
+		bc:     bc,
+		engine: engine,
+	}
+}
+
+// Prefetch processes the state changes according to the lbchain-devchain rules by running
+// the transaction messages using the statedb, but any changes are discarded. The
+// only goal is to pre-cache transaction signatures and state trie nodes.
+func (p *statePrefetcher) Prefetch(block *types.Block, statedb *state.StateDB, cfg vm.Config, interrupt *PrefetchInterrupt) {
+	var (
+		header  = block.Header()
+		gaspool = new(GasPool).AddGas(block.GasLimit())
+	)
+	// Iterate over and process the individual transactions, ignoring failures as
+	// the state is not the exact parent of the block
+	for i, tx := range block.Transactions() {
+		// If block precaching was interrupted, abort
+		if interrupt.Interrupted() {
+			return
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if err := precacheTransaction(p.config, p.bc, gaspool, statedb, header, tx, cfg, interrupt); err != nil {
+			continue
+		}
+	}
+	// Hash the state to pull in the trie nodes needed for the updates and deletions
+	if interrupt.Interrupted() {
+		return
+	}
+	statedb.IntermediateRoot(p.config.IsEIP158(block.Number()))
+}
+
+// precacheTransaction attempts to apply a transaction to the given state database
+// and uses the input parameters for its environment. The goal is not to execute
+// the transaction successfully, rather to warm up touched data slots. The nonce
+// is not checked, as transactions of the preceding block are not yet applied.
+// The execution is cancelled if the prefetch gets interrupted.
+func precacheTransaction(config *params.ChainConfig, bc ChainContext, gaspool *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, cfg vm.Config, interrupt *PrefetchInterrupt) error {
+	// Convert the transaction into an executable message and pre-cache its sender
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	if err != nil {
+		return err
+	}
+	msg = types.NewMessage(msg.From(), msg.To(), msg.Nonce(), msg.Value(), msg.Gas(), msg.GasPrice(), msg.Data(), false)
+
+	// Create the EVM and execute the transaction
+	context := NewEVMContext(msg, header, bc, nil)
+	vm := vm.NewEVM(context, statedb, config, cfg)
+	if !interrupt.track(vm) {
+		return errPrefetchInterrupted
+	}
+	defer interrupt.track(nil)
+
+	_, err = ApplyMessage(vm, msg, gaspool)
+	statedb.Finalise(config.IsEIP158(header.Number))
+	return err
+}
+
+// prefetchTask is the speculative execution of a block running in the background
+// while its parent is being processed.
+type prefetchTask struct {
+	block     common.Hash
+	interrupt PrefetchInterrupt
+	accessed  chan map[common.Address][]common.Hash // State accessed by the prefetch, delivered once done
+}
+
+// prefetch starts executing a block on top of the given state root in the
+// background, with all changes discarded. Nil is returned if the state is not
+// available.
+func (bc *BlockChain) prefetch(block *types.Block, root common.Hash) *prefetchTask {
+	throwaway, err := state.New(root, bc.stateCache)
+	if err != nil {
+		return nil
+	}
+	task := &prefetchTask{
+		block:    block.Hash(),
+		accessed: make(chan map[common.Address][]common.Hash, 1),
+	}
+	go func(start time.Time) {
+		bc.prefetcher.Prefetch(block, throwaway, bc.vmConfig, &task.interrupt)
+
+		prefetchExecuteTimer.UpdateSince(start)
+		if task.interrupt.Interrupted() {
+			prefetchInterruptMeter.Mark(1)
+		}
+		task.accessed <- throwaway.Accessed()
+	}(time.Now())
+
+	return task
+}
+
+// stop interrupts the prefetch, cancelling the transaction in flight, without
+// waiting for it to return.
+func (t *prefetchTask) stop() {
+	t.interrupt.Interrupt()
+}
+
+// report interrupts the prefetch, cancelling the transaction in flight, and
+// measures how much of the state accessed by the actual processing of the block
+// was prefetched.
+func (t *prefetchTask) report(block *types.Block, statedb *state.StateDB) {
+	if t.block != block.Hash() {
+		return
+	}
+	t.stop()
+	prefetched := <-t.accessed
+
+	for addr, slots := range statedb.Accessed() {
+		fetched, ok := prefetched[addr]
+		if !ok {
+			prefetchAccountMissMeter.Mark(1)
+			prefetchStorageMissMeter.Mark(int64(len(slots)))
+			continue
+		}
+		prefetchAccountHitMeter.Mark(1)
+
+		known := make(map[common.Hash]struct{}, len(fetched))
+		for _, slot := range fetched {
+			known[slot] = struct{}{}
+		}
+		for _, slot := range slots {
+			if _, ok := known[slot]; ok {
+				prefetchStorageHitMeter.Mark(1)
+			} else {
+				prefetchStorageMissMeter.Mark(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+// Tests that prefetching a block on top of the state preceding its parent loads
+// the state it accesses, and that interrupting it stops the execution.
+func TestStatePrefetch(t *testing.T) {
+	var (
+		db, _    = lbchain-devdb.NewMemDatabase()
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000)},
+				// Increments the value of the storage slot 1
+				contract: {Balance: new(big.Int), Code: []byte{0x60, 0x01, 0x54, 0x60, 0x01, 0x01, 0x60, 0x01, 0x55, 0x00}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), contract, big.NewInt(1), 100000, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	blockchain, _ := NewBlockChain(db, &CacheConfig{NoPrefetch: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	prefetcher := newStatePrefetcher(gspec.Config, blockchain, ethash.NewFaker())
+
+	// Prefetch the second block on the genesis state, its nonce being off by one
+	throwaway, _ := state.New(genesis.Root(), blockchain.stateCache)
+	prefetcher.Prefetch(blocks[1], throwaway, vm.Config{}, nil)
+
+	accessed := throwaway.Accessed()
+	if _, ok := accessed[address]; !ok {
+		t.Errorf("sender not prefetched")
+	}
+	if slots := accessed[contract]; len(slots) != 1 || slots[0] != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("contract storage not prefetched: have %x", slots)
+	}
+	// The prefetch must not have modified any state
+	if root := blockchain.CurrentBlock().Root(); root != genesis.Root() {
+		t.Errorf("head state changed: have %x, want %x", root, genesis.Root())
+	}
+	// An interrupted prefetch shouldn't execute anything
+	interrupt := new(PrefetchInterrupt)
+	interrupt.Interrupt()
+
+	throwaway, _ = state.New(genesis.Root(), blockchain.stateCache)
+	prefetcher.Prefetch(blocks[1], throwaway, vm.Config{}, interrupt)
+
+	if accessed := throwaway.Accessed(); len(accessed) != 0 {
+		t.Errorf("interrupted prefetch accessed %d accounts", len(accessed))
+	}
+}
+
+// Tests that interrupting a prefetch cancels the transaction in flight instead of
+// waiting for it to finish.
+func TestStatePrefetchCancel(t *testing.T) {
+	var (
+		db, _    = lbchain-devdb.NewMemDatabase()
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		gspec    = &Genesis{
+			Config:   params.TestChainConfig,
+			GasLimit: math.MaxUint64 / 2,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000)},
+				// Loops forever (until running out of gas)
+				contract: {Balance: new(big.Int), Code: []byte{0x5b, 0x60, 0x00, 0x56}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blockchain, _ := NewBlockChain(db, &CacheConfig{NoPrefetch: true}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	// Assemble a block with a transaction practically never finishing on its own
+	tx, _ := types.SignTx(types.NewTransaction(0, contract, new(big.Int), genesis.GasLimit(), new(big.Int), nil), signer, key)
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   genesis.GasLimit(),
+		Difficulty: genesis.Difficulty(),
+		Time:       new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+	}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+
+	var (
+		prefetcher   = newStatePrefetcher(gspec.Config, blockchain, ethash.NewFaker())
+		throwaway, _ = state.New(genesis.Root(), blockchain.stateCache)
+		interrupt    = new(PrefetchInterrupt)
+		done         = make(chan struct{})
+	)
+	go func() {
+		prefetcher.Prefetch(block, throwaway, vm.Config{}, interrupt)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	interrupt.Interrupt()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("prefetch not cancelled after interruption")
+	}
+	if _, ok := throwaway.Accessed()[contract]; !ok {
+		t.Errorf("contract not accessed before the interruption")
+	}
+}
+
+// Tests that importing a chain yields the same state whlbchain-dever the blocks are
+// prefetched or not.
+func TestStatePrefetchImport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000000)}},
+		}
+		signer = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	db, _ := lbchain-devdb.NewMemDatabase()
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 32, func(i int, block *BlockGen) {
+		for j := 0; j < 4; j++ {
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i), byte(j)}, big.NewInt(1000), 21000, new(big.Int), nil), signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	var roots []common.Hash
+	for _, noPrefetch := range []bool{true, false} {
+		db, _ := lbchain-devdb.NewMemDatabase()
+		gspec.MustCommit(db)
+
+		blockchain, _ := NewBlockChain(db, &CacheConfig{TrieCleanLimit: 16, NoPrefetch: noPrefetch}, gspec.Config, ethash.NewFaker(), vm.Config{})
+		if _, err := blockchain.InsertChain(blocks); err != nil {
+			t.Fatalf("noprefetch %v: failed to insert chain: %v", noPrefetch, err)
+		}
+		roots = append(roots, blockchain.CurrentBlock().Root())
+		blockchain.Stop()
+	}
+	if roots[0] != roots[1] || roots[0] != blocks[len(blocks)-1].Root() {
+		t.Errorf("state mismatch: plain %x, prefetched %x, want %x", roots[0], roots[1], blocks[len(blocks)-1].Root())
+	}
+}
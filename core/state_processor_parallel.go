@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/misc"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+var (
+	parallelExecuteMeter   = metrics.NewRegisteredMeter("chain/parallel/executed", nil)
+	parallelReexecuteMeter = metrics.NewRegisteredMeter("chain/parallel/reexecuted", nil)
+)
+
+// ParallelStateProcessor is a Processor executing the transactions of a block
+// concurrently, each on its own copy of the parent state, recording the state
+// read and written by them. The outcomes are then committed in order, replaying
+// the changes of the transactions which read nothing written by an earlier one
+// in the block, and reexecuting the conflicting ones on the committed state.
+// The results are identical to those of the StateProcessor.
+//
+// ParallelStateProcessor implements Processor.
+type ParallelStateProcessor struct {
+	config *params.ChainConfig // Chain configuration options
+	bc     processorChain      // Canonical block chain
+	engine consensus.Engine    // Consensus engine used for block rewards
+	serial *StateProcessor     // Fallback processor for blocks not worth parallelising
+	procs  int                 // Number of transactions to execute concurrently
+}
+
+// NewParallelStateProcessor initialises a new ParallelStateProcessor.
+func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *ParallelStateProcessor {
+	return &ParallelStateProcessor{
+		config: config,
+		bc:     bc,
+		engine: engine,
+		serial: NewStateProcessor(config, bc, engine),
+		procs:  runtime.NumCPU(),
+	}
+}
+
+// speculation is the outcome of executing a transaction on the parent state of
+// the block.
+type speculation struct {
+	msg    types.Message
+	gas    uint64
+	failed bool
+	err    error
+	access *state.Access
+}
+
+// Process processes the state changes according to the lbchain-devchain rules by running
+// the transaction messages using the statedb and applying any rewards to both
+// the processor (coinbase) and any included uncles.
+//
+// Process returns the receipts and logs accumulated during the process and
+// returns the amount of gas that was used in the process. If any of the
+// transactions failed to execute due to insufficient gas it will return an error.
+func (p *ParallelStateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	// Tracers observe execution as it happens, so they need it to be sequential
+	if len(block.Transactions()) < 2 || cfg.Debug {
+		return p.serial.Process(block, statedb, cfg)
+	}
+	var (
+		receipts types.Receipts
+		usedGas  = new(uint64)
+		header   = block.Header()
+		allLogs  []*types.Log
+		gp       = new(GasPool).AddGas(block.GasLimit())
+	)
+	// Mutate the the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	results := p.speculate(block, statedb, cfg)
+
+	// Commit the transactions in order, tracking the last writer of every piece
+	// of state to detect the speculations that read stale values
+	versions := make(map[state.AccessKey]int)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		res := results[i]
+		if res.err == nil && res.access.Replayable() && !conflicts(res.access, versions) {
+			parallelExecuteMeter.Mark(1)
+			if err := gp.SubGas(tx.Gas()); err != nil {
+				return nil, nil, 0, err
+			}
+			gp.AddGas(tx.Gas() - res.gas)
+			res.access.Apply(statedb)
+		} else {
+			parallelReexecuteMeter.Mark(1)
+			statedb.TrackReads()
+			msg, gas, failed, err := executeTransaction(p.config, p.bc, nil, gp, statedb, header, tx, cfg)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			res = &speculation{msg: msg, gas: gas, failed: failed, access: statedb.Access()}
+		}
+		for key := range res.access.Writes {
+			versions[key] = i
+		}
+		receipt := finaliseTransaction(p.config, statedb, header, tx, res.msg, res.gas, res.failed, usedGas)
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts)
+
+	return receipts, allLogs, *usedGas, nil
+}
+
+// speculate executes all the transactions of a block concurrently, each on its
+// own copy of the given state, and records their outcome and state accesses.
+func (p *ParallelStateProcessor) speculate(block *types.Block, statedb *state.StateDB, cfg vm.Config) []*speculation {
+	var (
+		txs     = block.Transactions()
+		header  = block.Header()
+		states  = make([]*state.StateDB, len(txs))
+		results = make([]*speculation, len(txs))
+		tasks   = make(chan int, len(txs))
+		pend    sync.WaitGroup
+	)
+	for i := range txs {
+		states[i] = statedb.Copy()
+		tasks <- i
+	}
+	close(tasks)
+
+	workers := p.procs
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	pend.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer pend.Done()
+
+			for i := range tasks {
+				statedb := states[i]
+				statedb.Prepare(txs[i].Hash(), block.Hash(), i)
+				statedb.TrackReads()
+
+				// Gas availability is only known when committing, allow the whole block
+				gp := new(GasPool).AddGas(block.GasLimit())
+				msg, gas, failed, err := executeTransaction(p.config, p.bc, nil, gp, statedb, header, txs[i], cfg)
+				if err == nil {
+					err = statedb.Error() // Values read might be bogus, reexecute
+				}
+				results[i] = &speculation{msg: msg, gas: gas, failed: failed, err: err, access: statedb.Access()}
+			}
+		}()
+	}
+	pend.Wait()
+	return results
+}
+
+// conflicts reports whlbchain-dever a transaction read any state written by an earlier
+// transaction of the block.
+func conflicts(access *state.Access, versions map[state.AccessKey]int) bool {
+	for key := range access.Reads {
+		if _, ok := versions[key]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+)
+
+// Tests that the parallel processor produces the same receipts, logs and state
+// as the serial one, over chains mixing independent and conflicting transactions.
+func TestParallelStateProcessor(t *testing.T) {
+	configs := map[string]*params.ChainConfig{
+		"frontier":  {ChainId: big.NewInt(1)},
+		"eip158":    {ChainId: big.NewInt(1), HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(0), EIP155Block: big.NewInt(0), EIP158Block: big.NewInt(0)},
+		"byzantium": params.TestChainConfig,
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) { testParallelStateProcessor(t, config) })
+	}
+}
+
+func testParallelStateProcessor(t *testing.T, config *params.ChainConfig) {
+	var (
+		db, _ = lbchain-devdb.NewMemDatabase()
+		keys  = make([]*ecdsa.PrivateKey, 4)
+		addrs = make([]common.Address, len(keys))
+		alloc = make(GenesisAlloc)
+
+		sink     = common.Address{0xee} // Plain account receiving transfers
+		empty    = common.Address{0xef} // Empty account only ever touched
+		counter  = common.Address{0xc0} // Increments slot 0
+		logger   = common.Address{0xc1} // Logs the balance of the caller
+		observer = common.Address{0xc2} // Stores the balance of the sink in slot 1
+		suicider = common.Address{0xc3} // Self-destructs to the sink
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[addrs[i]] = GenesisAccount{Balance: big.NewInt(1000000000000)}
+	}
+	alloc[counter] = GenesisAccount{Balance: new(big.Int), Code: []byte{0x60, 0x00, 0x54, 0x60, 0x01, 0x01, 0x60, 0x00, 0x55, 0x00}}
+	alloc[logger] = GenesisAccount{Balance: new(big.Int), Code: []byte{0x33, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xa0, 0x00}}
+	alloc[observer] = GenesisAccount{Balance: new(big.Int), Code: append(append([]byte{0x73}, sink.Bytes()...), 0x31, 0x60, 0x01, 0x55, 0x00)}
+	alloc[suicider] = GenesisAccount{Balance: big.NewInt(1000), Code: append(append([]byte{0x73}, sink.Bytes()...), 0xff)}
+
+	gspec := &Genesis{Config: config, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+	signer := types.MakeSigner(config, new(big.Int))
+
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 6, func(i int, block *BlockGen) {
+		// Make one of the senders the coinbase, conflicting with every fee payment
+		if i == 3 {
+			block.SetCoinbase(addrs[3])
+		}
+		send := func(from int, to *common.Address, value int64, data []byte) {
+			var tx *types.Transaction
+			if to == nil {
+				tx = types.NewContractCreation(block.TxNonce(addrs[from]), big.NewInt(value), 100000, big.NewInt(1), data)
+			} else {
+				tx = types.NewTransaction(block.TxNonce(addrs[from]), *to, big.NewInt(value), 100000, big.NewInt(1), data)
+			}
+			tx, err := types.SignTx(tx, signer, keys[from])
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.AddTx(tx)
+		}
+		// Independent transfers to the same account, and same-sender transactions
+		send(0, &sink, 1, nil)
+		send(1, &sink, 2, nil)
+		send(0, &addrs[2], 3, nil)
+
+		switch i % 3 {
+		case 0:
+			send(2, &counter, 0, nil)
+			send(3, &counter, 0, nil)
+			send(1, &logger, 0, nil)
+			send(2, &empty, 0, nil)
+		case 1:
+			send(2, &observer, 0, nil)
+			send(3, nil, 5, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x00})
+			send(1, &block.header.Coinbase, 7, nil)
+			send(3, &logger, 0, nil)
+		case 2:
+			send(2, &suicider, 0, nil)
+			send(3, &suicider, 0, nil)
+			send(1, &observer, 0, nil)
+			send(2, &logger, 0, nil)
+		}
+	})
+	blockchain, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	var (
+		serial   = NewStateProcessor(config, blockchain, blockchain.Engine())
+		parallel = NewParallelStateProcessor(config, blockchain, blockchain.Engine())
+	)
+	parallel.procs = 4 // Ensure concurrency even on single core machines
+
+	parent := genesis
+	for i, block := range blocks {
+		want, err := blockchain.StateAt(parent.Root())
+		if err != nil {
+			t.Fatalf("block %d: failed to open state: %v", i, err)
+		}
+		have := want.Copy()
+
+		wantReceipts, wantLogs, wantGas, err := serial.Process(block, want, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: serial processing failed: %v", i, err)
+		}
+		haveReceipts, haveLogs, haveGas, err := parallel.Process(block, have, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: parallel processing failed: %v", i, err)
+		}
+		if haveGas != wantGas {
+			t.Errorf("block %d: gas mismatch: have %d, want %d", i, haveGas, wantGas)
+		}
+		if !reflect.DeepEqual(haveReceipts, wantReceipts) {
+			t.Errorf("block %d: receipt mismatch", i)
+		}
+		if !reflect.DeepEqual(haveLogs, wantLogs) {
+			t.Errorf("block %d: log mismatch", i)
+		}
+		if haveRoot, wantRoot := have.IntermediateRoot(config.IsEIP158(block.Number())), want.IntermediateRoot(config.IsEIP158(block.Number())); haveRoot != wantRoot {
+			t.Errorf("block %d: state root mismatch: have %x, want %x", i, haveRoot, wantRoot)
+		}
+		if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i, err)
+		}
+		parent = block
+	}
+	// Importing the chain with the parallel processor should validate all blocks
+	db, _ = lbchain-devdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	imported, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer imported.Stop()
+
+	imported.SetProcessor(NewParallelStateProcessor(config, imported, imported.Engine()))
+	if _, err := imported.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain in parallel: %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/event"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// txHistoryEvents is the maximum number of lifecycle events retained for a
+	// single transaction. Older events are discarded first.
+	txHistoryEvents = 16
+
+	// txLifecycleChanSize is the size of the buffer holding the lifecycle events
+	// not yet delivered to the subscribers.
+	txLifecycleChanSize = 4096
+)
+
+// TxLifecycleType is the kind of a transaction lifecycle event in the pool.
+type TxLifecycleType string
+
+const (
+	TxLifecycleAdded    TxLifecycleType = "added"    // Transaction accepted into the pool
+	TxLifecyclePromoted TxLifecycleType = "promoted" // Transaction moved from the queue into the pending set
+	TxLifecycleDemoted  TxLifecycleType = "demoted"  // Transaction moved back from the pending set into the queue
+	TxLifecycleReplaced TxLifecycleType = "replaced" // Transaction superseded by another one with the same nonce
+	TxLifecycleDropped  TxLifecycleType = "dropped"  // Transaction discarded from the pool
+	TxLifecycleIncluded TxLifecycleType = "included" // Transaction included in a block of the canonical chain
+)
+
+// TxDropReason is the reason a transaction was discarded from the pool.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced       TxDropReason = "underpriced"             // Priced out by better paying transactions
+	TxDropNonceTooLow       TxDropReason = "nonce too low"           // Nonce already used by the chain state
+	TxDropInsufficientFunds TxDropReason = "insufficient funds"      // Sender cannot pay for the transaction anymore
+	TxDropGasLimit          TxDropReason = "exceeds block gas limit" // Gas limit above the one of the current block
+	TxDropLifetime          TxDropReason = "lifetime expired"        // Queued for longer than the allowed lifetime
+	TxDropOverflow          TxDropReason = "pool overflow"           // Evicted to keep the pool within its limits
+)
+
+// TxLifecycleEvent is posted whenever a transaction changes its state within the
+// transaction pool. Only the fields relevant to the type of the event are set.
+type TxLifecycleEvent struct {
+	Hash        common.Hash     `json:"hash"`
+	Type        TxLifecycleType `json:"type"`
+	Time        time.Time       `json:"time"`
+	Local       *bool           `json:"local,omitempty"`       // Whlbchain-dever an added transaction is a local one
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`  // Hash of the replacing transaction
+	Reason      TxDropReason    `json:"reason,omitempty"`      // Reason the transaction was dropped
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`   // Block the transaction was included in
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"` // Number of the block the transaction was included in
+}
+
+// txHistory is a bounded record of the lifecycle events of the most recently
+// seen transactions. It is not safe for concurrent use, the pool lock must be
+// held while accessing it.
+type txHistory struct {
+	events *lru.Cache // Lifecycle events of the recently seen transactions, keyed by hash
+}
+
+// newTxHistory creates a lifecycle history retaining the events of at most the
+// given number of transactions.
+func newTxHistory(limit int) *txHistory {
+	events, _ := lru.New(limit)
+	return &txHistory{events: events}
+}
+
+// add appends a lifecycle event to the history of its transaction.
+func (h *txHistory) add(ev *TxLifecycleEvent) {
+	var events []*TxLifecycleEvent
+	if cached, ok := h.events.Get(ev.Hash); ok {
+		events = cached.([]*TxLifecycleEvent)
+	}
+	if len(events) >= txHistoryEvents {
+		events = events[len(events)-txHistoryEvents+1:]
+	}
+	h.events.Add(ev.Hash, append(events, ev))
+}
+
+// get retrieves the lifecycle events of a transaction, oldest first.
+func (h *txHistory) get(hash common.Hash) []*TxLifecycleEvent {
+	cached, ok := h.events.Peek(hash)
+	if !ok {
+		return nil
+	}
+	events := cached.([]*TxLifecycleEvent)
+	return append([]*TxLifecycleEvent(nil), events...)
+}
+
+// last retrieves the most recent lifecycle event of a transaction.
+func (h *txHistory) last(hash common.Hash) *TxLifecycleEvent {
+	cached, ok := h.events.Peek(hash)
+	if !ok {
+		return nil
+	}
+	events := cached.([]*TxLifecycleEvent)
+	return events[len(events)-1]
+}
+
+// History returns the current status of a transaction along with the lifecycle
+// events recorded for it by the pool, oldest first. Events are only retained for
+// a limited number of recently seen transactions.
+func (pool *TxPool) History(hash common.Hash) (TxStatus, []*TxLifecycleEvent) {
+	status := pool.Status([]common.Hash{hash})[0]
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	events := pool.history.get(hash)
+	if status == TxStatusUnknown && len(events) > 0 && events[len(events)-1].Type == TxLifecycleIncluded {
+		status = TxStatusIncluded
+	}
+	return status, events
+}
+
+// SubscribeTxLifecycleEvent registers a subscription of TxLifecycleEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeTxLifecycleEvent(ch chan<- TxLifecycleEvent) event.Subscription {
+	return pool.scope.Track(pool.lifecycleFeed.Subscribe(ch))
+}
+
+// lifecycleLoop delivers the recorded lifecycle events to the subscribers. It
+// is decoupled from the recording to avoid blocking the pool on slow consumers.
+func (pool *TxPool) lifecycleLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case ev := <-pool.lifecycleCh:
+			pool.lifecycleFeed.Send(ev)
+		case <-pool.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
+// record timestamps a lifecycle event, appends it to the history of its
+// transaction and queues it for delivery to the subscribers.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) record(ev *TxLifecycleEvent) {
+	ev.Time = time.Now()
+	pool.history.add(ev)
+
+	select {
+	case pool.lifecycleCh <- *ev:
+	default:
+		lifecycleDropCounter.Inc(1)
+	}
+}
+
+// recordAdded records the acceptance of a transaction into the pool.
+func (pool *TxPool) recordAdded(hash common.Hash, local bool) {
+	pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecycleAdded, Local: &local})
+}
+
+// recordReplaced records that a pooled transaction was superseded by another.
+func (pool *TxPool) recordReplaced(hash common.Hash, by common.Hash) {
+	pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecycleReplaced, ReplacedBy: &by})
+}
+
+// recordDropped records the removal of a transaction from the pool.
+func (pool *TxPool) recordDropped(hash common.Hash, reason TxDropReason) {
+	pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecycleDropped, Reason: reason})
+}
+
+// recordStale records the removal of a transaction with a nonce already used by
+// the chain state, unless the transaction itself was the one included.
+func (pool *TxPool) recordStale(hash common.Hash) {
+	if last := pool.history.last(hash); last != nil && last.Type == TxLifecycleIncluded {
+		return
+	}
+	pool.recordDropped(hash, TxDropNonceTooLow)
+}
+
+// recordUnpayable records the removal of a transaction that can't be executed
+// anymore due to the balance of its sender or the block gas limit.
+func (pool *TxPool) recordUnpayable(tx *types.Transaction) {
+	if tx.Gas() > pool.currentMaxGas {
+		pool.recordDropped(tx.Hash(), TxDropGasLimit)
+		return
+	}
+	pool.recordDropped(tx.Hash(), TxDropInsufficientFunds)
+}
+
+// recordIncluded records the inclusion of the pooled transactions of a block
+// that became part of the canonical chain.
+func (pool *TxPool) recordIncluded(block *types.Block) {
+	var (
+		hash   = block.Hash()
+		number = hexutil.Uint64(block.NumberU64())
+	)
+	for _, tx := range block.Transactions() {
+		if pool.all[tx.Hash()] == nil {
+			continue
+		}
+		pool.record(&TxLifecycleEvent{Hash: tx.Hash(), Type: TxLifecycleIncluded, BlockHash: &hash, BlockNumber: &number})
+	}
+}
@@ -0,0 +1,376 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+)
+
+var (
+	// ErrSenderNotAllowed is returned if the pool only accepts transactions from
+	// allowlisted senders and the sender of the transaction is not one of them.
+	ErrSenderNotAllowed = errors.New("sender not allowed")
+
+	// ErrDestinationBlocked is returned if the recipient of a transaction is on
+	// the blocklist of the pool.
+	ErrDestinationBlocked = errors.New("destination blocked")
+
+	// ErrSenderRateLimited is returned if the sender of a transaction already had
+	// the maximum number of transactions admitted within the current rate window.
+	ErrSenderRateLimited = errors.New("sender rate limited")
+
+	// ErrClassUnderpriced is returned if the gas price of a transaction is below
+	// the minimum required from the class of its sender.
+	ErrClassUnderpriced = errors.New("transaction underpriced for sender class")
+)
+
+// TxPolicy is an admission rule consulted by the transaction pool before a new
+// transaction is accepted.
+type TxPolicy interface {
+	// Admit checks whlbchain-dever a transaction may enter the pool, returning the
+	// reason of the rejection otherwise. It is called with the pool lock held, so
+	// it must not call back into the pool.
+	Admit(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// TxPolicyFunc is an adapter to allow the use of ordinary functions as admission
+// policies.
+type TxPolicyFunc func(tx *types.Transaction, from common.Address, local bool) error
+
+// Admit calls f(tx, from, local).
+func (f TxPolicyFunc) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	return f(tx, from, local)
+}
+
+// TxSenderClass is a group of senders required to pay at least a given gas price.
+type TxSenderClass struct {
+	Name       string           `json:"name"`
+	Senders    []common.Address `json:"senders"`
+	PriceLimit uint64           `json:"priceLimit"` // Minimum gas price to enforce for the senders of the class
+}
+
+// TxPolicyConfig are the built-in admission rules of the transaction pool.
+//
+// The sender allowlist and the destination blocklist apply to all transactions,
+// whereas the rate limit and the sender class prices, similarly to the pool's
+// own price limit, exempt local transactions.
+type TxPolicyConfig struct {
+	RestrictSenders bool             `json:"restrictSenders"` // Whlbchain-dever only the allowlisted senders may submit transactions
+	Allowlist       []common.Address `json:"allowlist"`       // Senders permitted to submit transactions if restricted
+	Blocklist       []common.Address `json:"blocklist"`       // Recipients transactions may not be sent to
+
+	RateLimit  uint64        `json:"rateLimit"`  // Maximum number of transactions admitted per sender within a rate window (0 = unlimited)
+	RateWindow time.Duration `json:"rateWindow"` // Time interval the sender rate limit is enforced over
+
+	Classes []TxSenderClass `json:"classes"` // Sender classes with their own minimum gas price
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *TxPolicyConfig) sanitize() TxPolicyConfig {
+	conf := config.copy()
+	if conf.RateLimit > 0 && conf.RateWindow < time.Second {
+		log.Warn("Sanitizing invalid txpool rate window", "provided", conf.RateWindow, "updated", time.Minute)
+		conf.RateWindow = time.Minute
+	}
+	return conf
+}
+
+// copy creates a deep copy of the policy configuration.
+func (config *TxPolicyConfig) copy() TxPolicyConfig {
+	conf := *config
+	conf.Allowlist = append([]common.Address{}, config.Allowlist...)
+	conf.Blocklist = append([]common.Address{}, config.Blocklist...)
+	conf.Classes = make([]TxSenderClass, len(config.Classes))
+	for i, class := range config.Classes {
+		conf.Classes[i] = class
+		conf.Classes[i].Senders = append([]common.Address{}, class.Senders...)
+	}
+	return conf
+}
+
+// senderRate tracks the number of transactions admitted from a single sender
+// within its current rate window.
+type senderRate struct {
+	start time.Time // Beginning of the current rate window
+	count uint64    // Number of transactions admitted within the window
+}
+
+// txPolicyRules enforces the built-in admission rules of a TxPolicyConfig. The
+// rules may be replaced at runtime without losing the rate limiting state. It is
+// not safe for concurrent use, the pool lock must be held while accessing it.
+type txPolicyRules struct {
+	config  TxPolicyConfig
+	allowed map[common.Address]struct{} // Senders permitted if the pool is restricted
+	blocked map[common.Address]struct{} // Recipients transactions may not be sent to
+	classes map[common.Address]uint64   // Minimum gas price of the classified senders
+
+	rates map[common.Address]*senderRate // Admission counters of the rate limited senders
+	swept time.Time                      // Last time the expired rate windows were removed
+}
+
+// newTxPolicyRules creates the built-in admission rules of the given config.
+func newTxPolicyRules(config TxPolicyConfig) *txPolicyRules {
+	rules := &txPolicyRules{rates: make(map[common.Address]*senderRate)}
+	rules.set(config)
+	return rules
+}
+
+// set replaces the admission rules with the ones of the given config.
+func (rules *txPolicyRules) set(config TxPolicyConfig) {
+	rules.config = config.sanitize()
+
+	rules.allowed = make(map[common.Address]struct{})
+	for _, addr := range rules.config.Allowlist {
+		rules.allowed[addr] = struct{}{}
+	}
+	rules.blocked = make(map[common.Address]struct{})
+	for _, addr := range rules.config.Blocklist {
+		rules.blocked[addr] = struct{}{}
+	}
+	// If a sender is listed in multiple classes, the first one takes precedence
+	rules.classes = make(map[common.Address]uint64)
+	for _, class := range rules.config.Classes {
+		for _, addr := range class.Senders {
+			if _, ok := rules.classes[addr]; !ok {
+				rules.classes[addr] = class.PriceLimit
+			}
+		}
+	}
+}
+
+// Admit implements TxPolicy, checking a transaction against the built-in rules.
+// Admitted transactions are not counted towards the rate limit of their sender,
+// that is done via count once they are actually accepted into the pool.
+func (rules *txPolicyRules) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if err := rules.check(tx, from, local); err != nil {
+		return err
+	}
+	if !local && rules.limited(from, time.Now()) {
+		return ErrSenderRateLimited
+	}
+	return nil
+}
+
+// check verifies a transaction against all the built-in rules apart from the
+// sender rate limit.
+func (rules *txPolicyRules) check(tx *types.Transaction, from common.Address, local bool) error {
+	if rules.config.RestrictSenders {
+		if _, ok := rules.allowed[from]; !ok {
+			return ErrSenderNotAllowed
+		}
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := rules.blocked[*to]; ok {
+			return ErrDestinationBlocked
+		}
+	}
+	if local {
+		return nil
+	}
+	if limit, ok := rules.classes[from]; ok && tx.GasPrice().Cmp(new(big.Int).SetUint64(limit)) < 0 {
+		return ErrClassUnderpriced
+	}
+	return nil
+}
+
+// limited reports whether the sender already had the maximum number of
+// transactions accepted within its current rate window.
+func (rules *txPolicyRules) limited(from common.Address, now time.Time) bool {
+	if rules.config.RateLimit == 0 {
+		return false
+	}
+	rules.sweep(now)
+
+	rate := rules.rates[from]
+	if rate == nil || now.Sub(rate.start) >= rules.config.RateWindow {
+		return false
+	}
+	return rate.count >= rules.config.RateLimit
+}
+
+// count accounts a transaction accepted into the pool towards the rate limit of
+// its sender, opening a new rate window if the previous one expired.
+func (rules *txPolicyRules) count(from common.Address, now time.Time) {
+	if rules.config.RateLimit == 0 {
+		return
+	}
+	rate := rules.rates[from]
+	if rate == nil || now.Sub(rate.start) >= rules.config.RateWindow {
+		rate = &senderRate{start: now}
+		rules.rates[from] = rate
+	}
+	rate.count++
+}
+
+// sweep removes the senders with expired rate windows, preventing the tracked
+// senders from growing indefinitely.
+func (rules *txPolicyRules) sweep(now time.Time) {
+	if now.Sub(rules.swept) < rules.config.RateWindow {
+		return
+	}
+	for addr, rate := range rules.rates {
+		if now.Sub(rate.start) >= rules.config.RateWindow {
+			delete(rules.rates, addr)
+		}
+	}
+	rules.swept = now
+}
+
+// admit checks a transaction against the built-in admission rules and all the
+// custom policies registered with the pool. The sender rate limit is only
+// enforced if limit is set, i.e. not for transactions reinjected or restored
+// into the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) admit(tx *types.Transaction, from common.Address, local bool, limit bool) error {
+	var err error
+	if limit {
+		err = pool.rules.Admit(tx, from, local)
+	} else {
+		err = pool.rules.check(tx, from, local)
+	}
+	if err != nil {
+		policyTxCounter.Inc(1)
+		return err
+	}
+	for _, policy := range pool.policies {
+		if err := policy.Admit(tx, from, local); err != nil {
+			policyTxCounter.Inc(1)
+			return err
+		}
+	}
+	return nil
+}
+
+// count accounts a transaction accepted into the pool towards the rate limit of
+// its sender, unless the transaction is local and thus exempt from it.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) count(from common.Address, local bool) {
+	if local || pool.locals.contains(from) {
+		return
+	}
+	pool.rules.count(from, time.Now())
+}
+
+// AddPolicy registers a custom admission policy to consult, after the built-in
+// rules, before accepting new transactions into the pool.
+func (pool *TxPool) AddPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policies = append(pool.policies, policy)
+}
+
+// Policy retrieves the built-in admission rules currently enforced by the pool.
+func (pool *TxPool) Policy() TxPolicyConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.rules.config.copy()
+}
+
+// SetPolicy replaces the built-in admission rules of the pool. Transactions
+// already in the pool are not affected.
+func (pool *TxPool) SetPolicy(config TxPolicyConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.rules.set(config)
+	log.Info("Transaction pool admission policy updated", "restricted", pool.rules.config.RestrictSenders,
+		"allowed", len(pool.rules.allowed), "blocked", len(pool.rules.blocked), "classes", len(pool.rules.config.Classes),
+		"ratelimit", pool.rules.config.RateLimit, "ratewindow", pool.rules.config.RateWindow)
+}
+
+// AllowSender adds an account to the senders permitted to submit transactions
+// if the pool is restricted. It returns false if the sender was already allowed.
+func (pool *TxPool) AllowSender(addr common.Address) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.rules.allowed[addr]; ok {
+		return false
+	}
+	config := pool.rules.config
+	config.Allowlist = append(config.Allowlist, addr)
+	pool.rules.set(config)
+	return true
+}
+
+// DisallowSender removes an account from the senders permitted to submit
+// transactions. It returns false if the sender was not allowed.
+func (pool *TxPool) DisallowSender(addr common.Address) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.rules.allowed[addr]; !ok {
+		return false
+	}
+	config := pool.rules.config
+	config.Allowlist = removeAddress(config.Allowlist, addr)
+	pool.rules.set(config)
+	return true
+}
+
+// BlockDestination adds an account to the recipients transactions may not be
+// sent to. It returns false if the recipient was already blocked.
+func (pool *TxPool) BlockDestination(addr common.Address) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.rules.blocked[addr]; ok {
+		return false
+	}
+	config := pool.rules.config
+	config.Blocklist = append(config.Blocklist, addr)
+	pool.rules.set(config)
+	return true
+}
+
+// UnblockDestination removes an account from the blocked recipients. It returns
+// false if the recipient was not blocked.
+func (pool *TxPool) UnblockDestination(addr common.Address) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if _, ok := pool.rules.blocked[addr]; !ok {
+		return false
+	}
+	config := pool.rules.config
+	config.Blocklist = removeAddress(config.Blocklist, addr)
+	pool.rules.set(config)
+	return true
+}
+
+// removeAddress returns a copy of the address list without any occurrence of
+// the given address.
+func removeAddress(list []common.Address, addr common.Address) []common.Address {
+	kept := make([]common.Address, 0, len(list))
+	for _, item := range list {
+		if item != addr {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -101,6 +101,16 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+
+	// Lifecycle events not delivered due to the subscribers falling behind
+	lifecycleDropCounter = metrics.NewRegisteredCounter("txpool/lifecycle/drop", nil)
+
+	// Metrics for the admission policies
+	policyTxCounter = metrics.NewRegisteredCounter("txpool/policy", nil) // Rejected by an admission policy
+
+	// Metrics for the pool snapshot
+	snapshotRestoreCounter = metrics.NewRegisteredCounter("txpool/snapshot/restored", nil)  // Reinjected from the snapshot
+	snapshotDiscardCounter = metrics.NewRegisteredCounter("txpool/snapshot/discarded", nil) // Invalidated since the snapshot
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -113,6 +123,20 @@ const (
 	TxStatusIncluded
 )
 
+// String implements fmt.Stringer.
+func (status TxStatus) String() string {
+	switch status {
+	case TxStatusQueued:
+		return "queued"
+	case TxStatusPending:
+		return "pending"
+	case TxStatusIncluded:
+		return "included"
+	default:
+		return "unknown"
+	}
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -129,6 +153,9 @@ type TxPoolConfig struct {
 	Journal   string        // Journal of local transactions to survive node restarts
 	Rejournal time.Duration // Time interval to regenerate the local transaction journal
 
+	Snapshot    string        // Snapshot of the full pool to save on shutdown and restore on startup (empty = disabled)
+	SnapshotAge time.Duration // Maximum age of a snapshot to still restore it on startup
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -138,6 +165,10 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	History uint64 // Number of recently seen transactions to retain the lifecycle history of
+
+	Policy TxPolicyConfig // Built-in admission rules enforced on new transactions
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -146,6 +177,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	SnapshotAge: time.Hour,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -155,6 +188,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	History: 16384,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -165,6 +200,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.Snapshot != "" && conf.SnapshotAge < time.Second {
+		log.Warn("Sanitizing invalid txpool snapshot age", "provided", conf.SnapshotAge, "updated", DefaultTxPoolConfig.SnapshotAge)
+		conf.SnapshotAge = DefaultTxPoolConfig.SnapshotAge
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -173,6 +212,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.History < 1 {
+		log.Warn("Sanitizing invalid txpool history limit", "provided", conf.History, "updated", DefaultTxPoolConfig.History)
+		conf.History = DefaultTxPoolConfig.History
+	}
 	return conf
 }
 
@@ -208,6 +251,13 @@ type TxPool struct {
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
 	priced  *txPricedList                      // All transactions sorted by price
 
+	history       *txHistory            // Lifecycle events of the recently seen transactions
+	lifecycleCh   chan TxLifecycleEvent // Lifecycle events pending delivery to the subscribers
+	lifecycleFeed event.Feed
+
+	rules    *txPolicyRules // Built-in admission rules of the pool
+	policies []TxPolicy     // Custom admission policies consulted after the built-in rules
+
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead bool
@@ -229,6 +279,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		history:     newTxHistory(int(config.History)),
+		lifecycleCh: make(chan TxLifecycleEvent, txLifecycleChanSize),
+		rules:       newTxPolicyRules(config.Policy),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
@@ -247,12 +300,19 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If snapshotting is enabled, refill the pool with the content saved on shutdown
+	if config.Snapshot != "" {
+		if err := pool.restoreSnapshot(); err != nil {
+			log.Warn("Failed to restore transaction pool snapshot", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
-	// Start the event loop and return
-	pool.wg.Add(1)
+	// Start the event loops and return
+	pool.wg.Add(2)
 	go pool.loop()
+	go pool.lifecycleLoop()
 
 	return pool
 }
@@ -320,6 +380,7 @@ func (pool *TxPool) loop() {
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
+						pool.recordDropped(tx.Hash(), TxDropLifetime)
 						pool.removeTx(tx.Hash())
 					}
 				}
@@ -354,6 +415,12 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
 	var reinject types.Transactions
 
+	if oldHead != nil && oldHead.Hash() == newHead.ParentHash {
+		// Plain chain extension, record the inclusion of the pooled transactions
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			pool.recordIncluded(block)
+		}
+	}
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
 		oldNum := oldHead.Number.Uint64()
@@ -378,6 +445,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 			}
 			for add.NumberU64() > rem.NumberU64() {
 				included = append(included, add.Transactions()...)
+				pool.recordIncluded(add)
 				if add = pool.chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
 					log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
 					return
@@ -390,6 +458,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 					return
 				}
 				included = append(included, add.Transactions()...)
+				pool.recordIncluded(add)
 				if add = pool.chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
 					log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
 					return
@@ -411,9 +480,9 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
-	// Inject any transactions discarded due to reorgs
+	// Inject any transactions discarded due to reorgs, without rate limiting them
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
-	pool.addTxsLocked(reinject, false)
+	pool.reinjectTxsLocked(reinject, false)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -440,6 +509,11 @@ func (pool *TxPool) Stop() {
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
 
+	if pool.config.Snapshot != "" {
+		if err := pool.saveSnapshot(); err != nil {
+			log.Warn("Failed to save transaction pool snapshot", "err", err)
+		}
+	}
 	if pool.journal != nil {
 		pool.journal.close()
 	}
@@ -468,6 +542,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
+		pool.recordDropped(tx.Hash(), TxDropUnderpriced)
 		pool.removeTx(tx.Hash())
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
@@ -521,6 +596,22 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool, returning the
+// pending as well as queued transactions of a single account, sorted by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued types.Transactions
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 // Pending retrieves all currently processable transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -553,7 +644,7 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 
 // validateTx checks whlbchain-dever a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
-func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
+func (pool *TxPool) validateTx(tx *types.Transaction, local bool, limit bool) error {
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -593,7 +684,8 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
-	return nil
+	// Ensure the transaction is permitted by the admission policies
+	return pool.admit(tx, from, local, limit)
 }
 
 // add validates a transaction and inserts it into the non-executable queue for
@@ -604,7 +696,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // whitelisted, preventing any associated transaction from being dropped out of
 // the pool due to pricing constraints.
-func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
+//
+// The sender rate limit is only enforced, and the transaction only counted
+// towards it, if limit is set. Transactions already admitted once before (e.g.
+// reinjected after a reorg) are not limited again.
+func (pool *TxPool) add(tx *types.Transaction, local bool, limit bool) (bool, error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all[hash] != nil {
@@ -612,7 +708,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		return false, fmt.Errorf("known transaction: %x", hash)
 	}
 	// If the transaction fails basic validation, discard it
-	if err := pool.validateTx(tx, local); err != nil {
+	if err := pool.validateTx(tx, local, limit); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxCounter.Inc(1)
 		return false, err
@@ -630,6 +726,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
+			pool.recordDropped(tx.Hash(), TxDropUnderpriced)
 			pool.removeTx(tx.Hash())
 		}
 	}
@@ -643,15 +740,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			return false, ErrReplaceUnderpriced
 		}
 		// New transaction is better, replace old one
+		pool.recordAdded(hash, local)
 		if old != nil {
 			delete(pool.all, old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.recordReplaced(old.Hash(), hash)
 		}
 		pool.all[tx.Hash()] = tx
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
-
+		if limit {
+			pool.count(from, local)
+		}
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
@@ -664,12 +765,15 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.recordAdded(hash, local)
 	// Mark local addresses and journal local transactions
 	if local {
 		pool.locals.add(from)
 	}
 	pool.journalTx(from, tx)
-
+	if limit {
+		pool.count(from, local)
+	}
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replace, nil
 }
@@ -694,6 +798,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		delete(pool.all, old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.recordReplaced(old.Hash(), hash)
 	}
 	pool.all[hash] = tx
 	pool.priced.Put(tx)
@@ -729,6 +834,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.recordDropped(hash, TxDropUnderpriced)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -737,12 +843,15 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.recordReplaced(old.Hash(), hash)
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all[hash] == nil {
 		pool.all[hash] = tx
 		pool.priced.Put(tx)
 	}
+	pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecyclePromoted})
+
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
@@ -784,7 +893,7 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	defer pool.mu.Unlock()
 
 	// Try to inject the transaction and update any state
-	replace, err := pool.add(tx, local)
+	replace, err := pool.add(tx, local, true)
 	if err != nil {
 		return err
 	}
@@ -807,13 +916,26 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
 // addTxsLocked attempts to queue a batch of transactions if they are valid,
 // whilst assuming the transaction pool lock is already held.
 func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
+	return pool.insertTxsLocked(txs, local, true)
+}
+
+// reinjectTxsLocked attempts to queue a batch of transactions already admitted
+// into the pool once before (e.g. discarded by a reorg), without enforcing the
+// sender rate limit on them again. The transaction pool lock must be held.
+func (pool *TxPool) reinjectTxsLocked(txs []*types.Transaction, local bool) []error {
+	return pool.insertTxsLocked(txs, local, false)
+}
+
+// insertTxsLocked queues a batch of transactions if they are valid, enforcing
+// the sender rate limit only if limit is set.
+func (pool *TxPool) insertTxsLocked(txs []*types.Transaction, local bool, limit bool) []error {
 	// Add the batch of transaction, tracking the accepted ones
 	dirty := make(map[common.Address]struct{})
 	errs := make([]error, len(txs))
 
 	for i, tx := range txs {
 		var replace bool
-		if replace, errs[i] = pool.add(tx, local); errs[i] == nil {
+		if replace, errs[i] = pool.add(tx, local, limit); errs[i] == nil {
 			if !replace {
 				from, _ := types.Sender(pool.signer, tx) // already validated
 				dirty[from] = struct{}{}
@@ -885,6 +1007,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 				// Otherwise postpone any invalidated transactions
 				for _, tx := range invalids {
 					pool.enqueueTx(tx.Hash(), tx)
+					pool.record(&TxLifecycleEvent{Hash: tx.Hash(), Type: TxLifecycleDemoted})
 				}
 			}
 			// Update the account nonce if needed
@@ -926,6 +1049,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			log.Trace("Removed old queued transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.recordStale(hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currenlbchain-devate.GetBalance(addr), pool.currentMaxGas)
@@ -935,6 +1059,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			delete(pool.all, hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.recordUnpayable(tx)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -949,6 +1074,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				delete(pool.all, hash)
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
+				pool.recordDropped(hash, TxDropOverflow)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -998,6 +1124,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
 								pool.pendingState.SetNonce(offenders[i], nonce)
 							}
+							pool.recordDropped(hash, TxDropOverflow)
 							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 						}
 						pending--
@@ -1020,6 +1147,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
 							pool.pendingState.SetNonce(addr, nonce)
 						}
+						pool.recordDropped(hash, TxDropOverflow)
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
 					pending--
@@ -1053,6 +1181,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Drop all transactions if they are less than the overflow
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
+					pool.recordDropped(tx.Hash(), TxDropOverflow)
 					pool.removeTx(tx.Hash())
 				}
 				drop -= size
@@ -1062,6 +1191,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+				pool.recordDropped(txs[i].Hash(), TxDropOverflow)
 				pool.removeTx(txs[i].Hash())
 				drop--
 				queuedRateLimitCounter.Inc(1)
@@ -1084,6 +1214,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 			delete(pool.all, hash)
 			pool.priced.Removed()
+			pool.recordStale(hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currenlbchain-devate.GetBalance(addr), pool.currentMaxGas)
@@ -1093,11 +1224,13 @@ func (pool *TxPool) demoteUnexecutables() {
 			delete(pool.all, hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.recordUnpayable(tx)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 			pool.enqueueTx(hash, tx)
+			pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecycleDemoted})
 		}
 		// If there's a gap in front, warn (should never happen) and postpone all transactions
 		if list.Len() > 0 && list.txs.Get(nonce) == nil {
@@ -1105,6 +1238,7 @@ func (pool *TxPool) demoteUnexecutables() {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 				pool.enqueueTx(hash, tx)
+				pool.record(&TxLifecycleEvent{Hash: hash, Type: TxLifecycleDemoted})
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -18,11 +18,13 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -347,14 +349,14 @@ func TestTransactionChainFork(t *testing.T) {
 	reselbchain-devate()
 
 	tx := transaction(0, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	pool.removeTx(tx.Hash())
 
 	// reset the pool's internal state
 	reselbchain-devate()
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -382,10 +384,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 1000000, big.NewInt(1), nil), signer, key)
 
 	// Add the first two transaction, ensure higher priced stays only
-	if replace, err := pool.add(tx1, false); err != nil || replace {
+	if replace, err := pool.add(tx1, false, true); err != nil || replace {
 		t.Errorf("first transaction insert failed (%v) or reported replacement (%v)", err, replace)
 	}
-	if replace, err := pool.add(tx2, false); err != nil || !replace {
+	if replace, err := pool.add(tx2, false, true); err != nil || !replace {
 		t.Errorf("second transaction insert failed (%v) or not reported replacement (%v)", err, replace)
 	}
 	pool.promoteExecutables([]common.Address{addr})
@@ -396,7 +398,7 @@ func TestTransactionDoubleNonce(t *testing.T) {
 		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), tx2.Hash())
 	}
 	// Add the third transaction and ensure it's not saved (smaller price)
-	pool.add(tx3, false)
+	pool.add(tx3, false, true)
 	pool.promoteExecutables([]common.Address{addr})
 	if pool.pending[addr].Len() != 1 {
 		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
@@ -419,7 +421,7 @@ func TestTransactionMissingNonce(t *testing.T) {
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 	pool.currenlbchain-devate.AddBalance(addr, big.NewInt(100000000000000))
 	tx := transaction(1, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -1563,6 +1565,182 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that the full content of the pool is snapshotted on shutdown and that
+// on startup it's revalidated against the current head and restored, unless the
+// snapshot is too old.
+func TestTransactionSnapshot(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary path for the snapshot
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the original pool to snapshot the transactions of
+	db, _ := lbchain-devdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(dir, "txpool.rlp")
+	config.SnapshotAge = time.Minute
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	// Create a local, a remote and a cheap remote account to fill the pool with
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	cheap, _ := crypto.GenerateKey()
+
+	for _, key := range []*ecdsa.PrivateKey{local, remote, cheap} {
+		pool.currenlbchain-devate.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	pool.AddLocals([]*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), local),
+		pricedTransaction(1, 100000, big.NewInt(1), local),
+	})
+	pool.AddRemotes([]*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(2), remote),
+		pricedTransaction(1, 100000, big.NewInt(2), remote),
+		pricedTransaction(3, 100000, big.NewInt(2), remote),
+		pricedTransaction(0, 100000, big.NewInt(1), cheap),
+	})
+	if pending, queued := pool.Stats(); pending != 5 || queued != 1 {
+		t.Fatalf("pool content mismatch: have %d pending, %d queued, want 5 pending, 1 queued", pending, queued)
+	}
+	// Terminate the old pool, bump the remote nonce and the price limit, then
+	// create a new pool and ensure only the still valid transactions are restored
+	pool.Stop()
+	if _, err := os.Stat(config.Snapshot); err != nil {
+		t.Fatalf("snapshot not saved: %v", err)
+	}
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config.PriceLimit = 2
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("restored content mismatch: have %d pending, %d queued, want 3 pending, 1 queued", pending, queued)
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Fatalf("local account not restored as local")
+	}
+	if _, err := os.Stat(config.Snapshot); !os.IsNotExist(err) {
+		t.Fatalf("snapshot not removed after restoring: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Terminate the pool again, age the snapshot and ensure it's not restored
+	pool.Stop()
+
+	snap, err := loadTxSnapshot(config.Snapshot)
+	if err != nil || snap == nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if have := len(snap.Locals) + len(snap.Remotes); have != 4 {
+		t.Fatalf("snapshot size mismatch: have %d, want %d", have, 4)
+	}
+	snap.Time = uint64(time.Now().Add(-2 * config.SnapshotAge).Unix())
+	if err := saveTxSnapshot(config.Snapshot, snap); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("stale snapshot restored: have %d pending, %d queued", pending, queued)
+	}
+}
+
+// Tests that the transactions restored from a pool snapshot keep their original
+// arrival times, and end up in the same pending and queued sets as before.
+func TestTransactionSnapshotTimes(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary path for the snapshot
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the original pool and fill it with transactions of known arrival
+	db, _ := lbchain-devdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(dir, "txpool.rlp")
+	config.SnapshotAge = time.Minute
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	for _, key := range []*ecdsa.PrivateKey{local, remote} {
+		pool.currenlbchain-devate.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	var (
+		locals = []*types.Transaction{
+			pricedTransaction(0, 100000, big.NewInt(1), local),
+			pricedTransaction(2, 100000, big.NewInt(1), local),
+		}
+		remotes = []*types.Transaction{
+			pricedTransaction(0, 100000, big.NewInt(1), remote),
+			pricedTransaction(1, 100000, big.NewInt(1), remote),
+			pricedTransaction(3, 100000, big.NewInt(1), remote),
+		}
+		arrivals = make(map[common.Hash]time.Time)
+		start    = time.Now().Add(-time.Hour)
+	)
+	for i, tx := range append(append([]*types.Transaction{}, locals...), remotes...) {
+		tx.SetTime(start.Add(time.Duration(i) * time.Second))
+		arrivals[tx.Hash()] = tx.Time()
+	}
+	pool.AddLocals(locals)
+	pool.AddRemotes(remotes)
+
+	pending, queued := pool.Content()
+	if len(pending) != 2 || len(queued) != 2 {
+		t.Fatalf("original split mismatch: have %d pending, %d queued accounts, want 2 and 2", len(pending), len(queued))
+	}
+	pool.Stop()
+
+	// Restore the snapshot into a new pool and check the arrivals and the split
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	restoredPending, restoredQueued := pool.Content()
+	for _, test := range []struct {
+		name       string
+		have, want map[common.Address]types.Transactions
+	}{{"pending", restoredPending, pending}, {"queued", restoredQueued, queued}} {
+		if len(test.have) != len(test.want) {
+			t.Fatalf("%s account count mismatch: have %d, want %d", test.name, len(test.have), len(test.want))
+		}
+		for addr, txs := range test.want {
+			if len(test.have[addr]) != len(txs) {
+				t.Fatalf("%s %x: transaction count mismatch: have %d, want %d", test.name, addr, len(test.have[addr]), len(txs))
+			}
+			for i, tx := range test.have[addr] {
+				if tx.Hash() != txs[i].Hash() {
+					t.Errorf("%s %x, tx %d: hash mismatch: have %x, want %x", test.name, addr, i, tx.Hash(), txs[i].Hash())
+				}
+				if want := arrivals[tx.Hash()]; !tx.Time().Equal(want) {
+					t.Errorf("%s %x, tx %d: arrival mismatch: have %v, want %v", test.name, addr, i, tx.Time(), want)
+				}
+			}
+		}
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Fatalf("local account not restored as local")
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -1620,6 +1798,307 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that the lifecycle events of transactions are recorded into their history
+// and posted to the subscribers.
+func TestTransactionLifecycleHistory(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the lifecycle recording with
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxLifecycleEvent, 32)
+	sub := pool.SubscribeTxLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currenlbchain-devate.AddBalance(account, big.NewInt(1000000))
+
+	// Add an executable and a future transaction, and replace the executable one
+	var (
+		tx0  = pricedTransaction(0, 100000, big.NewInt(1), key)
+		tx0b = pricedTransaction(0, 100000, big.NewInt(2), key)
+		tx2  = pricedTransaction(2, 100000, big.NewInt(1), key)
+	)
+	if err := pool.AddLocal(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(tx2); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(tx0b); err != nil {
+		t.Fatalf("failed to add replacement transaction: %v", err)
+	}
+	// Drain the funds of the account and check that the remaining ones are dropped
+	pool.currenlbchain-devate.SetBalance(account, new(big.Int))
+	pool.lockedReset(nil, nil)
+
+	tests := []struct {
+		tx     *types.Transaction
+		status TxStatus
+		events []TxLifecycleType
+		check  func(events []*TxLifecycleEvent) error
+	}{
+		{tx0, TxStatusUnknown, []TxLifecycleType{TxLifecycleAdded, TxLifecyclePromoted, TxLifecycleReplaced}, func(events []*TxLifecycleEvent) error {
+			if !*events[0].Local {
+				return errors.New("local transaction recorded as remote")
+			}
+			if *events[2].ReplacedBy != tx0b.Hash() {
+				return fmt.Errorf("replacement mismatch: have %x, want %x", *events[2].ReplacedBy, tx0b.Hash())
+			}
+			return nil
+		}},
+		{tx0b, TxStatusUnknown, []TxLifecycleType{TxLifecycleAdded, TxLifecycleDropped}, func(events []*TxLifecycleEvent) error {
+			if events[1].Reason != TxDropInsufficientFunds {
+				return fmt.Errorf("drop reason mismatch: have %q, want %q", events[1].Reason, TxDropInsufficientFunds)
+			}
+			return nil
+		}},
+		{tx2, TxStatusUnknown, []TxLifecycleType{TxLifecycleAdded, TxLifecycleDropped}, func(events []*TxLifecycleEvent) error {
+			if *events[0].Local {
+				return errors.New("remote transaction recorded as local")
+			}
+			return nil
+		}},
+	}
+	for i, tt := range tests {
+		status, history := pool.History(tt.tx.Hash())
+		if status != tt.status {
+			t.Errorf("test %d: status mismatch: have %v, want %v", i, status, tt.status)
+		}
+		if len(history) != len(tt.events) {
+			t.Errorf("test %d: event count mismatch: have %d, want %d", i, len(history), len(tt.events))
+			continue
+		}
+		for j, ev := range history {
+			if ev.Hash != tt.tx.Hash() || ev.Type != tt.events[j] {
+				t.Errorf("test %d, event %d: mismatch: have %x/%s, want %x/%s", i, j, ev.Hash, ev.Type, tt.tx.Hash(), tt.events[j])
+			}
+		}
+		if err := tt.check(history); err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+	}
+	// Ensure all the events were delivered to the subscriber too
+	for i := 0; i < 7; i++ {
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not delivered", i)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event delivered: %x/%s", ev.Hash, ev.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that the content of a single account can be retrieved from the pool,
+// split into pending and queued transactions sorted by nonce.
+func TestTransactionContentFrom(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the content retrieval with
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currenlbchain-devate.AddBalance(account, big.NewInt(1000000))
+
+	otherAccount, _ := deriveSender(transaction(0, 0, other))
+	pool.currenlbchain-devate.AddBalance(otherAccount, big.NewInt(1000000))
+
+	pool.AddRemotes([]*types.Transaction{
+		transaction(1, 100000, key), transaction(0, 100000, key), transaction(3, 100000, key),
+		transaction(0, 100000, other),
+	})
+	pending, queued := pool.ContentFrom(account)
+	if len(pending) != 2 || pending[0].Nonce() != 0 || pending[1].Nonce() != 1 {
+		t.Fatalf("pending content mismatch: have %d transactions, want nonces 0 and 1", len(pending))
+	}
+	if len(queued) != 1 || queued[0].Nonce() != 3 {
+		t.Fatalf("queued content mismatch: have %d transactions, want nonce 3", len(queued))
+	}
+	if pending, queued := pool.ContentFrom(common.Address{}); len(pending) != 0 || len(queued) != 0 {
+		t.Fatalf("unknown account content mismatch: have %d pending, %d queued, want none", len(pending), len(queued))
+	}
+}
+
+// Tests that the built-in and custom admission policies are enforced on new
+// transactions and that the built-in rules can be updated at runtime.
+func TestTransactionAdmissionPolicies(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the admission policies with
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	sender, _ := deriveSender(transaction(0, 0, key))
+	stranger, _ := deriveSender(transaction(0, 0, other))
+
+	pool.currenlbchain-devate.AddBalance(sender, big.NewInt(1000000000))
+	pool.currenlbchain-devate.AddBalance(stranger, big.NewInt(1000000000))
+
+	nonces := make(map[*ecdsa.PrivateKey]uint64)
+	send := func(key *ecdsa.PrivateKey, to common.Address, gasprice int64, local bool) error {
+		tx, _ := types.SignTx(types.NewTransaction(nonces[key], to, big.NewInt(100), 100000, big.NewInt(gasprice), nil), types.HomesteadSigner{}, key)
+		add := pool.AddRemote
+		if local {
+			add = pool.AddLocal
+		}
+		err := add(tx)
+		if err == nil {
+			nonces[key]++
+		}
+		return err
+	}
+	// Restrict the pool to allowlisted senders, local ones included
+	pool.SetPolicy(TxPolicyConfig{RestrictSenders: true, Allowlist: []common.Address{sender}})
+
+	if err := send(key, common.Address{}, 1, false); err != nil {
+		t.Fatalf("allowed sender rejected: %v", err)
+	}
+	if err := send(other, common.Address{}, 1, false); err != ErrSenderNotAllowed {
+		t.Fatalf("remote stranger error mismatch: have %v, want %v", err, ErrSenderNotAllowed)
+	}
+	if err := send(other, common.Address{}, 1, true); err != ErrSenderNotAllowed {
+		t.Fatalf("local stranger error mismatch: have %v, want %v", err, ErrSenderNotAllowed)
+	}
+	if !pool.AllowSender(stranger) || pool.AllowSender(stranger) {
+		t.Fatalf("stranger allowance mismatch")
+	}
+	if err := send(other, common.Address{}, 1, false); err != nil {
+		t.Fatalf("newly allowed sender rejected: %v", err)
+	}
+	if !pool.DisallowSender(stranger) || pool.DisallowSender(stranger) {
+		t.Fatalf("stranger disallowance mismatch")
+	}
+	if err := send(other, common.Address{}, 1, false); err != ErrSenderNotAllowed {
+		t.Fatalf("disallowed sender error mismatch: have %v, want %v", err, ErrSenderNotAllowed)
+	}
+	// Block a destination and check that transactions sent to it are rejected
+	blocked := common.HexToAddress("0xb10cced")
+	if !pool.BlockDestination(blocked) {
+		t.Fatalf("failed to block destination")
+	}
+	if err := send(key, blocked, 1, true); err != ErrDestinationBlocked {
+		t.Fatalf("blocked destination error mismatch: have %v, want %v", err, ErrDestinationBlocked)
+	}
+	if config := pool.Policy(); len(config.Allowlist) != 1 || len(config.Blocklist) != 1 || config.Blocklist[0] != blocked {
+		t.Fatalf("policy mismatch: have %+v", config)
+	}
+	if !pool.UnblockDestination(blocked) {
+		t.Fatalf("failed to unblock destination")
+	}
+	if err := send(key, blocked, 1, false); err != nil {
+		t.Fatalf("unblocked destination rejected: %v", err)
+	}
+	// Require a minimum gas price from a class of senders, exempting local ones
+	pool.SetPolicy(TxPolicyConfig{Classes: []TxSenderClass{{Name: "retail", Senders: []common.Address{sender}, PriceLimit: 10}}})
+
+	if err := send(key, common.Address{}, 9, false); err != ErrClassUnderpriced {
+		t.Fatalf("class underpriced error mismatch: have %v, want %v", err, ErrClassUnderpriced)
+	}
+	if err := send(key, common.Address{}, 9, true); err != nil {
+		t.Fatalf("local class underpriced transaction rejected: %v", err)
+	}
+	if err := send(key, common.Address{}, 10, false); err != nil {
+		t.Fatalf("class priced transaction rejected: %v", err)
+	}
+	if err := send(other, common.Address{}, 1, false); err != nil {
+		t.Fatalf("unclassified sender rejected: %v", err)
+	}
+	// Rate limit the senders, exempting local transactions
+	pool.SetPolicy(TxPolicyConfig{RateLimit: 2, RateWindow: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if err := send(other, common.Address{}, 1, false); err != nil {
+			t.Fatalf("transaction %d within rate limit rejected: %v", i, err)
+		}
+	}
+	if err := send(other, common.Address{}, 1, false); err != ErrSenderRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+	if err := send(other, common.Address{}, 1, true); err != nil {
+		t.Fatalf("local transaction over rate limit rejected: %v", err)
+	}
+	if err := send(key, common.Address{}, 1, false); err != nil {
+		t.Fatalf("other sender rejected by rate limit: %v", err)
+	}
+	// Register a custom policy and check that it is consulted too
+	errCustom := errors.New("custom rejection")
+	pool.AddPolicy(TxPolicyFunc(func(tx *types.Transaction, from common.Address, local bool) error {
+		if tx.GasPrice().Cmp(big.NewInt(100)) > 0 {
+			return errCustom
+		}
+		return nil
+	}))
+	if err := send(key, common.Address{}, 101, false); err != errCustom {
+		t.Fatalf("custom policy error mismatch: have %v, want %v", err, errCustom)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that only transactions actually accepted into the pool count towards the
+// rate limit of their sender, and that transactions already admitted once (e.g.
+// reinjected after a reorg) are not limited again.
+func TestTransactionRateAccounting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the rate limit with
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	sender, _ := deriveSender(transaction(0, 0, key))
+	pool.currenlbchain-devate.AddBalance(sender, big.NewInt(1000000000))
+
+	pool.SetPolicy(TxPolicyConfig{RateLimit: 2, RateWindow: time.Minute})
+
+	errCustom := errors.New("custom rejection")
+	pool.AddPolicy(TxPolicyFunc(func(tx *types.Transaction, from common.Address, local bool) error {
+		if tx.GasPrice().Cmp(big.NewInt(100)) > 0 {
+			return errCustom
+		}
+		return nil
+	}))
+	// Transactions rejected by custom policies or the pool itself must not
+	// consume the rate budget of the sender
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("first transaction rejected: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(101), key)); err != errCustom {
+		t.Fatalf("custom policy error mismatch: have %v, want %v", err, errCustom)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100001, big.NewInt(1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("transaction within rate budget rejected: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), key)); err != ErrSenderRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+	// Reinjected transactions must bypass the exhausted rate limit
+	pool.mu.Lock()
+	errs := pool.reinjectTxsLocked([]*types.Transaction{pricedTransaction(2, 100000, big.NewInt(1), key)}, false)
+	pool.mu.Unlock()
+
+	if errs[0] != nil {
+		t.Fatalf("reinjected transaction rejected: %v", errs[0])
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 0 {
+		t.Fatalf("pool content mismatch: have %d pending, %d queued, want 3 pending", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
@@ -0,0 +1,160 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"os"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+)
+
+// txSnapshot is the full content of the transaction pool, saved to disk on
+// shutdown to allow refilling the pool on the next startup.
+type txSnapshot struct {
+	Time    uint64          // Unix timestamp of the moment the snapshot was taken
+	Locals  []*txSnapshotTx // Transactions originating from the local accounts
+	Remotes []*txSnapshotTx // Transactions received from the network
+}
+
+// txSnapshotTx is a single transaction of a pool snapshot, along with the time it
+// originally arrived into the pool, as it's not part of the transaction encoding.
+type txSnapshotTx struct {
+	Tx   *types.Transaction
+	Time uint64 // Unix timestamp in nanoseconds of the transaction's arrival
+}
+
+// saveTxSnapshot writes a transaction pool snapshot to disk, atomically replacing
+// any previous one.
+func saveTxSnapshot(path string, snap *txSnapshot) error {
+	output, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = rlp.Encode(output, snap); err != nil {
+		output.Close()
+		return err
+	}
+	if err = output.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// loadTxSnapshot reads a transaction pool snapshot from disk, returning nil if
+// there is none.
+func loadTxSnapshot(path string) (*txSnapshot, error) {
+	input, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	snap := new(txSnapshot)
+	if err := rlp.Decode(input, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// saveSnapshot persists the pending and queued transactions of the pool to the
+// configured snapshot file.
+func (pool *TxPool) saveSnapshot() error {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	snap := &txSnapshot{Time: uint64(time.Now().Unix())}
+	for _, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			for _, tx := range list.Flatten() {
+				entry := &txSnapshotTx{Tx: tx, Time: uint64(tx.Time().UnixNano())}
+				if pool.locals.contains(addr) {
+					snap.Locals = append(snap.Locals, entry)
+				} else {
+					snap.Remotes = append(snap.Remotes, entry)
+				}
+			}
+		}
+	}
+	if err := saveTxSnapshot(pool.config.Snapshot, snap); err != nil {
+		return err
+	}
+	log.Info("Saved transaction pool snapshot", "locals", len(snap.Locals), "remotes", len(snap.Remotes))
+	return nil
+}
+
+// restoreSnapshot injects the transactions of the snapshot saved on the last
+// shutdown back into the pool, revalidating them against the current head. The
+// snapshot is removed afterwards, so it is never restored more than once.
+func (pool *TxPool) restoreSnapshot() error {
+	snap, err := loadTxSnapshot(pool.config.Snapshot)
+	if snap == nil && err == nil {
+		return nil
+	}
+	defer os.Remove(pool.config.Snapshot)
+	if err != nil {
+		return err
+	}
+	total := len(snap.Locals) + len(snap.Remotes)
+
+	// Discard the entire snapshot if it's too old to be relevant anymore
+	if age := time.Since(time.Unix(int64(snap.Time), 0)); age > pool.config.SnapshotAge {
+		snapshotDiscardCounter.Inc(int64(total))
+		log.Warn("Discarded stale transaction pool snapshot", "transactions", total, "age", common.PrettyDuration(age))
+		return nil
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Inject the local transactions first to have their accounts marked as local.
+	// Transactions already known (e.g. loaded from the journal) are skipped, any
+	// other rejected one (stale nonce, underpriced, etc) is discarded. Restored
+	// transactions were already admitted once, so they aren't rate limited, and
+	// they keep their original arrival times.
+	restored, discarded := 0, 0
+	for _, batch := range []struct {
+		txs   []*txSnapshotTx
+		local bool
+	}{{snap.Locals, !pool.config.NoLocals}, {snap.Remotes, false}} {
+		txs := make([]*types.Transaction, 0, len(batch.txs))
+		for _, entry := range batch.txs {
+			if pool.all[entry.Tx.Hash()] == nil {
+				entry.Tx.SetTime(time.Unix(0, int64(entry.Time)))
+				txs = append(txs, entry.Tx)
+			}
+		}
+		for _, err := range pool.reinjectTxsLocked(txs, batch.local) {
+			if err != nil {
+				log.Trace("Discarded snapshot transaction", "err", err)
+				discarded++
+				continue
+			}
+			restored++
+		}
+	}
+	snapshotRestoreCounter.Inc(int64(restored))
+	snapshotDiscardCounter.Inc(int64(discarded))
+
+	log.Info("Restored transaction pool snapshot", "transactions", total, "restored", restored, "discarded", discarded)
+	return nil
+}
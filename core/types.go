@@ -35,6 +35,16 @@ type Validator interface {
 	ValidateState(block, parent *types.Block, state *state.StateDB, receipts types.Receipts, usedGas uint64) error
 }
 
+// Prefetcher is an interface for pre-caching the state accessed by a block.
+//
+// Prefetch executes the transactions of a block on top of a throwaway state,
+// discarding all results. Its only purpose is to pull the accessed accounts,
+// storage slots and trie nodes into the caches ahead of the actual processing.
+// It returns early if interrupted, cancelling the transaction in flight.
+type Prefetcher interface {
+	Prefetch(block *types.Block, statedb *state.StateDB, cfg vm.Config, interrupt *PrefetchInterrupt)
+}
+
 // Processor is an interface for processing blocks using a given initial state.
 //
 // Process takes the block to be processed and the statedb upon which the
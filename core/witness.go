@@ -0,0 +1,305 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+)
+
+// Witness is the data needed to execute a block without access to the state
+// of its parent: the trie nodes and contract codes touched during execution and
+// the ancestor headers referenced by it.
+type Witness struct {
+	Block   *types.Block    // Block to execute
+	Headers []*types.Header // Parent header first, followed by the ancestors accessed via BLOCKHASH
+	Codes   [][]byte        // Contract codes accessed during execution
+	Nodes   [][]byte        // State and storage trie nodes accessed during execution
+}
+
+// Size returns the total size of the trie nodes and contract codes in the witness.
+func (w *Witness) Size() common.StorageSize {
+	var size int
+	for _, code := range w.Codes {
+		size += len(code)
+	}
+	for _, node := range w.Nodes {
+		size += len(node)
+	}
+	return common.StorageSize(size)
+}
+
+// GenerateWitness executes a block on top of its parent state, retrieved from
+// the given trie database, and records every piece of data the execution needs.
+// The block is validated against the resulting state before returning.
+func GenerateWitness(bc *BlockChain, block *types.Block, source *trie.Database) (*Witness, error) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	var (
+		recorder = &witnessRecorder{source: source, nodes: make(map[common.Hash][]byte)}
+		database = &witnessStateDatabase{Database: state.NewDatabase(recorder), codes: make(map[common.Hash][]byte)}
+		chain    = &witnessChain{config: bc.Config(), engine: bc.Engine(), source: bc, headers: make(map[common.Hash]*types.Header)}
+	)
+	recorder.MemDatabase, _ = lbchain-devdb.NewMemDatabase()
+
+	statedb, err := state.New(parent.Root, database)
+	if err != nil {
+		return nil, err
+	}
+	if err := executeWitness(chain, block, statedb); err != nil {
+		return nil, err
+	}
+	// Assemble the recorded data, contract codes are read through the trie
+	// database too, so separate them out of the nodes
+	witness := &Witness{
+		Block:   block,
+		Headers: []*types.Header{parent},
+	}
+	for hash, code := range database.codes {
+		witness.Codes = append(witness.Codes, code)
+		delete(recorder.nodes, hash)
+	}
+	for _, node := range recorder.nodes {
+		witness.Nodes = append(witness.Nodes, node)
+	}
+	for hash, header := range chain.headers {
+		if hash != parent.Hash() {
+			witness.Headers = append(witness.Headers, header)
+		}
+	}
+	sort.Slice(witness.Codes, func(i, j int) bool { return bytes.Compare(witness.Codes[i], witness.Codes[j]) < 0 })
+	sort.Slice(witness.Nodes, func(i, j int) bool { return bytes.Compare(witness.Nodes[i], witness.Nodes[j]) < 0 })
+	ancestors := witness.Headers[1:]
+	sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].Number.Cmp(ancestors[j].Number) > 0 })
+	return witness, nil
+}
+
+// VerifyWitness executes the block of a witness using only the data contained
+// within, starting from the state root of the included parent header, and checks
+// that the resulting state, receipts and gas usage match the block header.
+func VerifyWitness(config *params.ChainConfig, engine consensus.Engine, witness *Witness) error {
+	block := witness.Block
+	if block == nil {
+		return errors.New("witness contains no block")
+	}
+	if block.NumberU64() == 0 {
+		return errors.New("genesis is not verifiable")
+	}
+	// Ensure the headers form a contiguous chain leading up to the block
+	if len(witness.Headers) == 0 {
+		return errors.New("witness contains no parent header")
+	}
+	chain := &witnessChain{config: config, engine: engine, headers: make(map[common.Hash]*types.Header)}
+
+	next := block.Header()
+	for _, header := range witness.Headers {
+		if header.Hash() != next.ParentHash || header.Number.Uint64()+1 != next.Number.Uint64() {
+			return fmt.Errorf("header #%d [%x…] is not the parent of #%d [%x…]", header.Number, header.Hash().Bytes()[:4], next.Number, next.Hash().Bytes()[:4])
+		}
+		chain.headers[header.Hash()] = header
+		next = header
+	}
+	// Load the trie nodes and codes into a throwaway database and execute the block
+	db, _ := lbchain-devdb.NewMemDatabase()
+	for _, code := range witness.Codes {
+		db.Put(crypto.Keccak256(code), code)
+	}
+	for _, node := range witness.Nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	statedb, err := state.New(witness.Headers[0].Root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	return executeWitness(chain, block, statedb)
+}
+
+// executeWitness processes a block on top of the given state and validates the
+// outcome against the block header.
+func executeWitness(chain *witnessChain, block *types.Block, statedb *state.StateDB) error {
+	processor := &StateProcessor{config: chain.config, bc: chain, engine: chain.engine}
+
+	receipts, _, usedGas, err := processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("state access failed: %v", err)
+	}
+	validator := NewBlockValidator(chain.config, nil, chain.engine)
+	if err := validator.ValidateState(block, nil, statedb, receipts, usedGas); err != nil {
+		return err
+	}
+	// Hashing may resolve further trie nodes, make sure they were all available
+	return statedb.Error()
+}
+
+// witnessRecorder is a key-value store serving reads from a trie database and
+// recording every node retrieved. Writes are kept in memory.
+type witnessRecorder struct {
+	*lbchain-devdb.MemDatabase
+
+	source *trie.Database
+	nodes  map[common.Hash][]byte
+	lock   sync.Mutex
+}
+
+// Get retrieves a trie node or contract code from the source database, recording
+// it in the witness.
+func (r *witnessRecorder) Get(key []byte) ([]byte, error) {
+	if blob, err := r.MemDatabase.Get(key); err == nil {
+		return blob, nil
+	}
+	if len(key) != common.HashLength {
+		return nil, errors.New("not found")
+	}
+	hash := common.BytesToHash(key)
+	blob, err := r.source.Node(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, errors.New("not found")
+	}
+	r.lock.Lock()
+	r.nodes[hash] = common.CopyBytes(blob)
+	r.lock.Unlock()
+
+	return blob, nil
+}
+
+// Has checks whlbchain-dever a trie node or contract code is available.
+func (r *witnessRecorder) Has(key []byte) (bool, error) {
+	blob, err := r.Get(key)
+	return err == nil && len(blob) > 0, nil
+}
+
+// witnessStateDatabase is a state database recording the contract codes accessed
+// through it.
+type witnessStateDatabase struct {
+	state.Database
+
+	codes map[common.Hash][]byte
+	lock  sync.Mutex
+}
+
+// ContractCode retrieves a particular contract's code, recording it.
+func (db *witnessStateDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.Database.ContractCode(addrHash, codeHash)
+	if err == nil && len(code) > 0 {
+		db.lock.Lock()
+		db.codes[codeHash] = common.CopyBytes(code)
+		db.lock.Unlock()
+	}
+	return code, err
+}
+
+// ContractCodeSize retrieves a particular contract's code size. The code itself
+// is needed to prove the size, so it's recorded too.
+func (db *witnessStateDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+// witnessChain is the chain context of a witness execution. It either serves
+// headers from a source chain, recording the accessed ones, or from the set of
+// headers contained in a witness.
+type witnessChain struct {
+	config *params.ChainConfig
+	engine consensus.Engine
+	source consensus.ChainReader // Chain to retrieve headers from, nil if verifying
+
+	headers map[common.Hash]*types.Header
+	lock    sync.Mutex
+}
+
+// Config retrieves the chain configuration.
+func (c *witnessChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the consensus engine.
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader is unavailable during witness execution.
+func (c *witnessChain) CurrentHeader() *types.Header { return nil }
+
+// GetBlock is unavailable during witness execution.
+func (c *witnessChain) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+
+// GetHeader retrieves a header by hash and number.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.source == nil {
+		if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+			return header
+		}
+		return nil
+	}
+	header := c.source.GetHeader(hash, number)
+	if header != nil {
+		c.headers[hash] = header
+	}
+	return header
+}
+
+// GetHeaderByHash retrieves a header by hash.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.source == nil {
+		return c.headers[hash]
+	}
+	header := c.source.GetHeaderByHash(hash)
+	if header != nil {
+		c.headers[hash] = header
+	}
+	return header
+}
+
+// GetHeaderByNumber retrieves a header by number.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.source == nil {
+		for _, header := range c.headers {
+			if header.Number.Uint64() == number {
+				return header
+			}
+		}
+		return nil
+	}
+	header := c.source.GetHeaderByNumber(number)
+	if header != nil {
+		c.headers[header.Hash()] = header
+	}
+	return header
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+)
+
+// Tests that block witnesses contain everything needed to reexecute a block
+// statelessly, and that incomplete or tampered witnesses fail verification.
+func TestBlockWitness(t *testing.T) {
+	var (
+		db, _    = lbchain-devdb.NewMemDatabase()
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xc0}
+		other    = common.Address{0xc1}
+		// Stores the hash of the block two back, increments a counter and stores
+		// the code size of another contract
+		code  = append(append([]byte{0x60, 0x02, 0x43, 0x03, 0x40, 0x60, 0x00, 0x55, 0x60, 0x01, 0x54, 0x60, 0x01, 0x01, 0x60, 0x01, 0x55, 0x73}, other.Bytes()...), 0x3b, 0x60, 0x02, 0x55, 0x00)
+		gspec = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address:  {Balance: big.NewInt(1000000000)},
+				contract: {Balance: new(big.Int), Code: code, Storage: map[common.Hash]common.Hash{{1}: {1}}},
+				other:    {Balance: new(big.Int), Code: []byte{0x00, 0x00, 0x00}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	// Generate a few blocks for BLOCKHASH to reference, then the one to witness
+	newTx := func(block *BlockGen, to common.Address) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), to, big.NewInt(1), 100000, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, block *BlockGen) {
+		block.AddTx(newTx(block, common.Address{0x01}))
+	})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	tail, _ := GenerateChain(gspec.Config, blocks[2], ethash.NewFaker(), db, 1, func(i int, block *BlockGen) {
+		block.AddTxWithChain(blockchain, newTx(block, contract))
+	})
+	if _, err := blockchain.InsertChain(tail); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	block := tail[0]
+	witness, err := GenerateWitness(blockchain, block, blockchain.stateCache.TrieDB())
+	if err != nil {
+		t.Fatalf("failed to generate witness: %v", err)
+	}
+	if len(witness.Codes) != 2 {
+		t.Errorf("code count mismatch: have %d, want 2", len(witness.Codes))
+	}
+	if len(witness.Headers) != 2 || witness.Headers[0].Hash() != blocks[2].Hash() || witness.Headers[1].Hash() != blocks[1].Hash() {
+		t.Errorf("header mismatch: have %d headers", len(witness.Headers))
+	}
+	// Verify the witness after a serialization roundtrip
+	blob, err := rlp.EncodeToBytes(witness)
+	if err != nil {
+		t.Fatalf("failed to encode witness: %v", err)
+	}
+	decoded := new(Witness)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatalf("failed to decode witness: %v", err)
+	}
+	if err := VerifyWitness(gspec.Config, ethash.NewFaker(), decoded); err != nil {
+		t.Fatalf("failed to verify witness: %v", err)
+	}
+	// Removing any trie node or code should make verification fail
+	for i := range decoded.Nodes {
+		incomplete := *decoded
+		incomplete.Nodes = append(append([][]byte{}, decoded.Nodes[:i]...), decoded.Nodes[i+1:]...)
+		if err := VerifyWitness(gspec.Config, ethash.NewFaker(), &incomplete); err == nil {
+			t.Errorf("node %d: verification succeeded without it", i)
+		}
+	}
+	for i := range decoded.Codes {
+		incomplete := *decoded
+		incomplete.Codes = append(append([][]byte{}, decoded.Codes[:i]...), decoded.Codes[i+1:]...)
+		if err := VerifyWitness(gspec.Config, ethash.NewFaker(), &incomplete); err == nil {
+			t.Errorf("code %d: verification succeeded without it", i)
+		}
+	}
+	// Dropping or tampering with the ancestor headers should fail too
+	incomplete := *decoded
+	incomplete.Headers = decoded.Headers[:1]
+	if err := VerifyWitness(gspec.Config, ethash.NewFaker(), &incomplete); err == nil {
+		t.Errorf("verification succeeded without the BLOCKHASH ancestor")
+	}
+	tampered := *decoded
+	tampered.Headers = []*types.Header{types.CopyHeader(decoded.Headers[0]), decoded.Headers[1]}
+	tampered.Headers[0].Root = common.Hash{}
+	if err := VerifyWitness(gspec.Config, ethash.NewFaker(), &tampered); err == nil {
+		t.Errorf("verification succeeded with a tampered parent header")
+	}
+}
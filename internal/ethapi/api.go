@@ -22,16 +22,20 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
+	"github.com/lbchain-devchain/go-lbchain-dev/accounts/abi"
 	"github.com/lbchain-devchain/go-lbchain-dev/accounts/keystore"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/math"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
 	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
 	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
@@ -46,6 +50,11 @@ import (
 
 const (
 	defaultGasPrice = 50 * params.Shannon
+
+	defaultCallTimeout = 5 * time.Second // Maximum execution time of calls made through the RPC API
+
+	defaultTxPoolPageSize = 100  // Number of pool transactions returned in a page if no limit is given
+	maxTxPoolPageSize     = 1000 // Maximum number of pool transactions returned in a single page
 )
 
 // Publiclbchain-devchainAPI provides an API to access lbchain-devchain related information.
@@ -130,8 +139,209 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
+// ContentFrom returns the transactions contained within the transaction pool that
+// were sent by the given account.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := map[string]map[string]*RPCTransaction{
+		"pending": make(map[string]*RPCTransaction),
+		"queued":  make(map[string]*RPCTransaction),
+	}
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	for _, tx := range pending {
+		content["pending"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	for _, tx := range queue {
+		content["queued"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	return content
+}
+
+// TxPoolPageArgs selects a page of the transactions contained within the pool.
+type TxPoolPageArgs struct {
+	From   *common.Address `json:"from"`   // Only list the transactions sent by this account
+	Status string          `json:"status"` // Transactions to list, "pending" (default) or "queued"
+	Order  string          `json:"order"`  // Ordering of the transactions, "price" (default) or "arrival"
+	Offset hexutil.Uint    `json:"offset"` // Number of transactions to skip
+	Limit  hexutil.Uint    `json:"limit"`  // Maximum number of transactions to return
+}
+
+// TxPoolPage is a page of the transactions contained within the pool.
+type TxPoolPage struct {
+	Transactions []*RPCTransaction `json:"transactions"`
+	Total        hexutil.Uint      `json:"total"` // Number of transactions matching the selection
+	Next         *hexutil.Uint     `json:"next"`  // Offset of the next page, nil if this is the last one
+}
+
+// ContentPage returns a page of the pending or queued transactions contained
+// within the pool, ordered by descending gas price or by arrival time. As the
+// pool changes between calls, consecutive pages may miss or repeat transactions.
+func (s *PublicTxPoolAPI) ContentPage(args TxPoolPageArgs) (*TxPoolPage, error) {
+	limit := int(args.Limit)
+	if limit == 0 {
+		limit = defaultTxPoolPageSize
+	}
+	if limit > maxTxPoolPageSize {
+		return nil, fmt.Errorf("page limit %d exceeds the maximum of %d", limit, maxTxPoolPageSize)
+	}
+	queued := false
+	switch args.Status {
+	case "", "pending":
+	case "queued":
+		queued = true
+	default:
+		return nil, fmt.Errorf("invalid transaction status %q", args.Status)
+	}
+	if args.Order != "" && args.Order != "price" && args.Order != "arrival" {
+		return nil, fmt.Errorf("invalid transaction order %q", args.Order)
+	}
+	// Gather the selected transactions and order them, breaking ties by arrival
+	// time and hash to keep the pages stable
+	var txs types.Transactions
+	if args.From != nil {
+		pending, queue := s.b.TxPoolContentFrom(*args.From)
+		if txs = pending; queued {
+			txs = queue
+		}
+	} else {
+		pending, queue := s.b.TxPoolContent()
+		content := pending
+		if queued {
+			content = queue
+		}
+		for _, list := range content {
+			txs = append(txs, list...)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if args.Order != "arrival" {
+			if cmp := txs[i].GasPrice().Cmp(txs[j].GasPrice()); cmp != 0 {
+				return cmp > 0
+			}
+		}
+		if ti, tj := txs[i].Time(), txs[j].Time(); !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return bytes.Compare(txs[i].Hash().Bytes(), txs[j].Hash().Bytes()) < 0
+	})
+	// Cut out the requested page
+	start, end := int(args.Offset), int(args.Offset)+limit
+	if start > len(txs) {
+		start = len(txs)
+	}
+	if end > len(txs) {
+		end = len(txs)
+	}
+	page := &TxPoolPage{
+		Transactions: make([]*RPCTransaction, 0, end-start),
+		Total:        hexutil.Uint(len(txs)),
+	}
+	for _, tx := range txs[start:end] {
+		page.Transactions = append(page.Transactions, newRPCPendingTransaction(tx))
+	}
+	if end < len(txs) {
+		next := hexutil.Uint(end)
+		page.Next = &next
+	}
+	return page, nil
+}
+
+// TxPoolTransaction is a transaction contained within the pool along with its
+// status in there.
+type TxPoolTransaction struct {
+	Status      string          `json:"status"`
+	Transaction *RPCTransaction `json:"transaction"`
+}
+
+// Transactions creates a subscription that is notified with the full transaction
+// objects sent by any of the given accounts, whenever they are added to the pool
+// or move between its pending and queued sets.
+func (s *PublicTxPoolAPI) Transactions(ctx context.Context, addresses []common.Address) (*rpc.Subscription, error) {
+	if len(addresses) == 0 {
+		return &rpc.Subscription{}, errors.New("no accounts to watch")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	watched := make(map[common.Address]struct{})
+	for _, addr := range addresses {
+		watched[addr] = struct{}{}
+	}
+	// Subscribe to the pool before returning, so no event happening after the
+	// subscription was created is missed
+	events := make(chan core.TxLifecycleEvent, 128)
+	sub := s.b.SubscribeTxLifecycleEvent(events)
+
+	go func() {
+		defer sub.Unsubscribe()
+
+		// Track the last status delivered for each transaction, so the promotion
+		// of a transaction already reported as pending is not delivered twice
+		delivered := make(map[common.Hash]string)
+		for {
+			select {
+			case ev := <-events:
+				var status string
+				switch ev.Type {
+				case core.TxLifecycleAdded:
+					current, _ := s.b.TxPoolHistory(ev.Hash)
+					if current != core.TxStatusPending && current != core.TxStatusQueued {
+						continue
+					}
+					status = current.String()
+				case core.TxLifecyclePromoted:
+					status = core.TxStatusPending.String()
+				case core.TxLifecycleDemoted:
+					status = core.TxStatusQueued.String()
+				default:
+					delete(delivered, ev.Hash)
+					continue
+				}
+				if delivered[ev.Hash] == status {
+					continue
+				}
+				tx := s.b.GetPoolTransaction(ev.Hash)
+				if tx == nil {
+					continue
+				}
+				rpcTx := newRPCPendingTransaction(tx)
+				if _, ok := watched[rpcTx.From]; !ok {
+					continue
+				}
+				delivered[ev.Hash] = status
+				notifier.Notify(rpcSub.ID, &TxPoolTransaction{Status: status, Transaction: rpcTx})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// TxPoolStatus is the status of a single transaction in the pool, along with the
+// lifecycle events recorded for it.
+type TxPoolStatus struct {
+	Hash   common.Hash              `json:"hash"`
+	Status string                   `json:"status"`
+	Events []*core.TxLifecycleEvent `json:"events"`
+}
+
+// Status returns the number of pending and queued transaction in the pool. If a
+// transaction hash is given, the status and the recorded lifecycle history of
+// that transaction are returned instead.
+func (s *PublicTxPoolAPI) Status(hash *common.Hash) interface{} {
+	if hash != nil {
+		status, events := s.b.TxPoolHistory(*hash)
+		if events == nil {
+			events = []*core.TxLifecycleEvent{}
+		}
+		return &TxPoolStatus{Hash: *hash, Status: status.String(), Events: events}
+	}
 	pending, queue := s.b.Stats()
 	return map[string]hexutil.Uint{
 		"pending": hexutil.Uint(pending),
@@ -139,6 +349,36 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// Lifecycle creates a subscription that is notified of every lifecycle event of
+// the transactions in the pool, optionally only of those of a single one.
+func (s *PublicTxPoolAPI) Lifecycle(ctx context.Context, hash *common.Hash) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.TxLifecycleEvent, 128)
+		sub := s.b.SubscribeTxLifecycleEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				if hash == nil || ev.Hash == *hash {
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -601,6 +841,82 @@ func (s *PublicBlockChainAPI) Gelbchain-devorageAt(ctx context.Context, address
 	return res[:], state.Error()
 }
 
+// AccountResult is the result of a GetProof call, holding the Merkle proof of
+// an account and of the requested storage slots within it.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the Merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the account and storage values of the specified account
+// including the Merkle proofs (EIP-1186), so that they can be verified against
+// the state root of the given block number.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Non-existent accounts are reported as empty ones, proving their absence
+	var (
+		storageTrie = state.StorageTrie(address)
+		storageHash = types.EmptyRootHash
+		codeHash    = state.GetCodeHash(address)
+	)
+	if storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	} else {
+		codeHash = crypto.Keccak256Hash(nil)
+	}
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, key := range storageKeys {
+		storageProof[i] = StorageResult{Key: key, Value: new(hexutil.Big), Proof: []hexutil.Bytes{}}
+		if storageTrie == nil {
+			continue
+		}
+		slot := common.HexToHash(key)
+		proof, err := state.StorageProof(address, slot)
+		if err != nil {
+			return nil, err
+		}
+		storageProof[i].Value = (*hexutil.Big)(state.Gelbchain-devate(address, slot).Big())
+		storageProof[i].Proof = toHexBytes(proof)
+	}
+	accountProof, err := state.AccountProof(address)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexBytes(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, state.Error()
+}
+
+// toHexBytes converts a list of binary blobs into their hex encoded RPC form.
+func toHexBytes(blobs [][]byte) []hexutil.Bytes {
+	res := make([]hexutil.Bytes, len(blobs))
+	for i, blob := range blobs {
+		res[i] = blob
+	}
+	return res
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
@@ -611,17 +927,14 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
-
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
-	if state == nil || err != nil {
-		return nil, 0, false, err
-	}
+// ToMessage converts the call arguments to a message, defaulting the sender to
+// the first account of the given manager and the gas and gas price to
+// unmetered values if they are not specified.
+func (args *CallArgs) ToMessage(am *accounts.Manager) types.Message {
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+		if wallets := am.Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 				addr = accounts[0].Address
 			}
@@ -635,9 +948,129 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
+	return types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+}
+
+// OverrideAccount indicates the overriding fields of an account during the
+// execution of a call. The state and state diff fields are mutually exclusive:
+// the former replaces the entire storage of the account, while the latter only
+// replaces the given slots.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   *hexutil.Big                 `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
 
+// StateOverride is the collection of accounts overridden during the execution
+// of a call.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
+	}
+	for addr, account := range *diff {
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		if account.Nonce != nil {
+			state.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			state.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			state.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+		if account.State != nil {
+			state.SetStorage(addr, *account.State)
+		}
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				state.Selbchain-devate(addr, key, value)
+			}
+		}
+	}
+	return nil
+}
+
+// BlockOverrides is the set of block context fields overridden during the
+// execution of a call.
+type BlockOverrides struct {
+	Number   *hexutil.Big    `json:"number"`
+	Time     *hexutil.Big    `json:"timestamp"`
+	Coinbase *common.Address `json:"coinbase"`
+	GasLimit *hexutil.Uint64 `json:"gasLimit"`
+}
+
+// Apply overrides the given fields of the EVM context.
+func (diff *BlockOverrides) Apply(context *vm.Context) {
+	if diff == nil {
+		return
+	}
+	if diff.Number != nil {
+		context.BlockNumber = new(big.Int).Set(diff.Number.ToInt())
+	}
+	if diff.Time != nil {
+		context.Time = new(big.Int).Set(diff.Time.ToInt())
+	}
+	if diff.Coinbase != nil {
+		context.Coinbase = *diff.Coinbase
+	}
+	if diff.GasLimit != nil {
+		context.GasLimit = uint64(*diff.GasLimit)
+	}
+}
+
+// chainContext is a core.ChainContext retrieving the headers through the API
+// backend, allowing EVM contexts to be assembled outside of the backend.
+type chainContext struct {
+	ctx context.Context
+	b   Backend
+}
+
+func (c *chainContext) Engine() consensus.Engine {
+	return c.b.Engine()
+}
+
+// GetHeader retrieves a canonical header, which is all the BLOCKHASH opcode
+// needs to be resolved.
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header, err := c.b.HeaderByNumber(c.ctx, rpc.BlockNumber(number))
+	if header == nil || err != nil || header.Hash() != hash {
+		return nil
+	}
+	return header
+}
+
+// newEVM creates an EVM executing a message on top of the given state and block,
+// with the fields of the block context optionally overridden. Contrary to the
+// backend's GetEVM, the sender isn't credited any funds.
+func (s *PublicBlockChainAPI) newEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, blockOverrides *BlockOverrides, vmCfg vm.Config) *vm.EVM {
+	context := core.NewEVMContext(msg, header, &chainContext{ctx: ctx, b: s.b}, nil)
+	blockOverrides.Apply(&context)
+
+	return vm.NewEVM(context, state, s.b.ChainConfig(), vmCfg)
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, blockOverrides *BlockOverrides, vmCfg vm.Config, timeout time.Duration) (*core.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+	msg := args.ToMessage(s.b.AccountManager())
+
+	// Credit the sender to afford the call, unless its balance is overridden
+	state.SetBalance(msg.From(), math.MaxBig256)
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -652,10 +1085,8 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer cancel()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
-	if err != nil {
-		return nil, 0, false, err
-	}
+	evm := s.newEVM(ctx, msg, state, header, blockOverrides, vmCfg)
+
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -666,23 +1097,66 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
-	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
-	if err := vmError(); err != nil {
-		return nil, 0, false, err
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if err := state.Error(); err != nil {
+		return nil, err
+	}
+	return result, err
+}
+
+// revertError is an API error that encompasses an EVM revert with a JSON error
+// code and the hex encoded revert data as the error data.
+type revertError struct {
+	error
+	reason string // revert data, hex encoded
+}
+
+// newRevertError creates a revertError instance with the provided revert data,
+// decoding the revert reason into the error message if it is a standard one.
+func newRevertError(result *core.ExecutionResult) *revertError {
+	reason, errUnpack := abi.UnpackRevert(result.Revert())
+	err := errors.New("execution reverted")
+	if errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(result.Revert()),
 	}
-	return res, gas, failed, err
+}
+
+// ErrorCode returns the JSON error code for a revert.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert reason.
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+//
+// The state of any account and the block context can optionally be overridden
+// for the duration of the call. Reverted calls return an error holding both the
+// raw revert data and, if decodable, the revert reason.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Bytes, error) {
+	result, err := s.doCall(ctx, args, blockNr, overrides, blockOverrides, vm.Config{}, defaultCallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if result.Err == vm.ErrExecutionReverted {
+		return nil, newRevertError(result)
+	}
+	return (hexutil.Bytes)(result.ReturnData), nil
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+// given transaction against the current pending block, optionally overriding
+// the state of any account and the block context.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -691,6 +1165,8 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	)
 	if uint64(args.Gas) >= params.TxGas {
 		hi = uint64(args.Gas)
+	} else if blockOverrides != nil && blockOverrides.GasLimit != nil {
+		hi = uint64(*blockOverrides.GasLimit)
 	} else {
 		// Retrieve the current pending block to act as the gas ceiling
 		block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
@@ -702,19 +1178,19 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (bool, *core.ExecutionResult) {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
-		if err != nil || failed {
-			return false
+		result, err := s.doCall(ctx, args, rpc.PendingBlockNumber, overrides, blockOverrides, vm.Config{}, 0)
+		if err != nil || result.Failed() {
+			return false, result
 		}
-		return true
+		return true, result
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -722,13 +1198,157 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if ok, result := executable(hi); !ok {
+			if result != nil && result.Err == vm.ErrExecutionReverted {
+				return 0, newRevertError(result)
+			}
 			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
 	return hexutil.Uint64(hi), nil
 }
 
+// CallBundleConfig are the optional parameters of a call bundle simulation.
+type CallBundleConfig struct {
+	StateOverrides *StateOverride  `json:"stateOverrides"`
+	BlockOverrides *BlockOverrides `json:"blockOverrides"`
+	StateDiff      bool            `json:"stateDiff"` // Whlbchain-dever to return the state changes made by the bundle
+	Timeout        *hexutil.Uint64 `json:"timeout"`   // Maximum execution time of the bundle in milliseconds (default = same as eth_call)
+}
+
+// CallBundleResult is the outcome of a single call executed within a bundle.
+type CallBundleResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Logs       []*types.Log   `json:"logs"`
+	Failed     bool           `json:"failed"`
+	Error      string         `json:"error,omitempty"`
+	Revert     hexutil.Bytes  `json:"revert,omitempty"`
+	Reason     string         `json:"revertReason,omitempty"`
+}
+
+// CallBundleResults is the outcome of a call bundle simulation.
+type CallBundleResults struct {
+	BlockNumber hexutil.Uint64      `json:"blockNumber"`
+	GasUsed     hexutil.Uint64      `json:"gasUsed"`
+	Results     []*CallBundleResult `json:"results"`
+	StateDiff   state.StateDiff     `json:"stateDiff,omitempty"`
+}
+
+// CallBundle executes the given calls sequentially on top of the state of the
+// given block, each call seeing the changes made by the previous ones. Neither
+// the state nor the transaction pool is modified.
+//
+// Calls rejected before execution (e.g. due to insufficient funds) are reported
+// with an error and leave the state untouched for the subsequent calls. As the
+// senders must afford their calls, an omitted gas allowance defaults to the
+// block gas limit and an omitted gas price to zero.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber, config *CallBundleConfig) (*CallBundleResults, error) {
+	defer func(start time.Time) {
+		log.Debug("Executing call bundle finished", "calls", len(calls), "runtime", time.Since(start))
+	}(time.Now())
+
+	if len(calls) == 0 {
+		return nil, errors.New("empty call bundle")
+	}
+	if config == nil {
+		config = new(CallBundleConfig)
+	}
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if err := config.StateOverrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	if config.StateDiff {
+		statedb.EnableDiffs()
+	}
+	// Bound the execution of the entire bundle, cancelling every EVM on return
+	timeout := defaultCallTimeout
+	if config.Timeout != nil {
+		timeout = time.Duration(*config.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	gasLimit := header.GasLimit
+	if config.BlockOverrides != nil && config.BlockOverrides.GasLimit != nil {
+		gasLimit = uint64(*config.BlockOverrides.GasLimit)
+	}
+
+	results := &CallBundleResults{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Results:     make([]*CallBundleResult, 0, len(calls)),
+	}
+	for i, args := range calls {
+		msg := args.ToMessage(s.b.AccountManager())
+
+		gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
+		if gas == 0 {
+			gas = gasLimit
+		}
+		msg = types.NewMessage(msg.From(), msg.To(), 0, msg.Value(), gas, gasPrice, msg.Data(), false)
+
+		// Calls have no hash of their own, identify their logs by index instead
+		hash := common.BigToHash(big.NewInt(int64(i)))
+		statedb.Prepare(hash, common.Hash{}, i)
+
+		// Contrary to single calls, the senders aren't credited any funds, the
+		// bundle must be affordable on top of the state it's executed on
+		evm := s.newEVM(ctx, msg, statedb, header, config.BlockOverrides, vm.Config{})
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		snapshot := statedb.Snapshot()
+		gp := new(core.GasPool).AddGas(math.MaxUint64)
+		res, err := core.ApplyMessage(evm, msg, gp)
+		if err := statedb.Error(); err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("call %d timed out", i)
+		}
+		result := &CallBundleResult{Logs: []*types.Log{}}
+		if err != nil {
+			statedb.RevertToSnapshot(snapshot)
+			result.Failed, result.Error = true, err.Error()
+		} else {
+			result.GasUsed = hexutil.Uint64(res.UsedGas)
+			if res.Failed() {
+				result.Failed, result.Error = true, res.Err.Error()
+				if revert := res.Revert(); len(revert) > 0 {
+					result.Revert = revert
+					result.Reason, _ = abi.UnpackRevert(revert)
+				}
+			} else {
+				result.ReturnData = res.Return()
+			}
+			if logs := statedb.GetLogs(hash); logs != nil {
+				result.Logs = logs
+			}
+			results.GasUsed += result.GasUsed
+		}
+		results.Results = append(results.Results, result)
+
+		// Finalise the call the same way the block processor does a transaction
+		statedb.Finalise(evm.ChainConfig().IsEIP158(evm.BlockNumber))
+	}
+	if config.StateDiff {
+		results.StateDiff = make(state.StateDiff)
+		for _, diff := range statedb.Diffs() {
+			results.StateDiff.Merge(diff.Diff)
+		}
+	}
+	return results, nil
+}
+
+// Simulate is an alias of CallBundle.
+func (s *PublicBlockChainAPI) Simulate(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber, config *CallBundleConfig) (*CallBundleResults, error) {
+	return s.CallBundle(ctx, calls, blockNr, config)
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
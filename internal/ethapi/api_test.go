@@ -0,0 +1,554 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/event"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/rpc"
+)
+
+// testBackend is an API backend serving a local chain. Any backend method not
+// needed by the tests panics.
+type testBackend struct {
+	Backend
+
+	db    lbchain-devdb.Database
+	chain *core.BlockChain
+	pool  *core.TxPool
+	am    *accounts.Manager
+}
+
+// newTestBackend creates a backend with a chain of the given length on top of a
+// genesis block holding the given allocation, and an empty transaction pool.
+func newTestBackend(t *testing.T, config *params.ChainConfig, alloc core.GenesisAlloc, blocks int) *testBackend {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	gspec := &core.Genesis{Config: config, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	generated, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, blocks, nil)
+	if n, err := chain.InsertChain(generated); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	return &testBackend{
+		db:    db,
+		chain: chain,
+		pool:  core.NewTxPool(poolConfig, config, chain),
+		am:    accounts.NewManager(),
+	}
+}
+
+// close stops the transaction pool and the chain of the backend.
+func (b *testBackend) close() {
+	b.pool.Stop()
+	b.chain.Stop()
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager { return b.am }
+func (b *testBackend) ChainConfig() *params.ChainConfig  { return b.chain.Config() }
+func (b *testBackend) Engine() consensus.Engine          { return b.chain.Engine() }
+func (b *testBackend) CurrentBlock() *types.Block        { return b.chain.CurrentBlock() }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock().Header(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	return b.pool.Get(hash)
+}
+
+func (b *testBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.pool.Content()
+}
+
+func (b *testBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.pool.ContentFrom(addr)
+}
+
+func (b *testBackend) TxPoolHistory(hash common.Hash) (core.TxStatus, []*core.TxLifecycleEvent) {
+	return b.pool.History(hash)
+}
+
+func (b *testBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return b.pool.SubscribeTxLifecycleEvent(ch)
+}
+
+// Tests that the balance of the sender of a call can be overridden, instead of
+// being replaced by the funds credited to afford the call.
+func TestCallSenderBalanceOverride(t *testing.T) {
+	var (
+		sender = common.Address{0x01}
+		prober = common.Address{0xc0} // Returns the balance of the caller
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		prober: {Balance: new(big.Int), Code: []byte{0x33, 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}},
+	}, 1)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	args := CallArgs{From: sender, To: &prober, Gas: 50000, GasPrice: hexutil.Big(*big.NewInt(1))}
+
+	// Without overrides, the sender should be credited to afford the call
+	ret, err := api.Call(context.Background(), args, rpc.LatestBlockNumber, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.BitLen() < 255 {
+		t.Errorf("credited balance mismatch: have %v", have)
+	}
+	// With the balance overridden, only the upfront gas cost should be deducted
+	overrides := &StateOverride{sender: {Balance: (*hexutil.Big)(big.NewInt(1000000))}}
+
+	ret, err = api.Call(context.Background(), args, rpc.LatestBlockNumber, overrides, nil)
+	if err != nil {
+		t.Fatalf("failed to execute call with overrides: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Int64() != 950000 {
+		t.Errorf("overridden balance mismatch: have %v, want %d", have, 950000)
+	}
+	// Calls the overridden balance can't afford should be rejected
+	overrides = &StateOverride{sender: {Balance: (*hexutil.Big)(big.NewInt(1000))}}
+	if _, err := api.Call(context.Background(), args, rpc.LatestBlockNumber, overrides, nil); err == nil {
+		t.Errorf("unaffordable call accepted")
+	}
+}
+
+// Tests that overriding the block number of a call switches the chain rules the
+// call is executed with.
+func TestCallBlockNumberOverride(t *testing.T) {
+	config := *params.TestChainConfig
+	config.ByzantiumBlock = big.NewInt(10)
+
+	// Returns the block number after executing the Byzantium RETURNDATASIZE opcode
+	prober := common.Address{0xc0}
+	backend := newTestBackend(t, &config, core.GenesisAlloc{
+		prober: {Balance: new(big.Int), Code: []byte{0x3d, 0x50, 0x43, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}},
+	}, 2)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	args := CallArgs{From: common.Address{0x01}, To: &prober, Gas: 50000}
+
+	// Before the fork, the opcode is invalid and the call fails without output
+	ret, err := api.Call(context.Background(), args, rpc.LatestBlockNumber, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+	if len(ret) != 0 {
+		t.Errorf("pre-fork call succeeded: %x", ret)
+	}
+	// Overriding the block number past the fork should activate it
+	overrides := &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(10))}
+
+	ret, err = api.Call(context.Background(), args, rpc.LatestBlockNumber, nil, overrides)
+	if err != nil {
+		t.Fatalf("failed to execute call with overridden number: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Int64() != 10 {
+		t.Errorf("block number mismatch: have %v, want %d", have, 10)
+	}
+}
+
+// Contracts used by the call bundle tests, both incrementing their first storage
+// slot, the counter returning the new value, the reverter reverting afterwards.
+var (
+	bundleCounter  = []byte{0x60, 0x00, 0x54, 0x60, 0x01, 0x01, 0x80, 0x60, 0x00, 0x55, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+	bundleReverter = []byte{0x60, 0x00, 0x54, 0x60, 0x01, 0x01, 0x60, 0x00, 0x55, 0x60, 0x00, 0x60, 0x00, 0xfd}
+)
+
+// Tests that the calls of a bundle are executed on top of each other, reverted
+// ones not affecting the subsequent calls, and that the state diff reports the
+// changes made by the entire bundle.
+func TestCallBundle(t *testing.T) {
+	var (
+		sender   = common.Address{0x01}
+		counter  = common.Address{0xc1}
+		reverter = common.Address{0xc2}
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		sender:   {Balance: big.NewInt(1000000000)},
+		counter:  {Balance: new(big.Int), Code: bundleCounter},
+		reverter: {Balance: new(big.Int), Code: bundleReverter},
+	}, 1)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	calls := []CallArgs{
+		{From: sender, To: &counter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+		{From: sender, To: &counter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+		{From: sender, To: &reverter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+		{From: sender, To: &counter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+	}
+	res, err := api.CallBundle(context.Background(), calls, rpc.LatestBlockNumber, &CallBundleConfig{StateDiff: true})
+	if err != nil {
+		t.Fatalf("failed to execute bundle: %v", err)
+	}
+	if len(res.Results) != len(calls) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(res.Results), len(calls))
+	}
+	for i, want := range []int64{1, 2, 0, 3} {
+		result := res.Results[i]
+		if reverted := want == 0; result.Failed != reverted {
+			t.Errorf("call %d: failure mismatch: have %v, want %v", i, result.Failed, reverted)
+		}
+		if have := new(big.Int).SetBytes(result.ReturnData); have.Int64() != want {
+			t.Errorf("call %d: counter mismatch: have %v, want %d", i, have, want)
+		}
+	}
+	// The reverted call should not leave any trace in the state
+	if diff := res.StateDiff[reverter]; diff != nil && len(diff.Storage) > 0 {
+		t.Errorf("reverted call changed the storage: %v", diff.Storage)
+	}
+	if diff := res.StateDiff[counter]; diff == nil || diff.Storage[common.Hash{}] == nil || diff.Storage[common.Hash{}].To != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("counter storage diff mismatch: %v", diff)
+	}
+	// The sender should only pay for the gas used, without being credited funds
+	want := new(big.Int).Sub(big.NewInt(1000000000), new(big.Int).SetUint64(uint64(res.GasUsed)))
+	if diff := res.StateDiff[sender]; diff == nil || diff.Balance == nil || diff.Balance.From.ToInt().Int64() != 1000000000 || diff.Balance.To.ToInt().Cmp(want) != 0 {
+		t.Errorf("sender balance diff mismatch: have %v, want %v", diff, want)
+	}
+}
+
+// Tests that unaffordable calls of a bundle are rejected without affecting the
+// subsequent ones, and that the state and block overrides are honoured.
+func TestCallBundleOverrides(t *testing.T) {
+	var (
+		rich     = common.Address{0x01}
+		poor     = common.Address{0x02}
+		counter  = common.Address{0xc1}
+		coinbase = common.Address{0xcb}
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		rich:    {Balance: big.NewInt(1000000000)},
+		poor:    {Balance: big.NewInt(1000)},
+		counter: {Balance: new(big.Int), Code: bundleCounter},
+	}, 1)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	calls := []CallArgs{
+		{From: poor, To: &counter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+		{From: rich, To: &counter, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))},
+	}
+	// Without overrides, the poor sender can't afford its call
+	res, err := api.CallBundle(context.Background(), calls, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to execute bundle: %v", err)
+	}
+	if !res.Results[0].Failed || res.Results[0].Error == "" || res.Results[0].GasUsed != 0 {
+		t.Errorf("unaffordable call not rejected: %+v", res.Results[0])
+	}
+	if have := new(big.Int).SetBytes(res.Results[1].ReturnData); res.Results[1].Failed || have.Int64() != 1 {
+		t.Errorf("call after rejection mismatch: failed %v, counter %v", res.Results[1].Failed, have)
+	}
+	// Override the balance of the poor sender and the counter, crediting the fees
+	// to an overridden coinbase
+	five := common.BigToHash(big.NewInt(5))
+	config := &CallBundleConfig{
+		StateOverrides: &StateOverride{
+			poor:    {Balance: (*hexutil.Big)(big.NewInt(1000000))},
+			counter: {StateDiff: &map[common.Hash]common.Hash{{}: five}},
+		},
+		BlockOverrides: &BlockOverrides{Coinbase: &coinbase},
+		StateDiff:      true,
+	}
+	if res, err = api.CallBundle(context.Background(), calls, rpc.LatestBlockNumber, config); err != nil {
+		t.Fatalf("failed to execute bundle with overrides: %v", err)
+	}
+	for i, want := range []int64{6, 7} {
+		if have := new(big.Int).SetBytes(res.Results[i].ReturnData); res.Results[i].Failed || have.Int64() != want {
+			t.Errorf("call %d: counter mismatch: failed %v, have %v, want %d", i, res.Results[i].Failed, have, want)
+		}
+	}
+	if diff := res.StateDiff[poor]; diff == nil || diff.Balance == nil || diff.Balance.From.ToInt().Int64() != 1000000 {
+		t.Errorf("overridden balance diff mismatch: %v", diff)
+	}
+	if diff := res.StateDiff[coinbase]; diff == nil || diff.Balance == nil || diff.Balance.To.ToInt().Uint64() != uint64(res.GasUsed) {
+		t.Errorf("coinbase balance diff mismatch: have %v, want %d", diff, res.GasUsed)
+	}
+}
+
+// Tests that the pool content is paged through ordered by price or by arrival,
+// breaking ties by arrival time and hash.
+func TestTxPoolContentPage(t *testing.T) {
+	var (
+		key1, _ = crypto.GenerateKey()
+		key2, _ = crypto.GenerateKey()
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		signer  = types.NewEIP155Signer(params.TestChainConfig.ChainId)
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		addr1: {Balance: big.NewInt(1000000000)},
+		addr2: {Balance: big.NewInt(1000000000)},
+	}, 1)
+	defer backend.close()
+
+	// Create the transactions with explicit arrival times, c and d tying on both
+	// price and time
+	base := time.Unix(1000000, 0)
+	pooled := func(key *ecdsa.PrivateKey, nonce uint64, price int64, arrival int) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, new(big.Int), 21000, big.NewInt(price), nil), signer, key)
+		tx.SetTime(base.Add(time.Duration(arrival) * time.Second))
+		return tx
+	}
+	var (
+		a = pooled(key1, 0, 3, 1)
+		b = pooled(key1, 1, 1, 2)
+		c = pooled(key1, 2, 2, 3)
+		d = pooled(key2, 0, 2, 3)
+		e = pooled(key2, 1, 2, 0)
+		f = pooled(key2, 3, 5, 4) // Nonce gap, queued
+	)
+	for i, err := range backend.pool.AddRemotes([]*types.Transaction{a, b, c, d, e, f}) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	tied := []*types.Transaction{c, d}
+	if bytes.Compare(d.Hash().Bytes(), c.Hash().Bytes()) < 0 {
+		tied[0], tied[1] = d, c
+	}
+	next := func(n uint) *hexutil.Uint {
+		offset := hexutil.Uint(n)
+		return &offset
+	}
+	tests := []struct {
+		args  TxPoolPageArgs
+		txs   []*types.Transaction
+		total uint
+		next  *hexutil.Uint
+	}{
+		// Paging through the pending transactions ordered by price
+		{TxPoolPageArgs{Limit: 2}, []*types.Transaction{a, e}, 5, next(2)},
+		{TxPoolPageArgs{Offset: 2, Limit: 2}, tied, 5, next(4)},
+		{TxPoolPageArgs{Offset: 4, Limit: 2}, []*types.Transaction{b}, 5, nil},
+		{TxPoolPageArgs{Offset: 3, Limit: 2}, []*types.Transaction{tied[1], b}, 5, nil},
+		{TxPoolPageArgs{Offset: 5, Limit: 2}, []*types.Transaction{}, 5, nil},
+		{TxPoolPageArgs{Offset: 10, Limit: 2}, []*types.Transaction{}, 5, nil},
+
+		// Default page size, arrival ordering, queued and per account selections
+		{TxPoolPageArgs{}, []*types.Transaction{a, e, tied[0], tied[1], b}, 5, nil},
+		{TxPoolPageArgs{Order: "arrival"}, []*types.Transaction{e, a, b, tied[0], tied[1]}, 5, nil},
+		{TxPoolPageArgs{Order: "arrival", Limit: 4}, []*types.Transaction{e, a, b, tied[0]}, 5, next(4)},
+		{TxPoolPageArgs{Status: "queued"}, []*types.Transaction{f}, 1, nil},
+		{TxPoolPageArgs{From: &addr1, Order: "arrival"}, []*types.Transaction{a, b, c}, 3, nil},
+		{TxPoolPageArgs{From: &addr2, Status: "queued"}, []*types.Transaction{f}, 1, nil},
+		{TxPoolPageArgs{From: &common.Address{0xff}}, []*types.Transaction{}, 0, nil},
+	}
+	api := NewPublicTxPoolAPI(backend)
+	for i, tt := range tests {
+		page, err := api.ContentPage(tt.args)
+		if err != nil {
+			t.Errorf("test %d: failed to retrieve page: %v", i, err)
+			continue
+		}
+		if uint(page.Total) != tt.total {
+			t.Errorf("test %d: total mismatch: have %d, want %d", i, page.Total, tt.total)
+		}
+		if (page.Next == nil) != (tt.next == nil) || (page.Next != nil && *page.Next != *tt.next) {
+			t.Errorf("test %d: next offset mismatch: have %v, want %v", i, page.Next, tt.next)
+		}
+		if len(page.Transactions) != len(tt.txs) {
+			t.Errorf("test %d: transaction count mismatch: have %d, want %d", i, len(page.Transactions), len(tt.txs))
+			continue
+		}
+		for j, tx := range page.Transactions {
+			if tx.Hash != tt.txs[j].Hash() {
+				t.Errorf("test %d: transaction %d mismatch: have %x, want %x", i, j, tx.Hash, tt.txs[j].Hash())
+			}
+		}
+	}
+	// Invalid selections should be rejected
+	for i, args := range []TxPoolPageArgs{{Limit: maxTxPoolPageSize + 1}, {Status: "included"}, {Order: "nonce"}} {
+		if _, err := api.ContentPage(args); err == nil {
+			t.Errorf("invalid selection %d accepted", i)
+		}
+	}
+}
+
+// Tests that the transactions subscription delivers the transactions of the
+// watched accounts as they are added to, promoted and demoted in the pool.
+func TestTxPoolTransactionsSubscription(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		other, _ = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		stranger = crypto.PubkeyToAddress(other.PublicKey)
+		signer   = types.NewEIP155Signer(params.TestChainConfig.ChainId)
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		sender:   {Balance: big.NewInt(1000000000)},
+		stranger: {Balance: big.NewInt(1000000000)},
+	}, 1)
+	defer backend.close()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("txpool", NewPublicTxPoolAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	notifications := make(chan *TxPoolTransaction)
+	sub, err := client.Subscribe(context.Background(), "txpool", notifications, "transactions", []common.Address{sender})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Notifications are only sent once the server activated the subscription
+	// after replying, give it some time to avoid missing the first events
+	time.Sleep(100 * time.Millisecond)
+
+	transfer := func(key *ecdsa.PrivateKey, nonce uint64, amount int64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{0xaa}, big.NewInt(amount), 21000, big.NewInt(1), nil), signer, key)
+		return tx
+	}
+	expect := func(tx *types.Transaction, status string) {
+		select {
+		case ev := <-notifications:
+			if ev.Transaction.Hash != tx.Hash() || ev.Status != status {
+				t.Fatalf("notification mismatch: have %x %s, want %x %s", ev.Transaction.Hash, ev.Status, tx.Hash(), status)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("notification timeout: want %x %s", tx.Hash(), status)
+		}
+	}
+	// A gapped transaction should be reported as queued, then promoted once the
+	// gap is filled. The cost of the second one can only be afforded until the
+	// balance is reduced.
+	a, b, c := transfer(key, 0, 0), transfer(key, 1, 900000000), transfer(key, 2, 0)
+
+	if err := backend.pool.AddRemote(b); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	expect(b, "queued")
+
+	if err := backend.pool.AddRemote(a); err != nil {
+		t.Fatalf("failed to add gap filling transaction: %v", err)
+	}
+	expect(a, "pending")
+	expect(b, "pending")
+
+	// Transactions of other accounts should not be delivered
+	if err := backend.pool.AddRemote(transfer(other, 0, 0)); err != nil {
+		t.Fatalf("failed to add foreign transaction: %v", err)
+	}
+	if err := backend.pool.AddRemote(c); err != nil {
+		t.Fatalf("failed to add executable transaction: %v", err)
+	}
+	expect(c, "pending")
+
+	// Mine a conflicting transaction draining the sender, invalidating the first
+	// two transactions and demoting the third one
+	blocks, _ := core.GenerateChain(params.TestChainConfig, backend.chain.CurrentBlock(), ethash.NewFaker(), backend.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(transfer(key, 0, 500000000))
+	})
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	expect(c, "queued")
+}
+
+// Tests that calls of a bundle omitting the gas allowance and price default to
+// the block gas limit at no cost, instead of being unaffordable for the sender.
+func TestCallBundleDefaults(t *testing.T) {
+	var (
+		sender  = common.Address{0x01}
+		counter = common.Address{0xc1}
+	)
+	backend := newTestBackend(t, params.TestChainConfig, core.GenesisAlloc{
+		sender:  {Balance: big.NewInt(1000)},
+		counter: {Balance: new(big.Int), Code: bundleCounter},
+	}, 1)
+	defer backend.close()
+
+	api := NewPublicBlockChainAPI(backend)
+	calls := []CallArgs{{From: sender, To: &counter}, {From: sender, To: &counter}}
+
+	res, err := api.CallBundle(context.Background(), calls, rpc.LatestBlockNumber, &CallBundleConfig{StateDiff: true})
+	if err != nil {
+		t.Fatalf("failed to execute bundle: %v", err)
+	}
+	for i, result := range res.Results {
+		if have := new(big.Int).SetBytes(result.ReturnData); result.Failed || have.Int64() != int64(i+1) {
+			t.Errorf("call %d: counter mismatch: failed %v (%s), have %v, want %d", i, result.Failed, result.Error, have, i+1)
+		}
+		if result.GasUsed == 0 {
+			t.Errorf("call %d: no gas used", i)
+		}
+	}
+	if diff := res.StateDiff[sender]; diff != nil && diff.Balance != nil {
+		t.Errorf("sender charged for free calls: %v", diff.Balance)
+	}
+	// Omitted allowances should be capped by the overridden block gas limit
+	limit := hexutil.Uint64(params.TxGas)
+	config := &CallBundleConfig{BlockOverrides: &BlockOverrides{GasLimit: &limit}}
+	if res, err = api.CallBundle(context.Background(), calls[:1], rpc.LatestBlockNumber, config); err != nil {
+		t.Fatalf("failed to execute bundle with overrides: %v", err)
+	}
+	if !res.Results[0].Failed {
+		t.Errorf("call within the overridden gas limit succeeded")
+	}
+}
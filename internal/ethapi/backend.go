@@ -23,6 +23,7 @@ import (
 
 	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
@@ -65,9 +66,13 @@ type Backend interface {
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions)
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
+	TxPoolHistory(hash common.Hash) (core.TxStatus, []*core.TxLifecycleEvent)
+	SubscribeTxLifecycleEvent(chan<- core.TxLifecycleEvent) event.Subscription
 
 	ChainConfig() *params.ChainConfig
+	Engine() consensus.Engine
 	CurrentBlock() *types.Block
 }
 
@@ -23,6 +23,7 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/math"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/bloombits"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
@@ -46,6 +47,10 @@ func (b *LesApiBackend) ChainConfig() *params.ChainConfig {
 	return b.lbchain-dev.chainConfig
 }
 
+func (b *LesApiBackend) Engine() consensus.Engine {
+	return b.lbchain-dev.engine
+}
+
 func (b *LesApiBackend) CurrentBlock() *types.Block {
 	return types.NewBlockWithHeader(b.lbchain-dev.BlockChain().CurrentHeader())
 }
@@ -129,14 +134,40 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.lbchain-dev.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.lbchain-dev.txPool.ContentFrom(addr)
+}
+
 func (b *LesApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subscription {
 	return b.lbchain-dev.txPool.SubscribeTxPreEvent(ch)
 }
 
+// TxPoolHistory reports whlbchain-dever the transaction is pending in the light pool. Light
+// clients don't record the lifecycle of their transactions.
+func (b *LesApiBackend) TxPoolHistory(hash common.Hash) (core.TxStatus, []*core.TxLifecycleEvent) {
+	if b.lbchain-dev.txPool.GetTransaction(hash) != nil {
+		return core.TxStatusPending, nil
+	}
+	return core.TxStatusUnknown, nil
+}
+
+// SubscribeTxLifecycleEvent returns a subscription never delivering any events,
+// as light clients don't record the lifecycle of their transactions.
+func (b *LesApiBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.lbchain-dev.blockchain.SubscribeChainEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.lbchain-dev.blockchain.SubscribeChainReorgEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
 	return b.lbchain-dev.blockchain.SubscribeChainHeadEvent(ch)
 }
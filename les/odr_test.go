@@ -135,8 +135,9 @@ func odrContractCall(ctx context.Context, db lbchain-devdb.Database, config *par
 
 				//vmenv := core.NewEnv(statedb, config, bc, msg, header, vm.Config{})
 				gp := new(core.GasPool).AddGas(math.MaxUint64)
-				ret, _, _, _ := core.ApplyMessage(vmenv, msg, gp)
-				res = append(res, ret...)
+				if result, err := core.ApplyMessage(vmenv, msg, gp); err == nil {
+					res = append(res, result.Return()...)
+				}
 			}
 		} else {
 			header := lc.GetHeaderByHash(bhash)
@@ -146,9 +147,9 @@ func odrContractCall(ctx context.Context, db lbchain-devdb.Database, config *par
 			context := core.NewEVMContext(msg, header, lc, nil)
 			vmenv := vm.NewEVM(context, state, config, vm.Config{})
 			gp := new(core.GasPool).AddGas(math.MaxUint64)
-			ret, _, _, _ := core.ApplyMessage(vmenv, msg, gp)
-			if state.Error() == nil {
-				res = append(res, ret...)
+			result, err := core.ApplyMessage(vmenv, msg, gp)
+			if err == nil && state.Error() == nil {
+				res = append(res, result.Return()...)
 			}
 		}
 	}
@@ -180,8 +180,9 @@ func odrContractCall(ctx context.Context, db lbchain-devdb.Database, bc *core.Bl
 		context := core.NewEVMContext(msg, header, chain, nil)
 		vmenv := vm.NewEVM(context, st, config, vm.Config{})
 		gp := new(core.GasPool).AddGas(math.MaxUint64)
-		ret, _, _, _ := core.ApplyMessage(vmenv, msg, gp)
-		res = append(res, ret...)
+		if result, err := core.ApplyMessage(vmenv, msg, gp); err == nil {
+			res = append(res, result.Return()...)
+		}
 		if st.Error() != nil {
 			return res, st.Error()
 		}
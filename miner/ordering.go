@@ -0,0 +1,266 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+)
+
+// Names of the built-in transaction ordering strategies.
+const (
+	OrderingPrice      = "price"      // Highest gas price first
+	OrderingArrival    = "arrival"    // First come, first served by arrival time
+	OrderingRoundRobin = "roundrobin" // One transaction of each sender in turn
+	OrderingPriority   = "priority"   // Transactions to system contracts first, then by gas price
+)
+
+// OrderedTransactions is a set of transactions yielded one by one in the order
+// they should be included into a block.
+type OrderedTransactions interface {
+	// Peek returns the next transaction to include, nil if there are none left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one from the same
+	// sender, if the sender has more.
+	Shift()
+
+	// Pop removes the current transaction, discarding all the remaining ones
+	// from the same sender too.
+	Pop()
+}
+
+// TxOrdering is a strategy deciding the order in which the pending transactions
+// are included into the mined blocks. Whatever the strategy, the transactions of
+// each single sender must be yielded in nonce order.
+type TxOrdering interface {
+	// Order creates the set of transactions to include into a block out of the
+	// pending ones, grouped by sender and sorted by nonce. The input map is
+	// reowned, the caller should not use it any more.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions) OrderedTransactions
+}
+
+// NewTxOrdering creates one of the built-in transaction ordering strategies by
+// name. The system contracts are only used by the priority strategy.
+func NewTxOrdering(name string, contracts []common.Address) (TxOrdering, error) {
+	switch name {
+	case "", OrderingPrice:
+		return PriceOrdering{}, nil
+	case OrderingArrival:
+		return ArrivalOrdering{}, nil
+	case OrderingRoundRobin:
+		return RoundRobinOrdering{}, nil
+	case OrderingPriority:
+		return NewPriorityOrdering(contracts), nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %q", name)
+	}
+}
+
+// PriceOrdering includes the best paying transactions first.
+type PriceOrdering struct{}
+
+// Order implements TxOrdering.
+func (PriceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) OrderedTransactions {
+	return types.NewTransactionsByPriceAndNonce(signer, pending)
+}
+
+// ArrivalOrdering includes the transactions in the order they were first seen,
+// falling back to the gas price for the ones that arrived at the same time.
+type ArrivalOrdering struct{}
+
+// Order implements TxOrdering.
+func (ArrivalOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) OrderedTransactions {
+	return newTransactionsByHeads(signer, pending, func(a, b *types.Transaction) bool {
+		if ta, tb := a.Time(), b.Time(); !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return a.GasPrice().Cmp(b.GasPrice()) > 0
+	})
+}
+
+// PriorityOrdering includes the transactions sent to a set of system contracts
+// first, ordering both the prioritized and the remaining ones by gas price.
+type PriorityOrdering struct {
+	contracts map[common.Address]struct{}
+}
+
+// NewPriorityOrdering creates a priority ordering for the given system contracts.
+func NewPriorityOrdering(contracts []common.Address) PriorityOrdering {
+	ordering := PriorityOrdering{contracts: make(map[common.Address]struct{})}
+	for _, addr := range contracts {
+		ordering.contracts[addr] = struct{}{}
+	}
+	return ordering
+}
+
+// prioritized checks whlbchain-dever a transaction is sent to a system contract.
+func (o PriorityOrdering) prioritized(tx *types.Transaction) bool {
+	if to := tx.To(); to != nil {
+		_, ok := o.contracts[*to]
+		return ok
+	}
+	return false
+}
+
+// Order implements TxOrdering. As the transactions of a sender are yielded in
+// nonce order, a prioritized transaction is only included once all the ones of
+// its sender with lower nonces are.
+func (o PriorityOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) OrderedTransactions {
+	return newTransactionsByHeads(signer, pending, func(a, b *types.Transaction) bool {
+		if pa, pb := o.prioritized(a), o.prioritized(b); pa != pb {
+			return pa
+		}
+		return a.GasPrice().Cmp(b.GasPrice()) > 0
+	})
+}
+
+// RoundRobinOrdering includes a single transaction of each sender in turn, so
+// that no sender can crowd out the others. The turns within a round are ordered
+// by the gas price of the first transaction of each sender.
+type RoundRobinOrdering struct{}
+
+// Order implements TxOrdering.
+func (RoundRobinOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) OrderedTransactions {
+	senders := make([]common.Address, 0, len(pending))
+	for addr, txs := range pending {
+		if len(txs) > 0 {
+			senders = append(senders, addr)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		if cmp := pending[senders[i]][0].GasPrice().Cmp(pending[senders[j]][0].GasPrice()); cmp != 0 {
+			return cmp > 0
+		}
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	return &transactionsByRound{txs: pending, senders: senders}
+}
+
+// transactionsByRound is a nonce-honouring set of transactions, yielding one
+// transaction of each sender in turn.
+type transactionsByRound struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	senders []common.Address                      // Accounts with transactions left, the one in turn first
+}
+
+// Peek returns the next transaction of the sender in turn.
+func (t *transactionsByRound) Peek() *types.Transaction {
+	if len(t.senders) == 0 {
+		return nil
+	}
+	return t.txs[t.senders[0]][0]
+}
+
+// Shift replaces the current transaction with the next one from the same sender
+// and passes the turn to the next sender.
+func (t *transactionsByRound) Shift() {
+	acc := t.senders[0]
+	t.txs[acc], t.senders = t.txs[acc][1:], t.senders[1:]
+	if len(t.txs[acc]) > 0 {
+		t.senders = append(t.senders, acc)
+	}
+}
+
+// Pop removes the current transaction along with the remaining ones from the
+// same sender, passing the turn to the next sender.
+func (t *transactionsByRound) Pop() {
+	delete(t.txs, t.senders[0])
+	t.senders = t.senders[1:]
+}
+
+// txHeads is a heap of the next transactions of each sender, ordered by the
+// comparison of a strategy.
+type txHeads struct {
+	txs  []*types.Transaction
+	less func(a, b *types.Transaction) bool
+}
+
+func (h *txHeads) Len() int           { return len(h.txs) }
+func (h *txHeads) Less(i, j int) bool { return h.less(h.txs[i], h.txs[j]) }
+func (h *txHeads) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeads) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*types.Transaction))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
+// transactionsByHeads is a nonce-honouring set of transactions, yielding the
+// best next transaction of all the senders according to a comparison of them.
+type transactionsByHeads struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *txHeads                              // Next transaction for each unique account
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newTransactionsByHeads creates a transaction set yielding the transactions of
+// all the senders in the order defined by the given comparison.
+func newTransactionsByHeads(signer types.Signer, txs map[common.Address]types.Transactions, less func(a, b *types.Transaction) bool) *transactionsByHeads {
+	heads := &txHeads{txs: make([]*types.Transaction, 0, len(txs)), less: less}
+	for acc, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, acc)
+			continue
+		}
+		heads.txs = append(heads.txs, accTxs[0])
+		txs[acc] = accTxs[1:]
+	}
+	heap.Init(heads)
+
+	return &transactionsByHeads{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+// Peek returns the next best transaction.
+func (t *transactionsByHeads) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.txs[0]
+}
+
+// Shift replaces the current best head with the next one from the same account.
+func (t *transactionsByHeads) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads.txs[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
+	} else {
+		heap.Pop(t.heads)
+	}
+}
+
+// Pop removes the best transaction, *not* replacing it with the next one from
+// the same account.
+func (t *transactionsByHeads) Pop() {
+	heap.Pop(t.heads)
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+)
+
+var (
+	orderingSigner   = types.HomesteadSigner{}
+	orderingContract = common.HexToAddress("0x000000000000000000000000000000000000c0de")
+)
+
+// orderingTx creates a signed transaction for the ordering tests.
+func orderingTx(key *ecdsa.PrivateKey, nonce uint64, to common.Address, price int64) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(100), 100, big.NewInt(price), nil), orderingSigner, key)
+	return tx
+}
+
+// drainOrdered retrieves all the transactions of an ordered set.
+func drainOrdered(txset OrderedTransactions) types.Transactions {
+	var txs types.Transactions
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		txs = append(txs, tx)
+		txset.Shift()
+	}
+	return txs
+}
+
+// Tests that all the built-in ordering strategies include every transaction once
+// while preserving the nonce ordering of each sender, and that popping a
+// transaction discards the remaining ones of its sender.
+func TestOrderingNonceOrder(t *testing.T) {
+	for _, name := range []string{OrderingPrice, OrderingArrival, OrderingRoundRobin, OrderingPriority} {
+		testOrderingNonceOrder(t, name)
+	}
+}
+
+func testOrderingNonceOrder(t *testing.T, name string) {
+	ordering, err := NewTxOrdering(name, []common.Address{orderingContract})
+	if err != nil {
+		t.Fatalf("%s: failed to create ordering: %v", name, err)
+	}
+	// Generate a batch of accounts with random prices, start nonces and recipients
+	var (
+		groups = make(map[common.Address]types.Transactions)
+		starts = make(map[common.Address]uint64)
+		keys   = make([]*ecdsa.PrivateKey, 25)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+		starts[addr] = uint64(rand.Intn(100))
+	}
+	for j := 0; j < 25; j++ {
+		for _, key := range keys {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+
+			to := common.Address{}
+			if rand.Intn(4) == 0 {
+				to = orderingContract
+			}
+			groups[addr] = append(groups[addr], orderingTx(key, starts[addr]+uint64(j), to, int64(rand.Intn(50))))
+		}
+	}
+	copied := make(map[common.Address]types.Transactions)
+	for addr, txs := range groups {
+		copied[addr] = append(types.Transactions(nil), txs...)
+	}
+	// Ensure all the transactions are retrieved in nonce order per sender
+	txs := drainOrdered(ordering.Order(orderingSigner, groups))
+	if len(txs) != len(keys)*25 {
+		t.Fatalf("%s: transaction count mismatch: have %d, want %d", name, len(txs), len(keys)*25)
+	}
+	next := make(map[common.Address]uint64)
+	for k, v := range starts {
+		next[k] = v
+	}
+	for i, tx := range txs {
+		from, _ := types.Sender(orderingSigner, tx)
+		if tx.Nonce() != next[from] {
+			t.Errorf("%s: transaction %d: nonce mismatch for %x: have %d, want %d", name, i, from[:4], tx.Nonce(), next[from])
+		}
+		next[from] = tx.Nonce() + 1
+	}
+	// Pop the first transaction and ensure nothing else from its sender is yielded
+	txset := ordering.Order(orderingSigner, copied)
+	popped, _ := types.Sender(orderingSigner, txset.Peek())
+	txset.Pop()
+
+	txs = drainOrdered(txset)
+	if len(txs) != (len(keys)-1)*25 {
+		t.Fatalf("%s: transaction count mismatch after pop: have %d, want %d", name, len(txs), (len(keys)-1)*25)
+	}
+	for i, tx := range txs {
+		if from, _ := types.Sender(orderingSigner, tx); from == popped {
+			t.Errorf("%s: transaction %d: yielded from popped sender %x", name, i, from[:4])
+		}
+	}
+}
+
+// Tests that the arrival ordering yields the transactions first come first served,
+// preferring the better paying ones out of those that arrived at the same time.
+func TestOrderingArrival(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Create the transactions of each sender with explicit arrival times (in
+	// seconds), interleaving the senders in a non trivial pattern. The better
+	// paying first sender and the second one tie at the sixth second.
+	arrivals := [][]int{{1, 2, 6}, {3, 6, 7}, {0, 4, 8, 9}}
+
+	var (
+		base   = time.Unix(1000000, 0)
+		groups = make(map[common.Address]types.Transactions)
+	)
+	for i, times := range arrivals {
+		addr := crypto.PubkeyToAddress(keys[i].PublicKey)
+		for nonce, arrival := range times {
+			tx := orderingTx(keys[i], uint64(nonce), common.Address{}, int64(10-i))
+			tx.SetTime(base.Add(time.Duration(arrival) * time.Second))
+			groups[addr] = append(groups[addr], tx)
+		}
+	}
+	want := []struct {
+		sender int
+		nonce  uint64
+	}{
+		{2, 0}, {0, 0}, {0, 1}, {1, 0}, {2, 1}, {0, 2}, {1, 1}, {1, 2}, {2, 2}, {2, 3},
+	}
+	txs := drainOrdered(ArrivalOrdering{}.Order(orderingSigner, groups))
+	if len(txs) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(txs), len(want))
+	}
+	for i, tx := range txs {
+		from, _ := types.Sender(orderingSigner, tx)
+		if sender := crypto.PubkeyToAddress(keys[want[i].sender].PublicKey); from != sender || tx.Nonce() != want[i].nonce {
+			t.Errorf("transaction %d: arrival order mismatch: have %x/%d, want %x/%d", i, from[:4], tx.Nonce(), sender[:4], want[i].nonce)
+		}
+	}
+}
+
+// Tests that the round robin ordering yields a single transaction of each
+// sender in turn, starting each round with the best paying sender.
+func TestOrderingRoundRobin(t *testing.T) {
+	a, _ := crypto.GenerateKey()
+	b, _ := crypto.GenerateKey()
+	c, _ := crypto.GenerateKey()
+
+	groups := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(a.PublicKey): {orderingTx(a, 0, common.Address{}, 1), orderingTx(a, 1, common.Address{}, 1), orderingTx(a, 2, common.Address{}, 1)},
+		crypto.PubkeyToAddress(b.PublicKey): {orderingTx(b, 0, common.Address{}, 3), orderingTx(b, 1, common.Address{}, 3)},
+		crypto.PubkeyToAddress(c.PublicKey): {orderingTx(c, 0, common.Address{}, 2)},
+	}
+	want := []*ecdsa.PrivateKey{b, c, a, b, a, a}
+
+	txs := drainOrdered(RoundRobinOrdering{}.Order(orderingSigner, groups))
+	if len(txs) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(txs), len(want))
+	}
+	for i, tx := range txs {
+		if from, _ := types.Sender(orderingSigner, tx); from != crypto.PubkeyToAddress(want[i].PublicKey) {
+			t.Errorf("transaction %d: sender mismatch: have %x, want %x", i, from, crypto.PubkeyToAddress(want[i].PublicKey))
+		}
+	}
+}
+
+// Tests that the priority ordering yields the transactions sent to the system
+// contracts first, unless preceded by other transactions of the same sender.
+func TestOrderingPriority(t *testing.T) {
+	a, _ := crypto.GenerateKey()
+	b, _ := crypto.GenerateKey()
+	c, _ := crypto.GenerateKey()
+
+	groups := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(a.PublicKey): {orderingTx(a, 0, orderingContract, 1), orderingTx(a, 1, orderingContract, 1)},
+		crypto.PubkeyToAddress(b.PublicKey): {orderingTx(b, 0, common.Address{}, 10), orderingTx(b, 1, common.Address{}, 10)},
+		crypto.PubkeyToAddress(c.PublicKey): {orderingTx(c, 0, common.Address{}, 5), orderingTx(c, 1, orderingContract, 1)},
+	}
+	want := []*ecdsa.PrivateKey{a, a, b, b, c, c}
+
+	txs := drainOrdered(NewPriorityOrdering([]common.Address{orderingContract}).Order(orderingSigner, groups))
+	if len(txs) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(txs), len(want))
+	}
+	for i, tx := range txs {
+		if from, _ := types.Sender(orderingSigner, tx); from != crypto.PubkeyToAddress(want[i].PublicKey) {
+			t.Errorf("transaction %d: sender mismatch: have %x, want %x", i, from, crypto.PubkeyToAddress(want[i].PublicKey))
+		}
+	}
+}
+
+// Tests that unknown ordering strategies are rejected.
+func TestOrderingUnknown(t *testing.T) {
+	if _, err := NewTxOrdering("random", nil); err == nil {
+		t.Fatalf("unknown ordering accepted")
+	}
+}
@@ -49,6 +49,26 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "service_returnError")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// Check code and data are returned
+	if e, ok := err.(Error); !ok || e.ErrorCode() != 444 {
+		t.Errorf("wrong error code: %v", err)
+	}
+	if e, ok := err.(DataError); !ok || e.ErrorData() != "testData" {
+		t.Errorf("wrong error data: %v", err)
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
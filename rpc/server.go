@@ -316,8 +316,16 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+
+			// Callback errors may carry their own error code and data
+			var err Error = &callbackError{e.Error()}
+			if ec, ok := e.(Error); ok {
+				err = ec
+			}
+			if de, ok := e.(DataError); ok {
+				return codec.CreateErrorResponseWithInfo(&req.id, err, de.ErrorData()), nil
+			}
+			return codec.CreateErrorResponse(&req.id, err), nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/math"
 )
 
@@ -64,3 +65,50 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	hash := common.HexToHash("0x1a2b3c")
+
+	tests := []struct {
+		input    string
+		mustFail bool
+		number   *BlockNumber
+		hash     *common.Hash
+	}{
+		0:  {`"0x12"`, false, newBlockNumber(18), nil},
+		1:  {`"latest"`, false, newBlockNumber(LatestBlockNumber), nil},
+		2:  {`"pending"`, false, newBlockNumber(PendingBlockNumber), nil},
+		3:  {`"` + hash.Hex() + `"`, false, nil, &hash},
+		4:  {`{"blockNumber":"earliest"}`, false, newBlockNumber(EarliestBlockNumber), nil},
+		5:  {`{"blockHash":"` + hash.Hex() + `"}`, false, nil, &hash},
+		6:  {`{"blockNumber":"0x1","blockHash":"` + hash.Hex() + `"}`, true, nil, nil},
+		7:  {`{}`, true, nil, nil},
+		8:  {`"0x` + hash.Hex()[3:] + `"`, true, nil, nil},
+		9:  {`"ff"`, true, nil, nil},
+		10: {`1`, true, nil, nil},
+	}
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail {
+			if err == nil {
+				t.Errorf("Test %d should fail", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if number, ok := bnh.Number(); ok != (test.number != nil) || (ok && number != *test.number) {
+			t.Errorf("Test %d got unexpected number: %v", i, bnh.BlockNumber)
+		}
+		if hash, ok := bnh.Hash(); ok != (test.hash != nil) || (ok && hash != *test.hash) {
+			t.Errorf("Test %d got unexpected hash: %v", i, bnh.BlockHash)
+		}
+	}
+}
+
+func newBlockNumber(number BlockNumber) *BlockNumber {
+	return &number
+}
@@ -17,12 +17,22 @@
 package trie
 
 import (
+	"container/list"
 	"sync"
 	"time"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/log"
+	"github.com/lbchain-devchain/go-lbchain-dev/metrics"
+)
+
+var (
+	memcacheCleanHitMeter   = metrics.NewRegisteredMeter("trie/memcache/clean/hit", nil)
+	memcacheCleanMissMeter  = metrics.NewRegisteredMeter("trie/memcache/clean/miss", nil)
+	memcacheCleanReadMeter  = metrics.NewRegisteredMeter("trie/memcache/clean/read", nil)
+	memcacheCleanWriteMeter = metrics.NewRegisteredMeter("trie/memcache/clean/write", nil)
+	memcacheCleanEvictMeter = metrics.NewRegisteredMeter("trie/memcache/clean/evict", nil)
 )
 
 // secureKeyPrefix is the database key prefix used to store trie node preimages.
@@ -46,6 +56,7 @@ type DatabaseReader interface {
 type Database struct {
 	diskdb lbchain-devdb.Database // Persistent storage for matured trie nodes
 
+	cleans    *cleanCache                 // Size bounded cache of clean (persisted) nodes, nil if disabled
 	nodes     map[common.Hash]*cachedNode // Data and references relationships of a node
 	preimages map[common.Hash][]byte      // Preimages of nodes from the secure trie
 	seckeybuf [secureKeyLength]byte       // Ephemeral buffer for calculating preimage keys
@@ -68,11 +79,84 @@ type cachedNode struct {
 	children map[common.Hash]int // Children referenced by this nodes
 }
 
+// cleanCache is a size bounded LRU cache of trie nodes already persisted to disk,
+// keeping recently read and recently committed nodes in memory.
+type cleanCache struct {
+	limit common.StorageSize // Maximum storage size of the cached nodes
+	size  common.StorageSize // Current storage size of the cached nodes
+
+	items map[common.Hash]*list.Element // Cached nodes by hash, pointing into the LRU list
+	order *list.List                    // Cached nodes in recency order, most recent in front
+
+	lock sync.Mutex
+}
+
+// cleanEntry is a single node in the clean cache.
+type cleanEntry struct {
+	hash common.Hash
+	blob []byte
+}
+
+// newCleanCache creates a clean node cache with the given memory allowance (MB).
+func newCleanCache(limit int) *cleanCache {
+	return &cleanCache{
+		limit: common.StorageSize(limit * 1024 * 1024),
+		items: make(map[common.Hash]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get retrieves a node from the cache, marking it as recently used.
+func (c *cleanCache) get(hash common.Hash) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[hash]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cleanEntry).blob, true
+	}
+	return nil, false
+}
+
+// add inserts a node into the cache, evicting the least recently used nodes if
+// the size allowance is exceeded.
+func (c *cleanCache) add(hash common.Hash, blob []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[hash] = c.order.PushFront(&cleanEntry{hash: hash, blob: blob})
+	c.size += common.StorageSize(common.HashLength + len(blob))
+
+	for c.size > c.limit {
+		entry := c.order.Remove(c.order.Back()).(*cleanEntry)
+		delete(c.items, entry.hash)
+		c.size -= common.StorageSize(common.HashLength + len(entry.blob))
+		memcacheCleanEvictMeter.Mark(1)
+	}
+}
+
 // NewDatabase creates a new trie database to store ephemeral trie content before
-// its written out to disk or garbage collected.
+// its written out to disk or garbage collected. No read cache is created, so all
+// data retrievals will hit the underlying disk database.
 func NewDatabase(diskdb lbchain-devdb.Database) *Database {
+	return NewDatabaseWithCache(diskdb, 0)
+}
+
+// NewDatabaseWithCache creates a new trie database to store ephemeral trie content
+// before its written out to disk or garbage collected. It also acts as a read cache
+// for nodes loaded from disk, with the given memory allowance (MB).
+func NewDatabaseWithCache(diskdb lbchain-devdb.Database, cache int) *Database {
+	var cleans *cleanCache
+	if cache > 0 {
+		cleans = newCleanCache(cache)
+	}
 	return &Database{
 		diskdb: diskdb,
+		cleans: cleans,
 		nodes: map[common.Hash]*cachedNode{
 			{}: {children: make(map[common.Hash]int)},
 		},
@@ -121,7 +205,7 @@ func (db *Database) insertPreimage(hash common.Hash, preimage []byte) {
 // Node retrieves a cached trie node from memory. If it cannot be found cached,
 // the method queries the persistent database for the content.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
-	// Retrieve the node from cache if available
+	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
 	node := db.nodes[hash]
 	db.lock.RUnlock()
@@ -129,8 +213,22 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	if node != nil {
 		return node.blob, nil
 	}
+	// Retrieve the node from the clean cache if available
+	if db.cleans != nil {
+		if enc, ok := db.cleans.get(hash); ok {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc)))
+			return enc, nil
+		}
+	}
 	// Content unavailable in memory, attempt to retrieve from disk
-	return db.diskdb.Get(hash[:])
+	enc, err := db.diskdb.Get(hash[:])
+	if err == nil && enc != nil && db.cleans != nil {
+		db.cleans.add(hash, enc)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(enc)))
+	}
+	return enc, err
 }
 
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
@@ -249,26 +347,22 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	db.lock.RLock()
 
 	start := time.Now()
-	batch := db.diskdb.NewBatch()
+	batch := newCommitBatch(db.diskdb)
 
 	// Move all of the accumulated preimages into a write batch
 	for hash, preimage := range db.preimages {
 		if err := batch.Put(db.secureKey(hash[:]), preimage); err != nil {
 			log.Error("Failed to commit preimage from trie database", "err", err)
+			batch.Write()
 			db.lock.RUnlock()
 			return err
 		}
-		if batch.ValueSize() > lbchain-devdb.IdealBatchSize {
-			if err := batch.Write(); err != nil {
-				return err
-			}
-			batch.Reset()
-		}
 	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.nodes), db.nodesSize+db.preimagesSize
 	if err := db.commit(node, batch); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
+		batch.Write()
 		db.lock.RUnlock()
 		return err
 	}
@@ -303,7 +397,7 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 }
 
 // commit is the private locked version of Commit.
-func (db *Database) commit(hash common.Hash, batch lbchain-devdb.Batch) error {
+func (db *Database) commit(hash common.Hash, batch *commitBatch) error {
 	// If the node does not exist, it's a previously committed node
 	node, ok := db.nodes[hash]
 	if !ok {
@@ -314,19 +408,64 @@ func (db *Database) commit(hash common.Hash, batch lbchain-devdb.Batch) error {
 			return err
 		}
 	}
-	if err := batch.Put(hash[:], node.blob); err != nil {
-		return err
+	return batch.Put(hash[:], node.blob)
+}
+
+// commitBatch gathers the data of a commit into database batches. Once a batch
+// reaches the ideal size, it is written to disk on a background thread while
+// the next one is being gathered, overlapping the trie traversal with the disk
+// writes.
+type commitBatch struct {
+	diskdb  lbchain-devdb.Database
+	batch   lbchain-devdb.Batch
+	pending chan lbchain-devdb.Batch // Full batches waiting to be written
+	done    chan error       // Result of the background writes
+}
+
+// newCommitBatch creates a commit batch and starts its background writer.
+func newCommitBatch(diskdb lbchain-devdb.Database) *commitBatch {
+	b := &commitBatch{
+		diskdb:  diskdb,
+		batch:   diskdb.NewBatch(),
+		pending: make(chan lbchain-devdb.Batch, 1),
+		done:    make(chan error, 1),
 	}
-	// If we've reached an optimal match size, commit and start over
-	if batch.ValueSize() >= lbchain-devdb.IdealBatchSize {
-		if err := batch.Write(); err != nil {
-			return err
+	go b.loop()
+	return b
+}
+
+// loop writes the full batches to disk, stopping at the first failure.
+func (b *commitBatch) loop() {
+	var err error
+	for batch := range b.pending {
+		if err == nil {
+			err = batch.Write()
 		}
-		batch.Reset()
+	}
+	b.done <- err
+}
+
+// Put inserts a key-value pair into the current batch, handing it over to the
+// background writer if it has reached the ideal size.
+func (b *commitBatch) Put(key, value []byte) error {
+	if err := b.batch.Put(key, value); err != nil {
+		return err
+	}
+	if b.batch.ValueSize() >= lbchain-devdb.IdealBatchSize {
+		b.pending <- b.batch
+		b.batch = b.diskdb.NewBatch()
 	}
 	return nil
 }
 
+// Write flushes the last batch and waits for all the writes to finish. The
+// batch cannot be used afterwards.
+func (b *commitBatch) Write() error {
+	b.pending <- b.batch
+	close(b.pending)
+	return <-b.done
+}
+
 // uncache is the post-processing step of a commit operation where the already
 // persisted trie is removed from the cache. The reason behind the two-phase
 // commit is to ensure consistent data availability while moving from memory
@@ -343,6 +482,12 @@ func (db *Database) uncache(hash common.Hash) {
 	}
 	delete(db.nodes, hash)
 	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+
+	// Move the flushed node into the clean cache to avoid reading it back
+	if db.cleans != nil {
+		db.cleans.add(hash, node.blob)
+		memcacheCleanWriteMeter.Mark(int64(len(node.blob)))
+	}
 }
 
 // Size returns the current storage size of the memory cache in front of the
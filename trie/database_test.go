@@ -0,0 +1,86 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+)
+
+// Tests that nodes flushed to disk are retained in the clean cache and served
+// from memory even after being deleted from the persistent database.
+func TestCleanCacheRetention(t *testing.T) {
+	diskdb, _ := lbchain-devdb.NewMemDatabase()
+	triedb := NewDatabaseWithCache(diskdb, 16)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := byte(0); i < 100; i++ {
+		trie.Update([]byte{i, i}, bytes.Repeat([]byte{i}, 40))
+	}
+	root, _ := trie.Commit(nil)
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush trie: %v", err)
+	}
+	// Wipe the disk and ensure all nodes are still reachable through the cache
+	for _, key := range diskdb.Keys() {
+		diskdb.Delete(key)
+	}
+	trie, err := New(root, triedb)
+	if err != nil {
+		t.Fatalf("failed to reopen trie: %v", err)
+	}
+	for i := byte(0); i < 100; i++ {
+		if val, err := trie.TryGet([]byte{i, i}); err != nil || !bytes.Equal(val, bytes.Repeat([]byte{i}, 40)) {
+			t.Fatalf("item %d: value mismatch: have %x, err %v", i, val, err)
+		}
+	}
+	// A database without a clean cache must fail the same lookups
+	if _, err := New(root, NewDatabase(diskdb)); err == nil {
+		t.Fatalf("trie opened without clean cache and without disk data")
+	}
+}
+
+// Tests that the clean cache respects its memory allowance, evicting the least
+// recently used nodes first.
+func TestCleanCacheEviction(t *testing.T) {
+	cache := newCleanCache(1)
+	blob := make([]byte, 1024-common.HashLength)
+
+	for i := 0; i < 2048; i++ {
+		cache.add(common.Hash{byte(i >> 8), byte(i)}, blob)
+
+		// Keep the very first item hot so it's never evicted
+		if _, ok := cache.get(common.Hash{}); !ok {
+			t.Fatalf("item %d: hot item evicted", i)
+		}
+	}
+	if cache.size > cache.limit {
+		t.Errorf("cache size above limit: have %v, limit %v", cache.size, cache.limit)
+	}
+	if have, want := cache.order.Len(), 1024; have != want {
+		t.Errorf("cached item count mismatch: have %d, want %d", have, want)
+	}
+	if _, ok := cache.get(common.Hash{0x00, 0x01}); ok {
+		t.Errorf("cold item retained")
+	}
+	if _, ok := cache.get(common.Hash{0x07, 0xff}); !ok {
+		t.Errorf("recent item evicted")
+	}
+}
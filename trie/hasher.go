@@ -26,12 +26,27 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
 )
 
+// parallelHashThreshold is the number of nodes to hash or commit after which the
+// children of the top level full node are hashed concurrently.
+var parallelHashThreshold = 100
+
 type hasher struct {
 	tmp        *bytes.Buffer
 	sha        hash.Hash
 	cachegen   uint16
 	cachelimit uint16
 	onleaf     LeafCallback
+	parallel   bool // Whlbchain-dever to hash the children of the next full node concurrently
+
+	pending []pendingNode // Collapsed nodes waiting to be flushed into the database
+}
+
+// pendingNode is a collapsed trie node that has been hashed during a commit, but
+// not yet inserted into the trie database.
+type pendingNode struct {
+	hash common.Hash
+	node node
+	blob []byte
 }
 
 // hashers live in a global db.
@@ -41,13 +56,14 @@ var hasherPool = sync.Pool{
 	},
 }
 
-func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback) *hasher {
+func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback, parallel bool) *hasher {
 	h := hasherPool.Get().(*hasher)
-	h.cachegen, h.cachelimit, h.onleaf = cachegen, cachelimit, onleaf
+	h.cachegen, h.cachelimit, h.onleaf, h.parallel = cachegen, cachelimit, onleaf, parallel
 	return h
 }
 
 func returnHasherToPool(h *hasher) {
+	h.onleaf, h.pending = nil, nil
 	hasherPool.Put(h)
 }
 
@@ -125,6 +141,12 @@ func (h *hasher) hashChildren(original node, db *Database) (node, node, error) {
 		// Hash the full node's children, caching the newly hashed subtrees
 		collapsed, cached := n.copy(), n.copy()
 
+		if h.parallel {
+			if err := h.hashChildrenParallel(n, collapsed, cached, db); err != nil {
+				return original, original, err
+			}
+			return collapsed, cached, nil
+		}
 		for i := 0; i < 16; i++ {
 			if n.Children[i] != nil {
 				collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
@@ -147,6 +169,56 @@ func (h *hasher) hashChildren(original node, db *Database) (node, node, error) {
 	}
 }
 
+// hashChildrenParallel hashes the children of a full node concurrently, each on
+// its own hasher, filling in the collapsed and cached copies of the node. Only
+// the topmost full node is split up, all the subtries are hashed sequentially.
+func (h *hasher) hashChildrenParallel(n, collapsed, cached *fullNode, db *Database) error {
+	// The leaf callback isn't required to be thread safe, serialize it
+	onleaf := h.onleaf
+	if onleaf != nil {
+		var lock sync.Mutex
+		onleaf = func(leaf []byte, parent common.Hash) error {
+			lock.Lock()
+			defer lock.Unlock()
+			return h.onleaf(leaf, parent)
+		}
+	}
+	var (
+		errs [16]error
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 16; i++ {
+		if n.Children[i] == nil {
+			collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			child := newHasher(h.cachegen, h.cachelimit, onleaf, false)
+			defer returnHasherToPool(child)
+
+			collapsed.Children[i], cached.Children[i], errs[i] = child.hash(n.Children[i], db, false)
+			if errs[i] == nil && db != nil {
+				child.flush(db)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	cached.Children[16] = n.Children[16]
+	if collapsed.Children[16] == nil {
+		collapsed.Children[16] = valueNode(nil)
+	}
+	return nil
+}
+
 // store hashes the node n and if we have a storage layer specified, it writes
 // the key/value pair to it and tracks any node->child references as well as any
 // node->external trie references.
@@ -171,42 +243,59 @@ func (h *hasher) store(n node, db *Database, force bool) (node, error) {
 		hash = hashNode(h.sha.Sum(nil))
 	}
 	if db != nil {
-		// We are pooling the trie nodes into an intermediate memory cache
-		db.lock.Lock()
+		// Queue the node up for a batched insertion into the memory cache
+		h.pending = append(h.pending, pendingNode{
+			hash: common.BytesToHash(hash),
+			node: n,
+			blob: common.CopyBytes(h.tmp.Bytes()),
+		})
+	}
+	return hash, nil
+}
 
-		hash := common.BytesToHash(hash)
-		db.insert(hash, h.tmp.Bytes())
+// flush inserts all the pending nodes into the intermediate memory cache under
+// a single lock, tracking their internal and external references. Children are
+// always stored before their parents, so they are inserted in the same order.
+func (h *hasher) flush(db *Database) {
+	if len(h.pending) == 0 {
+		return
+	}
+	db.lock.Lock()
+	for _, p := range h.pending {
+		db.insert(p.hash, p.blob)
 
 		// Track all direct parent->child node references
-		switch n := n.(type) {
+		switch n := p.node.(type) {
 		case *shortNode:
 			if child, ok := n.Val.(hashNode); ok {
-				db.reference(common.BytesToHash(child), hash)
+				db.reference(common.BytesToHash(child), p.hash)
 			}
 		case *fullNode:
 			for i := 0; i < 16; i++ {
 				if child, ok := n.Children[i].(hashNode); ok {
-					db.reference(common.BytesToHash(child), hash)
+					db.reference(common.BytesToHash(child), p.hash)
 				}
 			}
 		}
-		db.lock.Unlock()
+	}
+	db.lock.Unlock()
 
-		// Track external references from account->storage trie
-		if h.onleaf != nil {
-			switch n := n.(type) {
+	// Track external references from account->storage trie
+	if h.onleaf != nil {
+		for _, p := range h.pending {
+			switch n := p.node.(type) {
 			case *shortNode:
 				if child, ok := n.Val.(valueNode); ok {
-					h.onleaf(child, hash)
+					h.onleaf(child, p.hash)
 				}
 			case *fullNode:
 				for i := 0; i < 16; i++ {
 					if child, ok := n.Children[i].(valueNode); ok {
-						h.onleaf(child, hash)
+						h.onleaf(child, p.hash)
 					}
 				}
 			}
 		}
 	}
-	return hash, nil
+	h.pending = h.pending[:0]
 }
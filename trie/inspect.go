@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+)
+
+// Stats is a summary of the node layout of one or more tries.
+type Stats struct {
+	Depths []DepthStats       // Node counts by depth, the root being at depth zero
+	Leaves uint64             // Number of values stored in the trie
+	Nodes  uint64             // Number of nodes stored in the database by hash
+	Size   common.StorageSize // Total size of the nodes stored in the database
+}
+
+// DepthStats counts the nodes at a single depth of a trie by their type.
+type DepthStats struct {
+	Full      uint64 // Branch nodes with up to sixteen children
+	Extension uint64 // Short nodes pointing to a child node
+	Leaf      uint64 // Short nodes holding a value
+}
+
+// Inspect walks all the nodes of the trie with the given root, counting them by
+// depth and type. If onleaf is non-nil, it is invoked with every value stored in
+// the trie, which can be used to descend into tries referenced from the leaves.
+func Inspect(root common.Hash, db *Database, onleaf func(value []byte) error) (*Stats, error) {
+	stats := new(Stats)
+	if root == emptyRoot || root == (common.Hash{}) {
+		return stats, nil
+	}
+	if err := stats.walk(hashNode(root.Bytes()), 0, nil, db, onleaf); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Add merges the statistics of another trie into this one.
+func (s *Stats) Add(other *Stats) {
+	for depth, stat := range other.Depths {
+		s.grow(depth)
+		s.Depths[depth].Full += stat.Full
+		s.Depths[depth].Extension += stat.Extension
+		s.Depths[depth].Leaf += stat.Leaf
+	}
+	s.Leaves += other.Leaves
+	s.Nodes += other.Nodes
+	s.Size += other.Size
+}
+
+// grow extends the depth statistics to contain the given depth.
+func (s *Stats) grow(depth int) {
+	for len(s.Depths) <= depth {
+		s.Depths = append(s.Depths, DepthStats{})
+	}
+}
+
+// walk recursively counts a node and all its descendants, resolving the hashed
+// nodes from the database.
+func (s *Stats) walk(n node, depth int, path []byte, db *Database, onleaf func(value []byte) error) error {
+	if hash, ok := n.(hashNode); ok {
+		blob, err := db.Node(common.BytesToHash(hash))
+		if err != nil || blob == nil {
+			return &MissingNodeError{NodeHash: common.BytesToHash(hash), Path: path}
+		}
+		if n, err = decodeNode(hash, blob, 0); err != nil {
+			return fmt.Errorf("node %x: %v", hash, err)
+		}
+		s.Nodes++
+		s.Size += common.StorageSize(common.HashLength + len(blob))
+	}
+	s.grow(depth)
+
+	switch n := n.(type) {
+	case *shortNode:
+		if value, ok := n.Val.(valueNode); ok {
+			s.Depths[depth].Leaf++
+			return s.leaf(value, onleaf)
+		}
+		s.Depths[depth].Extension++
+		return s.walk(n.Val, depth+1, append(path, n.Key...), db, onleaf)
+
+	case *fullNode:
+		s.Depths[depth].Full++
+		for i, child := range &n.Children {
+			if child == nil {
+				continue
+			}
+			if value, ok := child.(valueNode); ok {
+				if err := s.leaf(value, onleaf); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.walk(child, depth+1, append(path, byte(i)), db, onleaf); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected node type %T at path %x", n, path)
+	}
+}
+
+// leaf counts a value stored in the trie and passes it to the leaf callback.
+func (s *Stats) leaf(value valueNode, onleaf func(value []byte) error) error {
+	s.Leaves++
+	if onleaf != nil {
+		return onleaf(value)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+)
+
+// Tests that trie inspection counts every leaf and every node stored in the
+// database, and reports missing nodes.
+func TestInspect(t *testing.T) {
+	diskdb, _ := lbchain-devdb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := 0; i < 1000; i++ {
+		key := crypto.Keccak256([]byte{byte(i), byte(i >> 8)})
+		trie.Update(key, key)
+	}
+	root, _ := trie.Commit(nil)
+	triedb.Commit(root, false)
+
+	var leaves int
+	stats, err := Inspect(root, triedb, func(value []byte) error {
+		leaves++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to inspect trie: %v", err)
+	}
+	if stats.Leaves != 1000 || leaves != 1000 {
+		t.Errorf("leaf count mismatch: have %d (callbacks %d), want %d", stats.Leaves, leaves, 1000)
+	}
+	if have, want := stats.Nodes, uint64(len(diskdb.Keys())); have != want {
+		t.Errorf("stored node count mismatch: have %d, want %d", have, want)
+	}
+	var size common.StorageSize
+	for _, key := range diskdb.Keys() {
+		blob, _ := diskdb.Get(key)
+		size += common.StorageSize(len(key) + len(blob))
+	}
+	if stats.Size != size {
+		t.Errorf("size mismatch: have %v, want %v", stats.Size, size)
+	}
+	var counted uint64
+	for _, depth := range stats.Depths {
+		counted += depth.Leaf
+	}
+	if counted != stats.Leaves {
+		t.Errorf("leaf node count mismatch: have %d, want %d", counted, stats.Leaves)
+	}
+	if stats.Depths[0].Full != 1 {
+		t.Errorf("root type mismatch: have %+v, want full node", stats.Depths[0])
+	}
+	// Merging the stats should double everything
+	merged := new(Stats)
+	merged.Add(stats)
+	merged.Add(stats)
+	if merged.Leaves != 2*stats.Leaves || merged.Size != 2*stats.Size || merged.Depths[1].Full != 2*stats.Depths[1].Full {
+		t.Errorf("merged stats mismatch: have %+v", merged)
+	}
+	// Deleting a node from the database should be reported
+	diskdb.Delete(diskdb.Keys()[0])
+	if _, err := Inspect(root, NewDatabase(diskdb), nil); err == nil {
+		t.Errorf("missing node not reported")
+	} else if _, ok := err.(*MissingNodeError); !ok {
+		t.Errorf("error type mismatch: have %T, want *MissingNodeError", err)
+	}
+}
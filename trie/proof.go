@@ -18,6 +18,7 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
@@ -65,7 +66,7 @@ func (t *Trie) Prove(key []byte, fromLevel uint, proofDb lbchain-devdb.Putter) e
 			panic(fmt.Sprintf("%T: invalid node: %v", tn, tn))
 		}
 	}
-	hasher := newHasher(0, 0, nil)
+	hasher := newHasher(0, 0, nil, false)
 	for i, n := range nodes {
 		// Don't bother checking for errors here since hasher panics
 		// if encoding doesn't work and we're not writing to any database.
@@ -99,6 +100,27 @@ func (t *SecureTrie) Prove(key []byte, fromLevel uint, proofDb lbchain-devdb.Put
 	return t.trie.Prove(key, fromLevel, proofDb)
 }
 
+// ProveRange constructs a Merkle proof for the contiguous range of leaves between
+// first and last (both included). The proof consists of the paths to the two edge
+// keys, which need not exist in the trie; the leaves inside the range are to be
+// sent alongside and are checked by VerifyRangeProof.
+func (t *Trie) ProveRange(first, last []byte, proofDb lbchain-devdb.Putter) error {
+	if err := t.Prove(first, 0, proofDb); err != nil {
+		return err
+	}
+	if bytes.Equal(first, last) {
+		return nil
+	}
+	return t.Prove(last, 0, proofDb)
+}
+
+// ProveRange constructs a Merkle proof for the contiguous range of leaves between
+// first and last (both included). The edge keys are raw trie keys, i.e. they need
+// to be hashed already, same as the keys of the leaves inside the range.
+func (t *SecureTrie) ProveRange(first, last []byte, proofDb lbchain-devdb.Putter) error {
+	return t.trie.ProveRange(first, last, proofDb)
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the value for
 // key in a trie with the given root hash. VerifyProof returns an error if the
 // proof contains invalid trie nodes or the wrong value.
@@ -114,7 +136,7 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 		if err != nil {
 			return nil, fmt.Errorf("bad proof node %d: %v", i, err), i
 		}
-		keyrest, cld := get(n, key)
+		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
 		case nil:
 			// The trie doesn't contain the key.
@@ -128,7 +150,386 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 	}
 }
 
-func get(tn node, key []byte) ([]byte, node) {
+// VerifyRangeProof checks whlbchain-dever the given leaves are exactly the contiguous
+// range of a trie with the given root hash between the edge keys firstKey and
+// lastKey (both included), as proven by the edge proofs produced by ProveRange.
+// Any gap, extra or modified leaf, or forged boundary makes the verification
+// fail. The returned flag reports whlbchain-dever the trie contains more leaves on
+// the right of the range.
+//
+// There are a few special cases:
+//   - If proofDb is nil, the leaves must make up the entire trie.
+//   - If there are no leaves, the proof of firstKey needs to show that there are
+//     no leaves from firstKey onwards (i.e. the range reached the end).
+//   - If there is a single leaf and the edge keys are the same, the leaf must be
+//     proven by an existence proof.
+func VerifyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, keys [][]byte, values [][]byte, proofDb DatabaseReader) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	// Ensure the received batch is monotonic increasing and contains no deletions
+	for i := 0; i < len(keys)-1; i++ {
+		if bytes.Compare(keys[i], keys[i+1]) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+	}
+	for _, value := range values {
+		if len(value) == 0 {
+			return false, errors.New("range contains deletion")
+		}
+	}
+	// Without edge proofs, the leaves are expected to make up the entire trie
+	if proofDb == nil {
+		tr := newProofTrie(nil)
+		for i, key := range keys {
+			tr.Update(key, values[i])
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	// Without leaves, there must be nothing in the trie from the first key onwards
+	if len(keys) == 0 {
+		if rootHash == emptyRoot {
+			return false, nil
+		}
+		root, val, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+		if err != nil {
+			return false, err
+		}
+		if val != nil || hasRightElement(root, firstKey) {
+			return false, errors.New("more entries available")
+		}
+		return false, nil
+	}
+	if bytes.Compare(keys[0], firstKey) < 0 || bytes.Compare(keys[len(keys)-1], lastKey) > 0 {
+		return false, errors.New("range exceeds the edge keys")
+	}
+	// A single leaf with equal edge keys must be proven directly
+	if len(keys) == 1 && bytes.Equal(firstKey, lastKey) {
+		root, val, err := proofToPath(rootHash, nil, firstKey, proofDb, false)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(val, values[0]) {
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, firstKey), nil
+	}
+	// In all other cases both edge paths are needed, possibly proving absence
+	if bytes.Compare(firstKey, lastKey) >= 0 {
+		return false, errors.New("invalid edge keys")
+	}
+	if len(firstKey) != len(lastKey) {
+		return false, errors.New("inconsistent edge keys")
+	}
+	root, _, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	root, _, err = proofToPath(rootHash, root, lastKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	// Remove everything between the edge paths and refill it from the leaves. If
+	// the range is complete and correct, the trie will have the original root.
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err != nil {
+		return false, err
+	}
+	if empty {
+		root = nil
+	}
+	tr := newProofTrie(root)
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, fmt.Errorf("invalid proof, leaf %x outside of the proven paths: %v", key, err)
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return hasRightElement(tr.root, keys[len(keys)-1]), nil
+}
+
+// newProofTrie creates an ephemeral trie on top of an optional partial root node
+// reconstructed from a proof. Nodes not contained in the proof are missing.
+func newProofTrie(root node) *Trie {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	return &Trie{root: root, db: NewDatabase(db)}
+}
+
+// proofToPath converts a Merkle proof to a trie node path, resolving all the
+// nodes on the way to the given key. The path is merged into the given root
+// if it's not nil, otherwise a new root is resolved from the proof. The value
+// of the key is returned too if it exists in the trie.
+//
+// If allowNonExistent is set, a proof of absence is accepted too.
+func proofToPath(rootHash common.Hash, root node, key []byte, proofDb DatabaseReader, allowNonExistent bool) (node, []byte, error) {
+	// resolveNode retrieves and resolves a trie node from the Merkle proof
+	resolveNode := func(hash common.Hash) (node, error) {
+		buf, _ := proofDb.Get(hash[:])
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash[:], buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %v", err)
+		}
+		return n, nil
+	}
+	// The root node must always be included in the proof
+	if root == nil {
+		n, err := resolveNode(rootHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	var (
+		err           error
+		child, parent node
+		keyrest       []byte
+		valnode       []byte
+	)
+	key, parent = keybytesToHex(key), root
+	for {
+		keyrest, child = get(parent, key, false)
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key. It's a proof of absence, but all
+			// the resolved nodes are proven, which is enough to prove the range.
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("the node is not contained in trie")
+		case *shortNode, *fullNode:
+			// Already resolved (merged path or embedded node)
+			key, parent = keyrest, child
+			continue
+		case hashNode:
+			child, err = resolveNode(common.BytesToHash(cld))
+			if err != nil {
+				return nil, nil, err
+			}
+		case valueNode:
+			valnode = cld
+		}
+		// Link the parent and the resolved child
+		switch pnode := parent.(type) {
+		case *shortNode:
+			pnode.Val = child
+		case *fullNode:
+			pnode.Children[key[0]] = child
+		default:
+			return nil, nil, fmt.Errorf("%T: invalid proof node: %v", pnode, pnode)
+		}
+		if len(valnode) > 0 {
+			return root, valnode, nil // The whole path is resolved
+		}
+		key, parent = keyrest, child
+	}
+}
+
+// unsetInternal removes all the internal node references (hash nodes, embedded
+// nodes) between the two edge paths of a range proof, so that the removed parts
+// can be rebuilt from the leaves of the range. All the visited nodes are marked
+// dirty, since their content might be modified. The returned flag reports that
+// the whole trie is within the range, so it needs to be rebuilt from scratch.
+//
+// Note, the edge keys are expected to be different, left being smaller.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point. It's either a short node whose key doesn't
+	// match one of the edge paths, or a full node where the paths split (they
+	// may point to non-existent children too).
+	var (
+		pos    = 0
+		parent node
+
+		// Fork indicators: 0 = path matches, -1 = path is less, 1 = path is greater
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := (n).(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if len(left)-pos < len(rn.Key) {
+				shortForkLeft = bytes.Compare(left[pos:], rn.Key)
+			} else {
+				shortForkLeft = bytes.Compare(left[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if len(right)-pos < len(rn.Key) {
+				shortForkRight = bytes.Compare(right[pos:], rn.Key)
+			} else {
+				shortForkRight = bytes.Compare(right[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+		case *fullNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if rn.Children[left[pos]] == nil || rn.Children[right[pos]] == nil || left[pos] != right[pos] {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+		default:
+			return false, fmt.Errorf("%T: invalid edge path node: %v", n, n)
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// There are five scenarios at a short node fork point:
+		//   - both edges are less than the node path: no valid range
+		//   - both edges are greater than the node path: no valid range
+		//   - left edge is less and right is greater: unset the entire node
+		//   - left edge points into the node, right is greater
+		//   - right edge points into the node, left is less
+		if shortForkLeft == -1 && shortForkRight == -1 {
+			return false, errors.New("empty range")
+		}
+		if shortForkLeft == 1 && shortForkRight == 1 {
+			return false, errors.New("empty range")
+		}
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			if parent == nil {
+				return true, nil // The fork point is the root, unset the entire trie
+			}
+			parent.(*fullNode).Children[left[pos-1]] = nil
+			return false, nil
+		}
+		// Only one of the edges points to a non-existent key
+		if shortForkRight != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				parent.(*fullNode).Children[left[pos-1]] = nil
+				return false, nil
+			}
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		if shortForkLeft != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				parent.(*fullNode).Children[right[pos-1]] = nil
+				return false, nil
+			}
+			return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+		}
+		return false, nil
+	case *fullNode:
+		// Unset all the children between the edge paths at the fork point
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("%T: invalid fork node: %v", n, n)
+	}
+}
+
+// unset removes all the internal node references on one side of an edge path,
+// the left side if removeLeft is set, the right side otherwise. If the path is
+// non-existent in the trie, the branch at the fork point is either removed (if
+// it's inside the range) or kept with its cached hash (if it's outside).
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// Fork point of a non-existent path, drop the branch if it's in range
+			fn, ok := parent.(*fullNode)
+			if !ok {
+				return errors.New("invalid edge path, short node without full parent")
+			}
+			if removeLeft && bytes.Compare(cld.Key, key[pos:]) < 0 {
+				fn.Children[key[pos-1]] = nil
+			}
+			if !removeLeft && bytes.Compare(cld.Key, key[pos:]) > 0 {
+				fn.Children[key[pos-1]] = nil
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			fn, ok := parent.(*fullNode)
+			if !ok {
+				return errors.New("invalid edge path, leaf without full parent")
+			}
+			fn.Children[key[pos-1]] = nil
+			return nil
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+	case nil:
+		// Non-existent branch of the fork point full node
+		return nil
+	default:
+		return fmt.Errorf("%T: invalid edge path node: %v", child, child)
+	}
+}
+
+// hasRightElement returns whlbchain-dever there are more leaves on the right side of
+// the given path, which may point to an existent or a non-existent key. The whole
+// path is expected to be resolved already.
+func hasRightElement(node node, key []byte) bool {
+	pos, key := 0, keybytesToHex(key)
+	for node != nil {
+		switch rn := node.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true
+				}
+			}
+			node, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0
+			}
+			node, pos = rn.Val, pos+len(rn.Key)
+		case valueNode:
+			return false // The whole path is resolved
+		default:
+			return true // Unresolved subtree, it may hold anything
+		}
+	}
+	return false
+}
+
+// get returns the child of the given node. Return nil if the node with specified
+// key doesn't exist at all.
+//
+// There is an additional flag `skipResolved`. If it's set then all resolved nodes
+// won't be returned.
+func get(tn node, key []byte, skipResolved bool) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
 		case *shortNode:
@@ -137,9 +538,15 @@ func get(tn node, key []byte) ([]byte, node) {
 			}
 			tn = n.Val
 			key = key[len(n.Key):]
+			if !skipResolved {
+				return key, tn
+			}
 		case *fullNode:
 			tn = n.Children[key[0]]
 			key = key[1:]
+			if !skipResolved {
+				return key, tn
+			}
 		case hashNode:
 			return key, n
 		case nil:
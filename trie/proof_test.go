@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -88,6 +89,200 @@ func TestVerifyBadProof(t *testing.T) {
 	}
 }
 
+// sortedEntries returns the contents of a random trie, ordered by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// rangeData splits a list of trie entries into the keys and values.
+func rangeData(entries []*kv) ([][]byte, [][]byte) {
+	var keys, vals [][]byte
+	for _, kv := range entries {
+		keys = append(keys, kv.k)
+		vals = append(vals, kv.v)
+	}
+	return keys, vals
+}
+
+// Tests that random ranges of leaves can be proven, both with existing and with
+// non-existent edge keys, and that the continuation flag is reported correctly.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + mrand.Intn(len(entries)-start)
+
+		// Prove the range with the leaves as edges
+		proof, _ := lbchain-devdb.NewMemDatabase()
+		if err := trie.ProveRange(entries[start].k, entries[end].k, proof); err != nil {
+			t.Fatalf("failed to prove range [%d, %d]: %v", start, end, err)
+		}
+		keys, values := rangeData(entries[start : end+1])
+		more, err := VerifyRangeProof(root, entries[start].k, entries[end].k, keys, values, proof)
+		if err != nil {
+			t.Fatalf("range [%d, %d]: valid proof rejected: %v", start, end, err)
+		}
+		if want := end != len(entries)-1; more != want {
+			t.Fatalf("range [%d, %d]: continuation mismatch: have %v, want %v", start, end, more, want)
+		}
+		// Prove the range with non-existent edges right outside of it
+		first, last := decreaseKey(common.CopyBytes(entries[start].k)), increaseKey(common.CopyBytes(entries[end].k))
+		if bytes.Compare(first, entries[start].k) >= 0 || bytes.Compare(last, entries[end].k) <= 0 {
+			continue // Key wrapped around
+		}
+		if start > 0 && bytes.Compare(first, entries[start-1].k) <= 0 {
+			continue
+		}
+		if end < len(entries)-1 && bytes.Compare(last, entries[end+1].k) >= 0 {
+			continue
+		}
+		proof, _ = lbchain-devdb.NewMemDatabase()
+		if err := trie.ProveRange(first, last, proof); err != nil {
+			t.Fatalf("failed to prove range [%d, %d]: %v", start, end, err)
+		}
+		if _, err := VerifyRangeProof(root, first, last, keys, values, proof); err != nil {
+			t.Fatalf("range [%d, %d]: valid proof with absent edges rejected: %v", start, end, err)
+		}
+	}
+}
+
+// Tests the special cases of range proofs: single leaves, the entire trie and
+// empty ranges at the end of the trie.
+func TestRangeProofSpecialCases(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	// A single leaf with equal edges
+	for _, pos := range []int{0, len(entries) / 2, len(entries) - 1} {
+		proof, _ := lbchain-devdb.NewMemDatabase()
+		trie.ProveRange(entries[pos].k, entries[pos].k, proof)
+
+		keys, values := rangeData(entries[pos : pos+1])
+		more, err := VerifyRangeProof(root, entries[pos].k, entries[pos].k, keys, values, proof)
+		if err != nil {
+			t.Fatalf("leaf %d: valid proof rejected: %v", pos, err)
+		}
+		if want := pos != len(entries)-1; more != want {
+			t.Fatalf("leaf %d: continuation mismatch: have %v, want %v", pos, more, want)
+		}
+		if _, err := VerifyRangeProof(root, entries[pos].k, entries[pos].k, keys, [][]byte{{0x01}}, proof); err == nil {
+			t.Fatalf("leaf %d: invalid value accepted", pos)
+		}
+	}
+	// The entire trie without any edge proofs
+	keys, values := rangeData(entries)
+	if _, err := VerifyRangeProof(root, nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("entire trie rejected: %v", err)
+	}
+	if _, err := VerifyRangeProof(root, nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("incomplete trie accepted")
+	}
+	// An empty range, only valid at the end of the trie
+	last := increaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	proof, _ := lbchain-devdb.NewMemDatabase()
+	trie.Prove(last, 0, proof)
+	if _, err := VerifyRangeProof(root, last, last, nil, nil, proof); err != nil {
+		t.Fatalf("empty tail range rejected: %v", err)
+	}
+	middle := increaseKey(common.CopyBytes(entries[len(entries)/2].k))
+	proof, _ = lbchain-devdb.NewMemDatabase()
+	trie.Prove(middle, 0, proof)
+	if _, err := VerifyRangeProof(root, middle, middle, nil, nil, proof); err == nil {
+		t.Fatalf("empty range with more leaves accepted")
+	}
+}
+
+// Tests that tampered ranges are rejected: missing, extra or modified leaves and
+// edges hiding leaves of the trie.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries) - 2)
+		end := start + 2 + mrand.Intn(len(entries)-start-2)
+
+		proof, _ := lbchain-devdb.NewMemDatabase()
+		trie.ProveRange(entries[start].k, entries[end].k, proof)
+
+		first, last := entries[start].k, entries[end].k
+		keys, values := rangeData(entries[start : end+1])
+
+		var desc string
+		switch index := mrand.Intn(len(keys)); mrand.Intn(6) {
+		case 0:
+			desc = "modified key"
+			keys[index] = increaseKey(common.CopyBytes(keys[index]))
+		case 1:
+			desc = "modified value"
+			values[index] = randBytes(20)
+		case 2:
+			desc = "removed leaf"
+			keys = append(keys[:index], keys[index+1:]...)
+			values = append(values[:index], values[index+1:]...)
+		case 3:
+			desc = "extra leaf"
+			if index == 0 {
+				index = 1
+			}
+			extra := decreaseKey(common.CopyBytes(keys[index]))
+			if bytes.Equal(extra, keys[index-1]) {
+				continue
+			}
+			keys = append(keys[:index], append([][]byte{extra}, keys[index:]...)...)
+			values = append(values[:index], append([][]byte{randBytes(20)}, values[index:]...)...)
+		case 4:
+			desc = "swapped leaves"
+			other := (index + 1) % len(keys)
+			keys[index], keys[other] = keys[other], keys[index]
+			values[index], values[other] = values[other], values[index]
+		case 5:
+			desc = "hidden edge leaf"
+			if end+1 == len(entries) {
+				continue
+			}
+			last = entries[end+1].k
+			proof, _ = lbchain-devdb.NewMemDatabase()
+			trie.ProveRange(first, last, proof)
+		}
+		if _, err := VerifyRangeProof(root, first, last, keys, values, proof); err == nil {
+			t.Fatalf("range [%d, %d]: %s accepted", start, end, desc)
+		}
+	}
+}
+
+// increaseKey returns the key incremented by one (in place).
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+// decreaseKey returns the key decremented by one (in place).
+func decreaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
@@ -472,7 +472,47 @@ func (t *Trie) hashRoot(db *Database, onleaf LeafCallback) (node, node, error) {
 	if t.root == nil {
 		return hashNode(emptyRoot.Bytes()), nil, nil
 	}
-	h := newHasher(t.cachegen, t.cachelimit, onleaf)
+	h := newHasher(t.cachegen, t.cachelimit, onleaf, t.parallelHash(db != nil))
 	defer returnHasherToPool(h)
-	return h.hash(t.root, db, true)
+
+	hashed, cached, err := h.hash(t.root, db, true)
+	if err != nil {
+		return hashed, cached, err
+	}
+	if db != nil {
+		h.flush(db)
+	}
+	return hashed, cached, nil
+}
+
+// parallelHash decides whlbchain-dever the trie has enough nodes left to process
+// to be worth hashing concurrently. These are the nodes without a cached hash
+// when only hashing, or the dirty ones when committing, as hashing doesn't
+// clear the dirty flags.
+func (t *Trie) parallelHash(commit bool) bool {
+	return countUnprocessed(t.root, commit, parallelHashThreshold) >= parallelHashThreshold
+}
+
+// countUnprocessed counts the nodes of a subtrie the hasher needs to process,
+// stopping as soon as the limit is reached.
+func countUnprocessed(n node, commit bool, limit int) int {
+	if hash, dirty := n.cache(); hash != nil && !(commit && dirty) {
+		return 0
+	}
+	switch n := n.(type) {
+	case *shortNode:
+		return 1 + countUnprocessed(n.Val, commit, limit-1)
+
+	case *fullNode:
+		count := 1
+		for i := 0; i < 16 && count < limit; i++ {
+			if n.Children[i] != nil {
+				count += countUnprocessed(n.Children[i], commit, limit-count)
+			}
+		}
+		return count
+
+	default:
+		return 0
+	}
 }
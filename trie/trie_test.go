@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"os"
@@ -558,22 +559,199 @@ func benchUpdate(b *testing.B, e binary.ByteOrder) *Trie {
 	return trie
 }
 
+// Tests that hashing and committing a trie concurrently produces the exact same
+// root hash, database nodes and leaf callbacks as doing it on a single thread.
+func TestParallelHashing(t *testing.T) {
+	addresses, accounts := makeAccounts(5000)
+
+	var (
+		hashes [2]common.Hash
+		roots  [2]common.Hash
+		dbs    [2]*Database
+		leaves [2]int
+	)
+	defer func(threshold int) { parallelHashThreshold = threshold }(parallelHashThreshold)
+
+	for i, parallel := range []bool{false, true} {
+		parallelHashThreshold = 100
+		if !parallel {
+			parallelHashThreshold = math.MaxInt32
+		}
+		trie := newEmpty()
+		for j := 0; j < len(addresses); j++ {
+			trie.Update(crypto.Keccak256(addresses[j][:]), accounts[j])
+		}
+		hashes[i] = trie.Hash()
+
+		// Modify the trie after hashing to ensure dirty nodes are committed too
+		for j := 0; j < 100; j++ {
+			trie.Delete(crypto.Keccak256(addresses[j][:]))
+		}
+		root, err := trie.Commit(func(leaf []byte, parent common.Hash) error {
+			leaves[i]++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("parallel %v: failed to commit trie: %v", parallel, err)
+		}
+		roots[i], dbs[i] = root, trie.db
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("hash mismatch: sequential %x, parallel %x", hashes[0], hashes[1])
+	}
+	if roots[0] != roots[1] {
+		t.Errorf("root hash mismatch: sequential %x, parallel %x", roots[0], roots[1])
+	}
+	if leaves[0] != leaves[1] {
+		t.Errorf("leaf callback count mismatch: sequential %d, parallel %d", leaves[0], leaves[1])
+	}
+	if len(dbs[0].nodes) != len(dbs[1].nodes) {
+		t.Errorf("node count mismatch: sequential %d, parallel %d", len(dbs[0].nodes), len(dbs[1].nodes))
+	}
+	for hash, node := range dbs[0].nodes {
+		other, ok := dbs[1].nodes[hash]
+		if !ok {
+			t.Errorf("node %x missing from parallel commit", hash)
+			continue
+		}
+		if !bytes.Equal(node.blob, other.blob) || !reflect.DeepEqual(node.children, other.children) || node.parents != other.parents {
+			t.Errorf("node %x mismatch between sequential and parallel commit", hash)
+		}
+	}
+	// Flush both tries to disk, spanning multiple database batches
+	var disks [2]*lbchain-devdb.MemDatabase
+	for i, db := range dbs {
+		if err := db.Commit(roots[i], false); err != nil {
+			t.Fatalf("failed to flush trie %d: %v", i, err)
+		}
+		disks[i] = db.diskdb.(*lbchain-devdb.MemDatabase)
+	}
+	if disks[0].Len() != disks[1].Len() {
+		t.Errorf("disk node count mismatch: sequential %d, parallel %d", disks[0].Len(), disks[1].Len())
+	}
+	for _, key := range disks[0].Keys() {
+		want, _ := disks[0].Get(key)
+		if have, _ := disks[1].Get(key); !bytes.Equal(have, want) {
+			t.Errorf("disk node %x mismatch between sequential and parallel commit", key)
+		}
+	}
+	trie, err := New(roots[1], NewDatabase(disks[1]))
+	if err != nil {
+		t.Fatalf("failed to reopen flushed trie: %v", err)
+	}
+	count := 0
+	for it := NewIterator(trie.NodeIterator(nil)); it.Next(); {
+		count++
+	}
+	if count != len(addresses)-100 {
+		t.Errorf("flushed leaf count mismatch: have %d, want %d", count, len(addresses)-100)
+	}
+}
+
+// Tests that the concurrent hashing is decided on the nodes left to process, so
+// a commit following the hashing of a large trie is still done concurrently.
+func TestParallelHashDecision(t *testing.T) {
+	addresses, accounts := makeAccounts(5000)
+
+	trie := newEmpty()
+	for i := 0; i < len(addresses); i++ {
+		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
+	}
+	if !trie.parallelHash(false) || !trie.parallelHash(true) {
+		t.Fatalf("fresh trie not hashed concurrently")
+	}
+	// Hashing caches the node hashes, but leaves them dirty for the commit
+	trie.Hash()
+	if trie.parallelHash(false) {
+		t.Errorf("hashed trie rehashed concurrently")
+	}
+	if !trie.parallelHash(true) {
+		t.Errorf("hashed trie not committed concurrently")
+	}
+	// Committing clears the dirty nodes, small modifications don't need threads
+	if _, err := trie.Commit(nil); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if trie.parallelHash(true) {
+		t.Errorf("committed trie recommitted concurrently")
+	}
+	trie.Delete(crypto.Keccak256(addresses[0][:]))
+	if trie.parallelHash(false) || trie.parallelHash(true) {
+		t.Errorf("single modification processed concurrently")
+	}
+}
+
 // Benchmarks the trie hashing. Since the trie caches the result of any operation,
 // we cannot use b.N as the number of hashing rouns, since all rounds apart from
 // the first one will be NOOP. As such, we'll use b.N as the number of account to
 // insert into the trie before measuring the hashing.
 func BenchmarkHash(b *testing.B) {
+	// Create a realistic account trie to hash
+	addresses, accounts := makeAccounts(b.N)
+
+	// Insert the accounts into the trie and hash it
+	trie := newEmpty()
+	for i := 0; i < len(addresses); i++ {
+		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	trie.Hash()
+}
+
+// Benchmarks the hashing and the committing of fixed size account tries, both on
+// a single thread and with the top level children hashed concurrently.
+func BenchmarkHashFixedSize(b *testing.B)   { benchFixedSize(b, false) }
+func BenchmarkCommitFixedSize(b *testing.B) { benchFixedSize(b, true) }
+
+func benchFixedSize(b *testing.B, commit bool) {
+	defer func(threshold int) { parallelHashThreshold = threshold }(parallelHashThreshold)
+
+	for _, size := range []int{100, 1000, 10000, 100000} {
+		addresses, accounts := makeAccounts(size)
+		for _, parallel := range []bool{false, true} {
+			name := fmt.Sprintf("%d/sequential", size)
+			if parallel {
+				name = fmt.Sprintf("%d/parallel", size)
+			}
+			parallelHashThreshold = 100
+			if !parallel {
+				parallelHashThreshold = math.MaxInt32
+			}
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					trie := newEmpty()
+					for j := 0; j < len(addresses); j++ {
+						trie.Update(crypto.Keccak256(addresses[j][:]), accounts[j])
+					}
+					b.StartTimer()
+
+					if commit {
+						trie.Commit(nil)
+					} else {
+						trie.Hash()
+					}
+				}
+			})
+		}
+	}
+}
+
+// makeAccounts generates a deterministic set of random addresses and RLP encoded
+// accounts belonging to them.
+func makeAccounts(size int) (addresses [][20]byte, accounts [][]byte) {
 	// Make the random benchmark deterministic
 	random := rand.New(rand.NewSource(0))
 
-	// Create a realistic account trie to hash
-	addresses := make([][20]byte, b.N)
+	addresses = make([][20]byte, size)
 	for i := 0; i < len(addresses); i++ {
 		for j := 0; j < len(addresses[i]); j++ {
 			addresses[i][j] = byte(random.Intn(256))
 		}
 	}
-	accounts := make([][]byte, len(addresses))
+	accounts = make([][]byte, len(addresses))
 	for i := 0; i < len(accounts); i++ {
 		var (
 			nonce   = uint64(random.Int63())
@@ -583,14 +761,7 @@ func BenchmarkHash(b *testing.B) {
 		)
 		accounts[i], _ = rlp.EncodeToBytes([]interface{}{nonce, balance, root, code})
 	}
-	// Insert the accounts into the trie and hash it
-	trie := newEmpty()
-	for i := 0; i < len(addresses); i++ {
-		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
-	}
-	b.ResetTimer()
-	b.ReportAllocs()
-	trie.Hash()
+	return addresses, accounts
 }
 
 func tempDB() (string, *Database) {
@@ -19,6 +19,7 @@ package lbchain-dev
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -30,6 +31,8 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-dev/filters"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/log"
 	"github.com/lbchain-devchain/go-lbchain-dev/miner"
 	"github.com/lbchain-devchain/go-lbchain-dev/params"
@@ -291,6 +294,37 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// TxPoolPolicy retrieves the built-in admission rules enforced by the transaction pool.
+func (api *PrivateAdminAPI) TxPoolPolicy() core.TxPolicyConfig {
+	return api.lbchain-dev.TxPool().Policy()
+}
+
+// SetTxPoolPolicy replaces the built-in admission rules of the transaction pool.
+func (api *PrivateAdminAPI) SetTxPoolPolicy(config core.TxPolicyConfig) bool {
+	api.lbchain-dev.TxPool().SetPolicy(config)
+	return true
+}
+
+// AllowTxSender permits an account to submit transactions to a restricted pool.
+func (api *PrivateAdminAPI) AllowTxSender(addr common.Address) bool {
+	return api.lbchain-dev.TxPool().AllowSender(addr)
+}
+
+// DisallowTxSender revokes the permission of an account to submit transactions.
+func (api *PrivateAdminAPI) DisallowTxSender(addr common.Address) bool {
+	return api.lbchain-dev.TxPool().DisallowSender(addr)
+}
+
+// BlockTxDestination rejects any new transaction sent to the given account.
+func (api *PrivateAdminAPI) BlockTxDestination(addr common.Address) bool {
+	return api.lbchain-dev.TxPool().BlockDestination(addr)
+}
+
+// UnblockTxDestination accepts transactions sent to the given account again.
+func (api *PrivateAdminAPI) UnblockTxDestination(addr common.Address) bool {
+	return api.lbchain-dev.TxPool().UnblockDestination(addr)
+}
+
 // PublicDebugAPI is the collection of lbchain-devchain full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -305,12 +339,54 @@ func NewPublicDebugAPI(lbchain-dev *lbchain-devchain) *PublicDebugAPI {
 
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	return stateDb.RawDump()
+}
+
+const (
+	// AccountRangeMaxResults is the maximum number of accounts returned by a
+	// single debug_accountRange call.
+	AccountRangeMaxResults = 256
+
+	// AccountRangeMaxStorage is the maximum number of storage slots returned for
+	// each account by a debug_accountRange call. The rest can be retrieved via
+	// debug_getStorageRange, starting from the returned slot hash.
+	AccountRangeMaxStorage = 256
+)
+
+// AccountRange enumerates the accounts of the state at a given block in account
+// hash order, starting at the given address or account hash. The result holds
+// the hash to continue from in the next call if there are accounts left. The
+// storage of every account is capped at AccountRangeMaxStorage slots.
+func (api *PublicDebugAPI) AccountRange(blockNr rpc.BlockNumber, start hexutil.Bytes, maxResults int, nocode, nostorage *bool) (state.IteratorDump, error) {
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	conf := &state.DumpConfig{
+		SkipCode:    nocode != nil && *nocode,
+		SkipStorage: nostorage != nil && *nostorage,
+		Start:       state.DumpStartKey(start),
+		Max:         uint64(maxResults),
+		MaxStorage:  AccountRangeMaxStorage,
+	}
+	return stateDb.IteratorDump(conf)
+}
+
+// stateAt retrieves the state of the database at a given block.
+func (api *PublicDebugAPI) stateAt(blockNr rpc.BlockNumber) (*state.StateDB, error) {
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
 		// the miner and operate on those
 		_, stateDb := api.lbchain-dev.miner.Pending()
-		return stateDb.RawDump(), nil
+		return stateDb, nil
 	}
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
@@ -319,13 +395,9 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		block = api.lbchain-dev.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
-	}
-	stateDb, err := api.lbchain-dev.BlockChain().StateAt(block.Root())
-	if err != nil {
-		return state.Dump{}, err
+		return nil, fmt.Errorf("block #%d not found", blockNr)
 	}
-	return stateDb.RawDump(), nil
+	return api.lbchain-dev.BlockChain().StateAt(block.Root())
 }
 
 // PrivateDebugAPI is the collection of lbchain-devchain full node APIs exposed over
@@ -353,6 +425,57 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockAr
 	return api.lbchain-dev.BlockChain().BadBlocks()
 }
 
+// GetReorgs returns the most recent chain reorganisations the node went through,
+// oldest first.
+func (api *PrivateDebugAPI) GetReorgs(ctx context.Context) []*filters.Reorg {
+	reorgs := api.lbchain-dev.BlockChain().Reorgs()
+
+	results := make([]*filters.Reorg, len(reorgs))
+	for i, reorg := range reorgs {
+		results[i] = filters.NewReorg(reorg)
+	}
+	return results
+}
+
+// GetBlockWitness returns the RLP encoded witness of a block: the block itself,
+// its parent header and the trie nodes, contract codes and ancestor headers
+// needed to reexecute it statelessly. The parent state is regenerated from the
+// nearest available one if needed, reexecuting at most reexec blocks.
+func (api *PrivateDebugAPI) GetBlockWitness(ctx context.Context, number rpc.BlockNumber, reexec *uint64) (hexutil.Bytes, error) {
+	var block *types.Block
+	switch number {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("pending block not supported")
+	case rpc.LatestBlockNumber:
+		block = api.lbchain-dev.blockchain.CurrentBlock()
+	default:
+		block = api.lbchain-dev.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis has no witness")
+	}
+	parent := api.lbchain-dev.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	limit := defaultTraceReexec
+	if reexec != nil {
+		limit = *reexec
+	}
+	statedb, err := api.computeStateDB(parent, limit)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := core.GenerateWitness(api.lbchain-dev.blockchain, block, statedb.Database().TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(witness)
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -402,6 +525,121 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 	return result, nil
 }
 
+// maxTrieRangeResults is the maximum number of leaves returned in a single proven
+// trie range, to avoid oversized responses.
+const maxTrieRangeResults = 1024
+
+// TrieRangeResult is the result of a debug_getAccountRange or debug_getStorageRange
+// API call: a contiguous range of trie leaves along with the Merkle proof of its
+// edges, to be checked via trie.VerifyRangeProof against the returned root.
+type TrieRangeResult struct {
+	Root    common.Hash      `json:"root"`
+	Entries []TrieRangeEntry `json:"entries"`
+	Proof   []hexutil.Bytes  `json:"proof"`
+	Next    *common.Hash     `json:"next"` // nil if Entries includes the last key in the trie.
+}
+
+// TrieRangeEntry is a single leaf of a proven trie range.
+type TrieRangeEntry struct {
+	Hash     common.Hash   `json:"hash"`
+	Preimage hexutil.Bytes `json:"preimage,omitempty"`
+	Value    hexutil.Bytes `json:"value"`
+}
+
+// GetAccountRange returns a range of the account trie at the given block height,
+// starting at the given account hash, along with a Merkle proof of the range.
+func (api *PrivateDebugAPI) GetAccountRange(ctx context.Context, blockNr rpc.BlockNumber, start common.Hash, maxResults int) (TrieRangeResult, error) {
+	statedb, root, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return TrieRangeResult{}, err
+	}
+	tr, err := trie.NewSecure(root, statedb.Database().TrieDB(), 0)
+	if err != nil {
+		return TrieRangeResult{}, err
+	}
+	return trieRange(tr, start, maxResults)
+}
+
+// GetStorageRange returns a range of the storage trie of an account at the given
+// block height, starting at the given slot hash, along with a Merkle proof of the
+// range.
+func (api *PrivateDebugAPI) GetStorageRange(ctx context.Context, blockNr rpc.BlockNumber, address common.Address, start common.Hash, maxResults int) (TrieRangeResult, error) {
+	statedb, _, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return TrieRangeResult{}, err
+	}
+	st := statedb.StorageTrie(address)
+	if st == nil {
+		return TrieRangeResult{}, fmt.Errorf("account %x doesn't exist", address)
+	}
+	tr, err := trie.NewSecure(st.Hash(), statedb.Database().TrieDB(), 0)
+	if err != nil {
+		return TrieRangeResult{}, err
+	}
+	return trieRange(tr, start, maxResults)
+}
+
+// stateAtNumber retrieves the state and its root hash at the given block height.
+func (api *PrivateDebugAPI) stateAtNumber(blockNr rpc.BlockNumber) (*state.StateDB, common.Hash, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		return nil, common.Hash{}, fmt.Errorf("pending state not supported")
+	case rpc.LatestBlockNumber:
+		block = api.lbchain-dev.blockchain.CurrentBlock()
+	default:
+		block = api.lbchain-dev.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, common.Hash{}, fmt.Errorf("block #%d not found", blockNr)
+	}
+	statedb, err := api.lbchain-dev.BlockChain().StateAt(block.Root())
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	return statedb, block.Root(), nil
+}
+
+// trieRange collects a range of leaves from a secure trie starting at the given
+// hash, proving the range by the paths to the start and the last leaf returned.
+func trieRange(tr *trie.SecureTrie, start common.Hash, maxResults int) (TrieRangeResult, error) {
+	if maxResults <= 0 || maxResults > maxTrieRangeResults {
+		maxResults = maxTrieRangeResults
+	}
+	result := TrieRangeResult{Root: tr.Hash(), Entries: []TrieRangeEntry{}}
+
+	it := trie.NewIterator(tr.NodeIterator(start[:]))
+	for len(result.Entries) < maxResults && it.Next() {
+		result.Entries = append(result.Entries, TrieRangeEntry{
+			Hash:     common.BytesToHash(it.Key),
+			Preimage: tr.GetKey(it.Key),
+			Value:    common.CopyBytes(it.Value),
+		})
+	}
+	// Add the 'next key' so clients can continue downloading.
+	if it.Next() {
+		next := common.BytesToHash(it.Key)
+		result.Next = &next
+	}
+	if it.Err != nil {
+		return TrieRangeResult{}, it.Err
+	}
+	// Prove the range, or the absence of anything after the start if it's empty
+	last := start
+	if len(result.Entries) > 0 {
+		last = result.Entries[len(result.Entries)-1].Hash
+	}
+	proof, _ := lbchain-devdb.NewMemDatabase()
+	if err := tr.ProveRange(start[:], last[:], proof); err != nil {
+		return TrieRangeResult{}, err
+	}
+	for _, key := range proof.Keys() {
+		node, _ := proof.Get(key)
+		result.Proof = append(result.Proof, node)
+	}
+	return result, nil
+}
+
 // GetModifiedAccountsByumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -23,6 +23,7 @@ import (
 	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/math"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/bloombits"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
@@ -46,6 +47,10 @@ func (b *lbchain-devApiBackend) ChainConfig() *params.ChainConfig {
 	return b.lbchain-dev.chainConfig
 }
 
+func (b *lbchain-devApiBackend) Engine() consensus.Engine {
+	return b.lbchain-dev.engine
+}
+
 func (b *lbchain-devApiBackend) CurrentBlock() *types.Block {
 	return b.lbchain-dev.blockchain.CurrentBlock()
 }
@@ -136,6 +141,10 @@ func (b *lbchain-devApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) e
 	return b.lbchain-dev.BlockChain().SubscribeChainEvent(ch)
 }
 
+func (b *lbchain-devApiBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.lbchain-dev.BlockChain().SubscribeChainReorgEvent(ch)
+}
+
 func (b *lbchain-devApiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
 	return b.lbchain-dev.BlockChain().SubscribeChainHeadEvent(ch)
 }
@@ -180,10 +189,22 @@ func (b *lbchain-devApiBackend) TxPoolContent() (map[common.Address]types.Transa
 	return b.lbchain-dev.TxPool().Content()
 }
 
+func (b *lbchain-devApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.lbchain-dev.TxPool().ContentFrom(addr)
+}
+
 func (b *lbchain-devApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subscription {
 	return b.lbchain-dev.TxPool().SubscribeTxPreEvent(ch)
 }
 
+func (b *lbchain-devApiBackend) TxPoolHistory(hash common.Hash) (core.TxStatus, []*core.TxLifecycleEvent) {
+	return b.lbchain-dev.TxPool().History(hash)
+}
+
+func (b *lbchain-devApiBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return b.lbchain-dev.TxPool().SubscribeTxLifecycleEvent(ch)
+}
+
 func (b *lbchain-devApiBackend) Downloader() *downloader.Downloader {
 	return b.lbchain-dev.Downloader()
 }
@@ -0,0 +1,134 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lbchain-dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/rpc"
+)
+
+// StateDiffConfig holds extra parameters to state diff functions.
+type StateDiffConfig struct {
+	Reexec *uint64
+}
+
+// BlockStateDiff is the set of state changes made by a block, both in total and
+// broken down by transaction.
+type BlockStateDiff struct {
+	Number       hexutil.Uint64       `json:"number"`
+	Hash         common.Hash          `json:"hash"`
+	Diff         state.StateDiff      `json:"diff"`
+	Transactions []*state.TxStateDiff `json:"transactions"`
+}
+
+// StateDiffBlock returns the accounts and storage slots modified by a block,
+// with their values before and after the block. The diffs recorded during import
+// are used for recent blocks, others are reexecuted on top of their parent state,
+// regenerating it from the nearest available one if needed.
+func (api *PrivateDebugAPI) StateDiffBlock(ctx context.Context, number rpc.BlockNumber, config *StateDiffConfig) (*BlockStateDiff, error) {
+	var block *types.Block
+
+	switch number {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("pending block not supported")
+	case rpc.LatestBlockNumber:
+		block = api.lbchain-dev.blockchain.CurrentBlock()
+	default:
+		block = api.lbchain-dev.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	diffs, err := api.blockDiffs(block, config)
+	if err != nil {
+		return nil, err
+	}
+	result := &BlockStateDiff{
+		Number:       hexutil.Uint64(block.NumberU64()),
+		Hash:         block.Hash(),
+		Diff:         make(state.StateDiff),
+		Transactions: make([]*state.TxStateDiff, 0, len(block.Transactions())),
+	}
+	for _, diff := range diffs {
+		result.Diff.Merge(diff.Diff)
+		if diff.TxHash != (common.Hash{}) {
+			result.Transactions = append(result.Transactions, diff)
+		}
+	}
+	return result, nil
+}
+
+// StateDiffTransaction returns the accounts and storage slots modified by a
+// single transaction, with their values before and after its execution.
+func (api *PrivateDebugAPI) StateDiffTransaction(ctx context.Context, hash common.Hash, config *StateDiffConfig) (state.StateDiff, error) {
+	_, blockHash, _, _ := core.GetTransaction(api.lbchain-dev.ChainDb(), hash)
+	if blockHash == (common.Hash{}) {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	block := api.lbchain-dev.blockchain.GetBlockByHash(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	diffs, err := api.blockDiffs(block, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, diff := range diffs {
+		if diff.TxHash == hash {
+			return diff.Diff, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %x not executed in block %x", hash, blockHash)
+}
+
+// blockDiffs retrieves the state changes made by each of the transactions of a
+// block and by the block finalisation. If they were not recorded during import,
+// the block is reexecuted on top of its parent state.
+func (api *PrivateDebugAPI) blockDiffs(block *types.Block, config *StateDiffConfig) ([]*state.TxStateDiff, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not diffable")
+	}
+	if diffs := api.lbchain-dev.blockchain.GetStateDiffs(block.Hash()); diffs != nil {
+		return diffs, nil
+	}
+	parent := api.lbchain-dev.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.computeStateDB(parent, reexec)
+	if err != nil {
+		return nil, err
+	}
+	statedb.EnableDiffs()
+	if _, _, _, err := api.lbchain-dev.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, fmt.Errorf("processing block %x failed: %v", block.Hash(), err)
+	}
+	return statedb.Diffs(), nil
+}
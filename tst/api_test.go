@@ -17,13 +17,16 @@
 package lbchain-dev
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -89,3 +92,75 @@ func Teslbchain-devorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the account and storage tries can be paged through in proven ranges,
+// each of which verifies against the trie root.
+func TestTrieRangeProofs(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	addr := common.Address{0xff}
+	statedb.SetNonce(addr, 1)
+	for i := 0; i < 100; i++ {
+		statedb.AddBalance(common.BytesToAddress([]byte{byte(i)}), big.NewInt(int64(i+1)))
+		statedb.Selbchain-devate(addr, common.BytesToHash([]byte{byte(i)}), common.Hash{31: byte(i + 1)})
+	}
+	root, _ := statedb.Commit(true)
+	statedb, _ = state.New(root, statedb.Database())
+
+	accTrie, _ := trie.NewSecure(root, statedb.Database().TrieDB(), 0)
+	storageTrie, _ := trie.NewSecure(statedb.StorageTrie(addr).Hash(), statedb.Database().TrieDB(), 0)
+
+	tests := []struct {
+		name   string
+		tr     *trie.SecureTrie
+		leaves int
+	}{
+		{"account", accTrie, 101},
+		{"storage", storageTrie, 100},
+	}
+	for _, tt := range tests {
+		var (
+			name  = tt.name
+			tr    = tt.tr
+			start common.Hash
+			count int
+		)
+		for pages := 0; ; pages++ {
+			if pages > 100 {
+				t.Fatalf("%s: range iteration not terminating", name)
+			}
+			result, err := trieRange(tr, start, 7)
+			if err != nil {
+				t.Fatalf("%s: failed to retrieve range from %x: %v", name, start, err)
+			}
+			last := start
+			if len(result.Entries) > 0 {
+				last = result.Entries[len(result.Entries)-1].Hash
+			}
+			var keys, values [][]byte
+			for _, entry := range result.Entries {
+				keys, values = append(keys, entry.Hash[:]), append(values, entry.Value)
+			}
+			proof, _ := lbchain-devdb.NewMemDatabase()
+			for _, node := range result.Proof {
+				proof.Put(crypto.Keccak256(node), node)
+			}
+			more, err := trie.VerifyRangeProof(result.Root, start[:], last[:], keys, values, proof)
+			if err != nil {
+				t.Fatalf("%s: range from %x failed to verify: %v", name, start, err)
+			}
+			if more != (result.Next != nil) {
+				t.Fatalf("%s: continuation mismatch: proven %v, next %v", name, more, result.Next)
+			}
+			count += len(result.Entries)
+			if result.Next == nil {
+				break
+			}
+			start = *result.Next
+		}
+		if count != tt.leaves {
+			t.Errorf("%s: leaf count mismatch: have %d, want %d", name, count, tt.leaves)
+		}
+	}
+}
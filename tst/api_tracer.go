@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lbchain-devchain/go-lbchain-dev/accounts/abi"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
 	"github.com/lbchain-devchain/go-lbchain-dev/core"
@@ -59,6 +60,13 @@ type TraceConfig struct {
 	Reexec  *uint64
 }
 
+// TraceCallConfig holds extra parameters to trace calls, in addition to the
+// ones of the trace functions.
+type TraceCallConfig struct {
+	TraceConfig
+	TxIndex *hexutil.Uint `json:"txIndex"` // Number of transactions of the block to apply before the call
+}
+
 // txTraceResult is the result of a single transaction trace.
 type txTraceResult struct {
 	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
@@ -386,10 +394,28 @@ func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string,
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requestd tracer.
 func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*txTraceResult, error) {
-	// Create the parent state database
 	if err := api.lbchain-dev.engine.VerifyHeader(api.lbchain-dev.blockchain, block.Header(), true); err != nil {
 		return nil, err
 	}
+	return api.traceBlockTxs(ctx, block, config, false)
+}
+
+// TraceBadBlock returns the structured logs created during the execution of a
+// bad block stored in the database. The block isn't verified, and if one of its
+// transactions fails to execute, the ones following it are reported as errors.
+func (api *PrivateDebugAPI) TraceBadBlock(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+	bad := api.lbchain-dev.blockchain.BadBlock(hash)
+	if bad == nil {
+		return nil, fmt.Errorf("bad block %#x not found", hash)
+	}
+	return api.traceBlockTxs(ctx, bad.Block, config, true)
+}
+
+// traceBlockTxs traces all the transactions of a block on top of its parent
+// state. If partial is set, a transaction failing to execute doesn't abort the
+// tracing, rather the transactions following it are reported as not executed.
+func (api *PrivateDebugAPI) traceBlockTxs(ctx context.Context, block *types.Block, config *TraceConfig, partial bool) ([]*txTraceResult, error) {
+	// Create the parent state database
 	parent := api.lbchain-dev.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
 		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
@@ -436,7 +462,10 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 		}()
 	}
 	// Feed the transactions into the tracers and return
-	var failed error
+	var (
+		failed error
+		index  int
+	)
 	for i, tx := range txs {
 		// Send the trace task over for execution
 		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
@@ -446,8 +475,8 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 		vmctx := core.NewEVMContext(msg, block.Header(), api.lbchain-dev.blockchain, nil)
 
 		vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
-		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
-			failed = err
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			failed, index = err, i
 			break
 		}
 		// Finalize the state so any modifications are written to the trie
@@ -456,9 +485,14 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	close(jobs)
 	pend.Wait()
 
-	// If execution failed in between, abort
+	// If execution failed in between, abort or mark the remaining transactions
 	if failed != nil {
-		return nil, failed
+		if !partial {
+			return nil, failed
+		}
+		for i := index + 1; i < len(txs); i++ {
+			results[i] = &txTraceResult{Error: fmt.Sprintf("not executed: transaction %d failed: %v", index, failed)}
+		}
 	}
 	return results, nil
 }
@@ -549,11 +583,108 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+// RevertReason is the data a reverted transaction was aborted with.
+type RevertReason struct {
+	Data   hexutil.Bytes `json:"data"`             // Raw data supplied to the REVERT opcode
+	Reason string        `json:"reason,omitempty"` // Decoded Error(string) reason, if standard
+}
+
+// GetTransactionRevertReason replays a mined transaction on top of its parent
+// state and returns the data it was reverted with, decoding it if possible.
+func (api *PrivateDebugAPI) GetTransactionRevertReason(ctx context.Context, hash common.Hash) (*RevertReason, error) {
+	tx, blockHash, _, index := core.GetTransaction(api.lbchain-dev.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	msg, vmctx, statedb, err := api.computeTxEnv(blockHash, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
+
+	result, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("replaying transaction %x failed: %v", hash, err)
+	}
+	switch {
+	case !result.Failed():
+		return nil, fmt.Errorf("transaction %x did not fail", hash)
+	case result.Err != vm.ErrExecutionReverted:
+		return nil, fmt.Errorf("transaction %x failed without reverting: %v", hash, result.Err)
+	}
+	reason := &RevertReason{Data: result.Revert()}
+	reason.Reason, _ = abi.UnpackRevert(reason.Data)
+	return reason, nil
+}
+
+// TraceCall returns the structured logs created during the execution of a call
+// on top of the state of the given block, and returns them as a JSON object. If
+// a transaction index is configured, the call is executed on top of the parent
+// state of the block after applying that many of its transactions.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
+	// Retrieve the block to trace the call on top of
+	var (
+		block   *types.Block
+		pending *state.StateDB
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.lbchain-dev.blockchain.GetBlockByHash(hash)
+	} else {
+		number, _ := blockNrOrHash.Number()
+		switch number {
+		case rpc.PendingBlockNumber:
+			block, pending = api.lbchain-dev.miner.Pending()
+		case rpc.LatestBlockNumber:
+			block = api.lbchain-dev.blockchain.CurrentBlock()
+		default:
+			block = api.lbchain-dev.blockchain.GetBlockByNumber(uint64(number))
+		}
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	if config == nil {
+		config = new(TraceCallConfig)
+	}
+	reexec := defaultTraceReexec
+	if config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	// Assemble the state to execute the call on top of
+	var (
+		statedb *state.StateDB
+		err     error
+	)
+	switch {
+	case config.TxIndex != nil:
+		statedb, err = api.computeBlockStateDB(block, int(*config.TxIndex), reexec)
+	case pending != nil:
+		statedb = pending
+	default:
+		statedb, err = api.computeStateDB(block, reexec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Trace the call and return. The sender isn't credited to afford the call, so
+	// unless specified, the gas is capped to the block's and is free of charge.
+	msg := args.ToMessage(api.lbchain-dev.AccountManager())
+
+	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
+	if gas == 0 {
+		gas = block.GasLimit()
+	}
+	msg = types.NewMessage(msg.From(), msg.To(), 0, msg.Value(), gas, gasPrice, msg.Data(), false)
+	vmctx := core.NewEVMContext(msg, block.Header(), api.lbchain-dev.blockchain, nil)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, &config.TraceConfig)
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
-	// Assemble the structured logger or the JavaScript tracer
+	// Assemble the structured logger, the native or the JavaScript tracer
 	var (
 		tracer vm.Tracer
 		err    error
@@ -567,15 +698,22 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
-			return nil, err
+		// Construct the native tracer if one exists by the name, the JavaScript one otherwise
+		var stop func(error)
+		if native, ok := tracers.NewNative(*config.Tracer); ok {
+			tracer, stop = native, native.Stop
+		} else {
+			jst, err := tracers.New(*config.Tracer)
+			if err != nil {
+				return nil, err
+			}
+			tracer, stop = jst, jst.Stop
 		}
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			stop(errors.New("execution timeout"))
 		}()
 		defer cancel()
 
@@ -588,7 +726,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
 
-	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
@@ -596,15 +734,18 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
 		return &ethapi.ExecutionResult{
-			Gas:         gas,
-			Failed:      failed,
-			ReturnValue: fmt.Sprintf("%x", ret),
+			Gas:         result.UsedGas,
+			Failed:      result.Failed(),
+			ReturnValue: fmt.Sprintf("%x", result.ReturnData),
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
 	case *tracers.Tracer:
 		return tracer.GetResult()
 
+	case tracers.NativeTracer:
+		return tracer.GetResult()
+
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
 	}
@@ -612,35 +753,53 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 
 // computeTxEnv returns the execution environment of a certain transaction.
 func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, reexec uint64) (core.Message, vm.Context, *state.StateDB, error) {
-	// Create the parent state database
 	block := api.lbchain-dev.blockchain.GetBlockByHash(blockHash)
 	if block == nil {
 		return nil, vm.Context{}, nil, fmt.Errorf("block %x not found", blockHash)
 	}
+	if txIndex < 0 || txIndex >= len(block.Transactions()) {
+		return nil, vm.Context{}, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
+	}
+	statedb, err := api.computeBlockStateDB(block, txIndex, reexec)
+	if err != nil {
+		return nil, vm.Context{}, nil, err
+	}
+	// Assemble the transaction call message of the requested offset
+	signer := types.MakeSigner(api.config, block.Number())
+
+	msg, _ := block.Transactions()[txIndex].AsMessage(signer)
+	context := core.NewEVMContext(msg, block.Header(), api.lbchain-dev.blockchain, nil)
+
+	return msg, context, statedb, nil
+}
+
+// computeBlockStateDB returns the parent state of a block with the given number
+// of its transactions applied.
+func (api *PrivateDebugAPI) computeBlockStateDB(block *types.Block, txCount int, reexec uint64) (*state.StateDB, error) {
+	if txCount < 0 || txCount > len(block.Transactions()) {
+		return nil, fmt.Errorf("tx index %d out of range for block %x", txCount, block.Hash())
+	}
+	// Create the parent state database
 	parent := api.lbchain-dev.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
-		return nil, vm.Context{}, nil, fmt.Errorf("parent %x not found", block.ParentHash())
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
 	}
 	statedb, err := api.computeStateDB(parent, reexec)
 	if err != nil {
-		return nil, vm.Context{}, nil, err
+		return nil, err
 	}
 	// Recompute transactions up to the target index.
 	signer := types.MakeSigner(api.config, block.Number())
 
-	for idx, tx := range block.Transactions() {
-		// Assemble the transaction call message and return if the requested offset
+	for _, tx := range block.Transactions()[:txCount] {
 		msg, _ := tx.AsMessage(signer)
 		context := core.NewEVMContext(msg, block.Header(), api.lbchain-dev.blockchain, nil)
-		if idx == txIndex {
-			return msg, context, statedb, nil
-		}
-		// Not yet the searched for transaction, execute on top of the current state
+
 		vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{})
-		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
-			return nil, vm.Context{}, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 		}
 		statedb.DeleteSuicides()
 	}
-	return nil, vm.Context{}, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
+	return statedb, nil
 }
@@ -0,0 +1,268 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lbchain-dev
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/accounts"
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/consensus/ethash"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/internal/ethapi"
+	"github.com/lbchain-devchain/go-lbchain-dev/params"
+	"github.com/lbchain-devchain/go-lbchain-dev/rpc"
+)
+
+// Tests that calls can be traced on top of the state of any block, optionally
+// after applying a number of its transactions.
+func TestTraceCall(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		sink    = common.Address{0xee}
+		checker = common.Address{0xc0} // Returns the balance of the sink
+
+		db, _  = lbchain-devdb.NewMemDatabase()
+		config = params.TestChainConfig
+		gspec  = &core.Genesis{
+			Config: config,
+			Alloc: core.GenesisAlloc{
+				sender:  {Balance: big.NewInt(1000000000000)},
+				checker: {Balance: new(big.Int), Code: append(append([]byte{0x73}, sink.Bytes()...), 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(config, new(big.Int))
+	)
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, 2, func(i int, block *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(sender), sink, big.NewInt(1000), 21000, big.NewInt(1), nil), signer, key)
+			block.AddTx(tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(config, &lbchain-devchain{blockchain: chain, chainDb: db, accountManager: accounts.NewManager()})
+
+	var (
+		latest = rpc.LatestBlockNumber
+		first  = rpc.BlockNumber(1)
+		hash   = blocks[0].Hash()
+		tracer = "callTracer"
+	)
+	tests := []struct {
+		block   rpc.BlockNumberOrHash
+		txIndex *hexutil.Uint
+		balance int64
+	}{
+		{rpc.BlockNumberOrHash{BlockNumber: &latest}, nil, 4000},
+		{rpc.BlockNumberOrHash{BlockNumber: &first}, nil, 2000},
+		{rpc.BlockNumberOrHash{BlockHash: &hash}, nil, 2000},
+		{rpc.BlockNumberOrHash{BlockHash: &hash}, newUint(0), 0},
+		{rpc.BlockNumberOrHash{BlockNumber: &first}, newUint(1), 1000},
+		{rpc.BlockNumberOrHash{BlockNumber: &latest}, newUint(2), 4000},
+	}
+	args := ethapi.CallArgs{From: sender, To: &checker, Gas: 100000, GasPrice: hexutil.Big(*big.NewInt(1))}
+
+	for i, tt := range tests {
+		// Trace the call with the struct logger and check the returned balance
+		res, err := api.TraceCall(context.Background(), args, tt.block, &TraceCallConfig{TxIndex: tt.txIndex})
+		if err != nil {
+			t.Fatalf("test %d: failed to trace call: %v", i, err)
+		}
+		result := res.(*ethapi.ExecutionResult)
+		if have := new(big.Int).SetBytes(common.FromHex(result.ReturnValue)); have.Int64() != tt.balance {
+			t.Errorf("test %d: balance mismatch: have %v, want %d", i, have, tt.balance)
+		}
+		if len(result.StructLogs) == 0 {
+			t.Errorf("test %d: no struct logs", i)
+		}
+		// Trace the call with a named tracer too
+		res, err = api.TraceCall(context.Background(), args, tt.block, &TraceCallConfig{TraceConfig: TraceConfig{Tracer: &tracer}, TxIndex: tt.txIndex})
+		if err != nil {
+			t.Fatalf("test %d: failed to trace call with tracer: %v", i, err)
+		}
+		var call struct {
+			To     common.Address `json:"to"`
+			Output hexutil.Bytes  `json:"output"`
+		}
+		if err := json.Unmarshal(res.(json.RawMessage), &call); err != nil {
+			t.Fatalf("test %d: failed to unmarshal trace: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(call.Output); call.To != checker || have.Int64() != tt.balance {
+			t.Errorf("test %d: call trace mismatch: have %x/%v, want %x/%d", i, call.To, have, checker, tt.balance)
+		}
+	}
+	// Applying more transactions than the block contains should fail
+	if _, err := api.TraceCall(context.Background(), args, rpc.BlockNumberOrHash{BlockHash: &hash}, &TraceCallConfig{TxIndex: newUint(3)}); err == nil {
+		t.Errorf("out of range transaction index accepted")
+	}
+	// Calls without gas and gas price should be traceable from unfunded accounts
+	args = ethapi.CallArgs{From: common.Address{0xff}, To: &checker}
+
+	res, err := api.TraceCall(context.Background(), args, rpc.BlockNumberOrHash{BlockNumber: &latest}, nil)
+	if err != nil {
+		t.Fatalf("failed to trace call without gas: %v", err)
+	}
+	result := res.(*ethapi.ExecutionResult)
+	if result.Failed {
+		t.Errorf("call without gas failed")
+	}
+	if have := new(big.Int).SetBytes(common.FromHex(result.ReturnValue)); have.Int64() != 4000 {
+		t.Errorf("balance mismatch without gas: have %v, want %d", have, 4000)
+	}
+}
+
+// Tests that the revert reason of a mined transaction can be recovered.
+func TestGetTransactionRevertReason(t *testing.T) {
+	// Create a contract reverting with a standard Error("revert reason")
+	data := common.FromHex("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000")
+	code := append([]byte{0x60, byte(len(data)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(data)), 0x60, 0x00, 0xfd}, data...)
+
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		sink     = common.Address{0xee}
+		reverter = common.Address{0xc0}
+
+		db, _  = lbchain-devdb.NewMemDatabase()
+		config = params.TestChainConfig
+		gspec  = &core.Genesis{
+			Config: config,
+			Alloc: core.GenesisAlloc{
+				sender:   {Balance: big.NewInt(1000000000000)},
+				reverter: {Balance: new(big.Int), Code: code},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(config, new(big.Int))
+	)
+	var txs []*types.Transaction
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, block *core.BlockGen) {
+		for _, to := range []common.Address{sink, reverter} {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(sender), to, new(big.Int), 100000, big.NewInt(1), nil), signer, key)
+			block.AddTx(tx)
+			txs = append(txs, tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(config, &lbchain-devchain{blockchain: chain, chainDb: db})
+
+	reason, err := api.GetTransactionRevertReason(context.Background(), txs[1].Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve revert reason: %v", err)
+	}
+	if !bytes.Equal(reason.Data, data) {
+		t.Errorf("revert data mismatch: have %x, want %x", []byte(reason.Data), data)
+	}
+	if reason.Reason != "revert reason" {
+		t.Errorf("revert reason mismatch: have %q, want %q", reason.Reason, "revert reason")
+	}
+	// Successful and unknown transactions have no revert reason
+	if _, err := api.GetTransactionRevertReason(context.Background(), txs[0].Hash()); err == nil {
+		t.Errorf("successful transaction reported a revert reason")
+	}
+	if _, err := api.GetTransactionRevertReason(context.Background(), common.Hash{0x01}); err == nil {
+		t.Errorf("unknown transaction reported a revert reason")
+	}
+}
+
+// Tests that a bad block stored in the database can be traced, with the
+// transactions following a failing one reported as not executed.
+func TestTraceBadBlock(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		sink   = common.Address{0xee}
+
+		db, _  = lbchain-devdb.NewMemDatabase()
+		config = params.TestChainConfig
+		gspec  = &core.Genesis{
+			Config: config,
+			Alloc:  core.GenesisAlloc{sender: {Balance: big.NewInt(1000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(config, new(big.Int))
+	)
+	chain, _ := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	// Assemble a block on top of the genesis with a transaction of a future
+	// nonce in the middle, and store it as a bad block
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{0, 5, 1} {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, sink, big.NewInt(1000), 21000, big.NewInt(1), nil), signer, key)
+		txs = append(txs, tx)
+	}
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   genesis.GasLimit(),
+		Difficulty: genesis.Difficulty(),
+		Time:       new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+	}
+	block := types.NewBlock(header, txs, nil, nil)
+	if err := core.WriteBadBlock(db, &core.BadBlock{Block: block, Reason: "invalid nonce"}, 16); err != nil {
+		t.Fatalf("failed to store bad block: %v", err)
+	}
+	api := NewPrivateDebugAPI(config, &lbchain-devchain{blockchain: chain, chainDb: db})
+
+	results, err := api.TraceBadBlock(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace bad block: %v", err)
+	}
+	if len(results) != len(txs) {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(results), len(txs))
+	}
+	if res, ok := results[0].Result.(*ethapi.ExecutionResult); !ok || res.Failed || res.Gas != 21000 {
+		t.Errorf("valid transaction trace mismatch: have %+v", results[0])
+	}
+	if results[1].Error == "" {
+		t.Errorf("future nonce transaction traced without error")
+	}
+	if !strings.HasPrefix(results[2].Error, "not executed") {
+		t.Errorf("transaction after failure error mismatch: have %q", results[2].Error)
+	}
+	// Unknown bad blocks should be rejected
+	if _, err := api.TraceBadBlock(context.Background(), common.Hash{0x01}, nil); err == nil {
+		t.Errorf("unknown bad block traced")
+	}
+}
+
+func newUint(n uint) *hexutil.Uint {
+	return (*hexutil.Uint)(&n)
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -113,6 +114,9 @@ func New(ctx *node.ServiceContext, config *Config) (*lbchain-devchain, error) {
 		return nil, err
 	}
 	stopDbUpgrade := upgradeDeduplicateData(chainDb)
+	if chainDb, err = CreateAncientDB(chainDb, config, true); err != nil {
+		return nil, err
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -146,12 +150,15 @@ func New(ctx *node.ServiceContext, config *Config) (*lbchain-devchain, error) {
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, NoPrefetch: config.NoPrefetch, StateDiffs: config.StateDiffs}
 	)
 	lbchain-dev.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, lbchain-dev.chainConfig, lbchain-dev.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	if config.ParallelExec {
+		lbchain-dev.blockchain.SetProcessor(core.NewParallelStateProcessor(lbchain-dev.chainConfig, lbchain-dev.blockchain, lbchain-dev.engine))
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -163,6 +170,9 @@ func New(ctx *node.ServiceContext, config *Config) (*lbchain-devchain, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = ctx.ResolvePath(config.TxPool.Snapshot)
+	}
 	lbchain-dev.txPool = core.NewTxPool(config.TxPool, lbchain-dev.chainConfig, lbchain-dev.blockchain)
 
 	if lbchain-dev.protocolManager, err = NewProtocolManager(lbchain-dev.chainConfig, config.SyncMode, config.NetworkId, lbchain-dev.eventMux, lbchain-dev.txPool, lbchain-dev.engine, lbchain-dev.blockchain, chainDb); err != nil {
@@ -171,6 +181,15 @@ func New(ctx *node.ServiceContext, config *Config) (*lbchain-devchain, error) {
 	lbchain-dev.miner = miner.New(lbchain-dev, lbchain-dev.chainConfig, lbchain-dev.EventMux(), lbchain-dev.engine)
 	lbchain-dev.miner.SetExtra(makeExtraData(config.ExtraData))
 
+	if len(config.MinerPriority) > 0 && config.MinerOrdering != miner.OrderingPriority {
+		return nil, fmt.Errorf("prioritized system contracts require the %q transaction ordering", miner.OrderingPriority)
+	}
+	ordering, err := miner.NewTxOrdering(config.MinerOrdering, config.MinerPriority)
+	if err != nil {
+		return nil, err
+	}
+	lbchain-dev.miner.SetOrdering(ordering)
+
 	lbchain-dev.ApiBackend = &lbchain-devApiBackend{lbchain-dev, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
@@ -210,6 +229,24 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (lbchain-de
 	return db, nil
 }
 
+// CreateAncientDB attaches an append-only ancient store to a persistent chain
+// database. If freeze is set, old canonical blocks are moved out of the key-value
+// store in the background, otherwise only the already frozen ones are accessed.
+func CreateAncientDB(db lbchain-devdb.Database, config *Config, freeze bool) (lbchain-devdb.Database, error) {
+	ldb, ok := db.(*lbchain-devdb.LDBDatabase)
+	if !ok || config.AncientThreshold == 0 {
+		return db, nil
+	}
+	dir := config.DatabaseFreezer
+	if dir == "" {
+		dir = filepath.Join(ldb.Path(), "ancient")
+	}
+	if !freeze {
+		return core.OpenDatabaseWithFreezer(db, dir)
+	}
+	return core.NewDatabaseWithFreezer(db, dir, config.AncientThreshold)
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an lbchain-devchain service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chainConfig *params.ChainConfig, db lbchain-devdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
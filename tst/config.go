@@ -43,12 +43,14 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:        1,
+	LightPeers:       100,
+	DatabaseCache:    768,
+	AncientThreshold: core.DefaultAncientThreshold,
+	TrieCleanCache:   256,
+	TrieCache:        256,
+	TrieTimeout:      5 * time.Minute,
+	GasPrice:         big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -91,14 +93,22 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string // Directory of the ancient store (default = inside the chain database)
+	AncientThreshold   uint64 // Number of recent blocks to keep out of the ancient store (0 = disabled)
+	TrieCleanCache     int    // Memory allowance (MB) for caching clean trie nodes read from disk
 	TrieCache          int
 	TrieTimeout        time.Duration
+	NoPrefetch         bool // Whlbchain-dever to disable speculative state prefetching during block import
+	ParallelExec       bool // Whlbchain-dever to execute block transactions concurrently
+	StateDiffs         bool // Whlbchain-dever to record the state changes of the imported blocks
 
 	// Mining-related options
-	lbchain-deverbase    common.Address `toml:",omitempty"`
-	MinerThreads int            `toml:",omitempty"`
-	ExtraData    []byte         `toml:",omitempty"`
-	GasPrice     *big.Int
+	lbchain-deverbase     common.Address `toml:",omitempty"`
+	MinerThreads  int            `toml:",omitempty"`
+	ExtraData     []byte         `toml:",omitempty"`
+	GasPrice      *big.Int
+	MinerOrdering string           `toml:",omitempty"` // Strategy ordering the transactions of the mined blocks
+	MinerPriority []common.Address `toml:",omitempty"` // System contracts prioritized by the priority ordering
 
 	// lbchain-devash options
 	lbchain-devash ethash.Config
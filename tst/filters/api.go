@@ -28,6 +28,7 @@ import (
 	lbchain-devereum "github.com/lbchain-devchain/go-lbchain-dev"
 	"github.com/lbchain-devchain/go-lbchain-dev/common"
 	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/core"
 	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
 	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
 	"github.com/lbchain-devchain/go-lbchain-dev/event"
@@ -229,6 +230,77 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// ReorgBlock identifies a block taking part in a chain reorganisation.
+type ReorgBlock struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+}
+
+// Reorg is the RPC representation of a chain reorganisation.
+type Reorg struct {
+	Ancestor   ReorgBlock    `json:"ancestor"`
+	Dropped    []ReorgBlock  `json:"dropped"`
+	Added      []ReorgBlock  `json:"added"`
+	DroppedTxs []common.Hash `json:"droppedTransactions"`
+	Reincluded []common.Hash `json:"reincludedTransactions"`
+}
+
+// NewReorg converts a chain reorganisation event into its RPC representation.
+func NewReorg(ev core.ChainReorgEvent) *Reorg {
+	reorg := &Reorg{
+		Ancestor:   ReorgBlock{Number: hexutil.Uint64(ev.Ancestor.Number.Uint64()), Hash: ev.Ancestor.Hash()},
+		Dropped:    make([]ReorgBlock, 0, len(ev.Dropped)),
+		Added:      make([]ReorgBlock, 0, len(ev.Added)),
+		DroppedTxs: make([]common.Hash, 0, len(ev.DroppedTxs)),
+		Reincluded: make([]common.Hash, 0, len(ev.ReincludedTxs)),
+	}
+	for _, block := range ev.Dropped {
+		reorg.Dropped = append(reorg.Dropped, ReorgBlock{Number: hexutil.Uint64(block.NumberU64()), Hash: block.Hash()})
+	}
+	for _, block := range ev.Added {
+		reorg.Added = append(reorg.Added, ReorgBlock{Number: hexutil.Uint64(block.NumberU64()), Hash: block.Hash()})
+	}
+	for _, tx := range ev.DroppedTxs {
+		reorg.DroppedTxs = append(reorg.DroppedTxs, tx.Hash())
+	}
+	for _, tx := range ev.ReincludedTxs {
+		reorg.Reincluded = append(reorg.Reincluded, tx.Hash())
+	}
+	return reorg
+}
+
+// Reorgs send a notification each time the canonical chain is reorganised, with
+// the common ancestor, the dropped and added blocks and the transactions dropped
+// from the chain or included again.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ChainReorgEvent)
+		reorgsSub := api.events.SubscribeReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, NewReorg(ev))
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -0,0 +1,104 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+)
+
+// NativeTracer is a transaction tracer implemented in Go, producing the same
+// output as its JavaScript counterpart of the same name, only much faster.
+type NativeTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the tracing, or any error
+	// that occurred during it.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// natives contains the constructors of all the native tracers by name. They
+// take precedence over the JavaScript tracers of the same name.
+var natives = map[string]func() NativeTracer{
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+}
+
+// NewNative instantiates the native tracer with the given name, if one exists.
+func NewNative(name string) (NativeTracer, bool) {
+	if constructor, ok := natives[name]; ok {
+		return constructor(), true
+	}
+	return nil, false
+}
+
+// interrupter implements the interruption logic shared by the native tracers.
+type interrupter struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+	err       error  // Error, if one has occurred
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (i *interrupter) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// interrupted reports whlbchain-dever tracing should stop, either due to a previous
+// error or an interruption request. In the latter case the EVM is aborted too.
+func (i *interrupter) interrupted(env *vm.EVM) bool {
+	if i.err != nil {
+		return true
+	}
+	if atomic.LoadUint32(&i.interrupt) > 0 {
+		i.err = i.reason
+		env.Cancel()
+		return true
+	}
+	return false
+}
+
+// peek returns the n'th item from the top of the stack, or zero if the stack is
+// not deep enough.
+func peek(stack *vm.Stack, n int) *big.Int {
+	data := stack.Data()
+	if len(data) <= n {
+		return new(big.Int)
+	}
+	return data[len(data)-n-1]
+}
+
+// sliceMemory returns a copy of a memory segment, or nil if the requested
+// segment is out of bounds.
+func sliceMemory(memory *vm.Memory, offset, size *big.Int) []byte {
+	if !offset.IsUint64() || !size.IsUint64() {
+		return nil
+	}
+	begin, end := offset.Uint64(), offset.Uint64()+size.Uint64()
+	if end < begin || uint64(memory.Len()) < end {
+		return nil
+	}
+	return memory.Get(int64(begin), int64(end-begin))
+}
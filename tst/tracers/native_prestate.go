@@ -0,0 +1,161 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/vm"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+)
+
+// prestateAccount is the state of an account before the execution of a
+// transaction, in the format of a genesis allocation.
+type prestateAccount struct {
+	Balance *big.Int                    `json:"-"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// MarshalJSON encodes the account with the balance in hex, as the JavaScript
+// tracer does.
+func (a *prestateAccount) MarshalJSON() ([]byte, error) {
+	type account prestateAccount
+	return json.Marshal(&struct {
+		Balance *hexutil.Big `json:"balance"`
+		*account
+	}{(*hexutil.Big)(a.Balance), (*account)(a)})
+}
+
+// prestateTracer is the native version of prestate_tracer.js, collecting the
+// state accessed by a transaction, sufficient to reexecute it from a custom
+// assembled genesis block.
+type prestateTracer struct {
+	interrupter
+
+	prestate map[common.Address]*prestateAccount
+	db       vm.StateDB // State database of the execution, nil if no code was run
+
+	create bool
+	from   common.Address
+	to     common.Address
+	value  *big.Int
+}
+
+// newPrestateTracer creates a native prestate tracer.
+func newPrestateTracer() NativeTracer {
+	return &prestateTracer{prestate: make(map[common.Address]*prestateAccount)}
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; !ok {
+		t.prestate[addr] = &prestateAccount{
+			Balance: new(big.Int).Set(t.db.GetBalance(addr)),
+			Nonce:   t.db.GetNonce(addr),
+			Code:    common.CopyBytes(t.db.GetCode(addr)),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+	}
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate, unless it's empty.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	storage := t.prestate[addr].Storage
+	if _, ok := storage[key]; !ok {
+		if val := t.db.Gelbchain-devate(addr, key); val != (common.Hash{}) {
+			storage[key] = val
+		}
+	}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create, t.from, t.to, t.value = create, from, to, new(big.Int).Set(value)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.interrupted(env) {
+		return nil
+	}
+	// Add the current account if we just started tracing. Its balance includes
+	// the value sent along with the message, which is fixed in GetResult.
+	if t.db == nil {
+		t.db = env.StateDB
+		t.lookupAccount(contract.Address())
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.BigToAddress(peek(stack, 0)))
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, t.db.GetNonce(from)))
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.BigToAddress(peek(stack, 1)))
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.BigToHash(peek(stack, 0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the assembled prestate, or any accumulated error.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.db == nil {
+		return nil, errors.New("no code executed, prestate unavailable")
+	}
+	// Deduct the value of the outer transaction and move it back to the origin
+	t.lookupAccount(t.from)
+	t.lookupAccount(t.to)
+
+	fromBal, toBal := t.prestate[t.from].Balance, t.prestate[t.to].Balance
+	t.prestate[t.to].Balance = new(big.Int).Sub(toBal, t.value)
+	t.prestate[t.from].Balance = new(big.Int).Add(fromBal, t.value)
+
+	// Decrement the caller's nonce, and remove empty create targets. Any existing
+	// state would have caused the transaction to be rejected in the first place.
+	t.prestate[t.from].Nonce--
+	if t.create {
+		delete(t.prestate, t.to)
+	}
+	return json.Marshal(t.prestate)
+}
@@ -116,79 +116,140 @@ type callTracerTest struct {
 	Result  *callTrace    `json:"result"`
 }
 
-// Iterates over all the input-output datasets in the tracer test harness and
-// runs the JavaScript tracers against them.
-func TestCallTracer(t *testing.T) {
+// resultTracer is a tracer able to report its result, JavaScript or native.
+type resultTracer interface {
+	vm.Tracer
+	GetResult() (json.RawMessage, error)
+}
+
+// runTracerTest executes the transaction of a tracer test case with the given
+// tracer attached, returning the trace result.
+func runTracerTest(t *testing.T, test *callTracerTest, tracer resultTracer) json.RawMessage {
+	// Configure a blockchain with the given prestate
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      origin,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	db, _ := lbchain-devdb.NewMemDatabase()
+	statedb := tests.MakePreState(db, test.Genesis.Alloc)
+
+	// Create the EVM environment and run the tracer in it
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	// Retrieve the trace result
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+// loadTracerTests reads all the call tracer test cases from the testdata folder.
+func loadTracerTests(t *testing.T) map[string]*callTracerTest {
 	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
 		t.Fatalf("failed to retrieve tracer test suite: %v", err)
 	}
+	tests := make(map[string]*callTracerTest)
 	for _, file := range files {
 		if !strings.HasPrefix(file.Name(), "call_tracer_") {
 			continue
 		}
-		file := file // capture range variable
-		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
-			t.Parallel()
+		// Call tracer test found, read if from disk
+		blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+		if err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		}
+		test := new(callTracerTest)
+		if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		tests[camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json"))] = test
+	}
+	return tests
+}
 
-			// Call tracer test found, read if from disk
-			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
-			if err != nil {
-				t.Fatalf("failed to read testcase: %v", err)
-			}
-			test := new(callTracerTest)
-			if err := json.Unmarshal(blob, test); err != nil {
-				t.Fatalf("failed to parse testcase: %v", err)
-			}
-			// Configure a blockchain with the given prestate
-			tx := new(types.Transaction)
-			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
-				t.Fatalf("failed to parse testcase input: %v", err)
-			}
-			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
-			origin, _ := signer.Sender(tx)
-
-			context := vm.Context{
-				CanTransfer: core.CanTransfer,
-				Transfer:    core.Transfer,
-				Origin:      origin,
-				Coinbase:    test.Context.Miner,
-				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
-				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
-				Difficulty:  (*big.Int)(test.Context.Difficulty),
-				GasLimit:    uint64(test.Context.GasLimit),
-				GasPrice:    tx.GasPrice(),
-			}
-			db, _ := lbchain-devdb.NewMemDatabase()
-			statedb := tests.MakePreState(db, test.Genesis.Alloc)
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the JavaScript and the native call tracers against them.
+func TestCallTracer(t *testing.T) {
+	for name, test := range loadTracerTests(t) {
+		test := test // capture range variable
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-			// Create the tracer, the EVM environment and run it
-			tracer, err := New("callTracer")
+			jst, err := New("callTracer")
 			if err != nil {
 				t.Fatalf("failed to create call tracer: %v", err)
 			}
-			evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
-
-			msg, err := tx.AsMessage(signer)
-			if err != nil {
-				t.Fatalf("failed to prepare transaction for tracing: %v", err)
-			}
-			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
-			if _, _, _, err = st.TransitionDb(); err != nil {
-				t.Fatalf("failed to execute transaction: %v", err)
-			}
-			// Retrieve the trace result and compare against the etalon
-			res, err := tracer.GetResult()
-			if err != nil {
-				t.Fatalf("failed to retrieve trace result: %v", err)
-			}
-			ret := new(callTrace)
-			if err := json.Unmarshal(res, ret); err != nil {
-				t.Fatalf("failed to unmarshal trace result: %v", err)
-			}
-			if !reflect.DeepEqual(ret, test.Result) {
-				t.Fatalf("trace mismatch: have %+v, want %+v", ret, test.Result)
+			native, _ := NewNative("callTracer")
+
+			for kind, tracer := range map[string]resultTracer{"javascript": jst, "native": native} {
+				// Compare the trace result against the etalon
+				ret := new(callTrace)
+				if err := json.Unmarshal(runTracerTest(t, test, tracer), ret); err != nil {
+					t.Fatalf("%s: failed to unmarshal trace result: %v", kind, err)
+				}
+				if !reflect.DeepEqual(ret, test.Result) {
+					t.Fatalf("%s: trace mismatch: have %+v, want %+v", kind, ret, test.Result)
+				}
 			}
 		})
 	}
 }
+
+// Tests that the native tracers produce the same output as their JavaScript
+// counterparts over the tracer test harness.
+func TestNativeTracers(t *testing.T) {
+	for name, test := range loadTracerTests(t) {
+		for tracer := range natives {
+			test, tracer := test, tracer // capture range variables
+			t.Run(name+"/"+tracer, func(t *testing.T) {
+				t.Parallel()
+
+				native, _ := NewNative(tracer)
+
+				var want, have interface{}
+				if err := json.Unmarshal(runTracerTest(t, test, native), &have); err != nil {
+					t.Fatalf("failed to unmarshal native result: %v", err)
+				}
+				jst, err := New(tracer)
+				if err != nil {
+					t.Fatalf("failed to create JavaScript tracer: %v", err)
+				}
+				if err := json.Unmarshal(runTracerTest(t, test, jst), &want); err != nil {
+					t.Fatalf("failed to unmarshal JavaScript result: %v", err)
+				}
+				// Execution time naturally differs between the runs
+				if tracer == "callTracer" {
+					delete(want.(map[string]interface{}), "time")
+					delete(have.(map[string]interface{}), "time")
+				}
+				if !reflect.DeepEqual(have, want) {
+					t.Fatalf("result mismatch:\nhave %v\nwant %v", have, want)
+				}
+			})
+		}
+	}
+}
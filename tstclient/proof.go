@@ -0,0 +1,184 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lbchain-devclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/common/hexutil"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/rlp"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCodeHash is the known hash of the empty EVM bytecode.
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
+
+// AccountResult is the Merkle proof of an account and of a set of its storage
+// slots (EIP-1186), along with the values proven.
+type AccountResult struct {
+	Address      common.Address
+	AccountProof [][]byte
+	Balance      *big.Int
+	CodeHash     common.Hash
+	Nonce        uint64
+	StorageHash  common.Hash
+	StorageProof []StorageResult
+}
+
+// StorageResult is the Merkle proof of a single storage slot of an account.
+type StorageResult struct {
+	Key   common.Hash
+	Value *big.Int
+	Proof [][]byte
+}
+
+type rpcAccountResult struct {
+	Address      common.Address     `json:"address"`
+	AccountProof []hexutil.Bytes    `json:"accountProof"`
+	Balance      *hexutil.Big       `json:"balance"`
+	CodeHash     common.Hash        `json:"codeHash"`
+	Nonce        hexutil.Uint64     `json:"nonce"`
+	StorageHash  common.Hash        `json:"storageHash"`
+	StorageProof []rpcStorageResult `json:"storageProof"`
+}
+
+type rpcStorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// ProofAt returns the Merkle proof of the given account and storage keys. The
+// block number can be nil, in which case the proof is made against the latest
+// known block. Use Verify to check the result against a trusted state root.
+func (ec *Client) ProofAt(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountResult, error) {
+	var res rpcAccountResult
+	if err := ec.c.CallContext(ctx, &res, "eth_getProof", account, keys, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	if res.Balance == nil {
+		return nil, fmt.Errorf("proof of %x missing balance", account)
+	}
+	result := &AccountResult{
+		Address:      res.Address,
+		AccountProof: fromHexBytes(res.AccountProof),
+		Balance:      (*big.Int)(res.Balance),
+		CodeHash:     res.CodeHash,
+		Nonce:        uint64(res.Nonce),
+		StorageHash:  res.StorageHash,
+		StorageProof: make([]StorageResult, len(res.StorageProof)),
+	}
+	for i, slot := range res.StorageProof {
+		if slot.Value == nil {
+			return nil, fmt.Errorf("proof of slot %s missing value", slot.Key)
+		}
+		result.StorageProof[i] = StorageResult{
+			Key:   common.HexToHash(slot.Key),
+			Value: (*big.Int)(slot.Value),
+			Proof: fromHexBytes(slot.Proof),
+		}
+	}
+	return result, nil
+}
+
+func fromHexBytes(blobs []hexutil.Bytes) [][]byte {
+	res := make([][]byte, len(blobs))
+	for i, blob := range blobs {
+		res[i] = blob
+	}
+	return res
+}
+
+// proofAccount is the consensus encoding of an account in the state trie.
+type proofAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Verify checks that the account and storage values of the result are proven
+// by the contained Merkle proofs against the given state root, usually taken
+// from a trusted block header. Accounts missing from the state must be reported
+// as empty ones, with all their storage slots zero.
+func (r *AccountResult) Verify(root common.Hash) error {
+	blob, err := verifyProof(root, r.Address[:], r.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	acc := proofAccount{Balance: new(big.Int), Root: emptyRoot, CodeHash: emptyCodeHash[:]}
+	if blob != nil {
+		if err := rlp.DecodeBytes(blob, &acc); err != nil {
+			return fmt.Errorf("invalid proven account: %v", err)
+		}
+	}
+	switch {
+	case r.Nonce != acc.Nonce:
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", r.Nonce, acc.Nonce)
+	case r.Balance == nil || r.Balance.Cmp(acc.Balance) != 0:
+		return fmt.Errorf("balance mismatch: have %v, proven %v", r.Balance, acc.Balance)
+	case r.StorageHash != acc.Root:
+		return fmt.Errorf("storage hash mismatch: have %x, proven %x", r.StorageHash, acc.Root)
+	case !bytes.Equal(r.CodeHash[:], acc.CodeHash):
+		return fmt.Errorf("code hash mismatch: have %x, proven %x", r.CodeHash, acc.CodeHash)
+	}
+	for _, slot := range r.StorageProof {
+		if slot.Value == nil {
+			return fmt.Errorf("slot %x: missing value", slot.Key)
+		}
+		enc, err := verifyProof(acc.Root, slot.Key[:], slot.Proof)
+		if err != nil {
+			return fmt.Errorf("slot %x: invalid proof: %v", slot.Key, err)
+		}
+		var value []byte
+		if enc != nil {
+			if _, value, _, err = rlp.Split(enc); err != nil {
+				return fmt.Errorf("slot %x: invalid proven value: %v", slot.Key, err)
+			}
+		}
+		if proven := new(big.Int).SetBytes(value); slot.Value.Cmp(proven) != 0 {
+			return fmt.Errorf("slot %x: value mismatch: have %v, proven %v", slot.Key, slot.Value, proven)
+		}
+	}
+	return nil
+}
+
+// verifyProof checks a Merkle proof of a secure trie key against a root hash,
+// returning the value proven (or nil if the key is proven to be absent).
+func verifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	// The empty trie has no nodes, everything is absent from it
+	if root == emptyRoot {
+		return nil, nil
+	}
+	db, _ := lbchain-devdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	value, err, _ := trie.VerifyProof(root, crypto.Keccak256(key), db)
+	return value, err
+}
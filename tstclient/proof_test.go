@@ -0,0 +1,143 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-lbchain-devereum library.
+//
+// The go-lbchain-devereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-lbchain-devereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-lbchain-devereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lbchain-devclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/lbchain-devchain/go-lbchain-dev/common"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/state"
+	"github.com/lbchain-devchain/go-lbchain-dev/core/types"
+	"github.com/lbchain-devchain/go-lbchain-dev/crypto"
+	"github.com/lbchain-devchain/go-lbchain-dev/lbchain-devdb"
+	"github.com/lbchain-devchain/go-lbchain-dev/internal/ethapi"
+	"github.com/lbchain-devchain/go-lbchain-dev/rpc"
+	"github.com/lbchain-devchain/go-lbchain-dev/trie"
+)
+
+// proofBackend is an API backend serving the state of a single block, just
+// enough for the proof retrievals.
+type proofBackend struct {
+	ethapi.Backend
+	root common.Hash
+	db   state.Database
+}
+
+func (b *proofBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	statedb, err := state.New(b.root, b.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statedb, &types.Header{Root: b.root}, nil
+}
+
+// newProofClient creates a client connected in-process to the proof API of a
+// node serving the given state.
+func newProofClient(t *testing.T, root common.Hash, db state.Database) *Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("lbchain-dev", ethapi.NewPublicBlockChainAPI(&proofBackend{root: root, db: db})); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	return NewClient(rpc.DialInProc(server))
+}
+
+// proof retrieves the proof of an account through the RPC API.
+func proof(t *testing.T, client *Client, addr common.Address, keys ...common.Hash) *AccountResult {
+	res, err := client.ProofAt(context.Background(), addr, keys, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve proof of %x: %v", addr, err)
+	}
+	return res
+}
+
+// Tests that account and storage proofs can be verified against the state root,
+// and that tampering with any of the proven values is detected.
+func TestProofVerification(t *testing.T) {
+	db, _ := lbchain-devdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	var (
+		addr    = common.Address{0x01}
+		missing = common.Address{0x02}
+		plain   = common.Address{0x03}
+	)
+	statedb.SetBalance(addr, big.NewInt(1000))
+	statedb.SetNonce(addr, 3)
+	statedb.SetCode(addr, []byte{0x60, 0x00})
+	for i := byte(1); i <= 32; i++ {
+		statedb.Selbchain-devate(addr, common.Hash{i}, common.Hash{31: i})
+	}
+	statedb.SetBalance(plain, big.NewInt(1))
+
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	client := newProofClient(t, root, statedb.Database())
+
+	// Valid proofs of existing and missing accounts and slots should pass
+	for i, res := range []*AccountResult{
+		proof(t, client, addr, common.Hash{1}, common.Hash{32}, common.Hash{0xff}),
+		proof(t, client, missing, common.Hash{1}),
+		proof(t, client, plain, common.Hash{1}),
+	} {
+		if err := res.Verify(root); err != nil {
+			t.Errorf("proof %d: valid proof rejected: %v", i, err)
+		}
+	}
+	// The account proofs should also be accepted by the trie directly
+	for _, acc := range []common.Address{addr, missing, plain} {
+		proofDb, _ := lbchain-devdb.NewMemDatabase()
+		for _, node := range proof(t, client, acc).AccountProof {
+			proofDb.Put(crypto.Keccak256(node), node)
+		}
+		value, err, _ := trie.VerifyProof(root, crypto.Keccak256(acc[:]), proofDb)
+		if err != nil {
+			t.Errorf("account %x: proof rejected by trie: %v", acc, err)
+		}
+		if exists := acc != missing; (value != nil) != exists {
+			t.Errorf("account %x: proven existence mismatch: have %v, want %v", acc, value != nil, exists)
+		}
+	}
+	// Tampered values should all be rejected
+	tampers := []func(res *AccountResult){
+		func(res *AccountResult) { res.Balance = big.NewInt(1001) },
+		func(res *AccountResult) { res.Nonce++ },
+		func(res *AccountResult) { res.CodeHash = common.Hash{} },
+		func(res *AccountResult) { res.StorageHash = emptyRoot },
+		func(res *AccountResult) { res.StorageProof[0].Value = big.NewInt(2) },
+		func(res *AccountResult) { res.StorageProof[1].Value = big.NewInt(0) },
+		func(res *AccountResult) { res.StorageProof[2].Value = big.NewInt(1) },
+		func(res *AccountResult) { res.StorageProof[0].Key = common.Hash{2} },
+		func(res *AccountResult) { res.AccountProof = res.AccountProof[:1] },
+		func(res *AccountResult) { res.Address = missing },
+	}
+	for i, tamper := range tampers {
+		res := proof(t, client, addr, common.Hash{1}, common.Hash{32}, common.Hash{0xff})
+		tamper(res)
+		if err := res.Verify(root); err == nil {
+			t.Errorf("tamper %d: invalid proof accepted", i)
+		}
+	}
+	res := proof(t, client, missing, common.Hash{1})
+	res.Balance = big.NewInt(1)
+	if err := res.Verify(root); err == nil {
+		t.Errorf("missing account with balance accepted")
+	}
+}